@@ -1,34 +1,144 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/SntrKslnn/matchmaking-service/internal/competition"
 	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
 	"github.com/SntrKslnn/matchmaking-service/internal/server"
+	"github.com/SntrKslnn/matchmaking-service/internal/validation"
 )
 
+// matchmakingServer is a listener serving the matchmaking queues, such as the TCP, the WebSocket, the REST or the gRPC server
+type matchmakingServer interface {
+	Start() error
+	Shutdown(ctx context.Context) error
+}
+
 func main() {
 	port := flag.Int("port", 8080, "TCP server port")
-	maxPlayers := flag.Int("max-players", 10, "Maximum number of players per competition")
-	minPlayers := flag.Int("min-players", 2, "Minimum number of players to start competition")
-	levelOverlap := flag.Int("level-matching-tolerance", 3, "Level overlap for matchmaking")
-	timeout := flag.Duration("timeout", 20*time.Second, "Matchmaking timeout duration")
+	webSocketPort := flag.Int("websocket-port", 0, "WebSocket server port. The WebSocket server is disabled if 0")
+	grpcPort := flag.Int("grpc-port", 0, "gRPC server port. The gRPC server is disabled if 0")
+	httpPort := flag.Int("http-port", 0, "HTTP REST server port. The REST server is disabled if 0")
+	webSocketOrigins := flag.String("websocket-allowed-origins", "", "Comma separated origins of the browser pages that may connect to the WebSocket server. Only pages served from the server's host may connect if empty")
+	queuesFile := flag.String("queues", "", "JSON file defining named queues and their settings. A single default queue is used if empty")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "Time the server waits on SIGTERM or SIGINT for players to be notified and connections to be closed")
+	minLevel := flag.Int("min-level", validation.DefaultMinLevel, "Lowest level a player may join with")
+	maxLevel := flag.Int("max-level", validation.DefaultMaxLevel, "Highest level a player may join with")
+	maxPlayerIDLength := flag.Int("max-player-id-length", validation.DefaultMaxIDLength, "Maximum length of a player id in bytes. The length is not limited if 0")
+	playerIDPattern := flag.String("player-id-pattern", validation.DefaultIDPattern, "Regular expression player ids must match. Any id is accepted if empty")
+	gameServerToken := flag.String("game-server-token", "", "Secret game servers send with their result and dropped player reports. Reports are refused if empty")
+	defaultQueueFlags := defineQueueFlags(flag.CommandLine)
 	flag.Parse()
 
-	fmt.Printf("Starting TCP server on port %d with max players %d, min players %d, level overlap %d, and timeout %s\n", *port, *maxPlayers, *minPlayers, *levelOverlap, *timeout)
-
-	matchMakingTcpServer := server.NewTCPServer(*port, matchmaking.MatchmakingConfig{
-		CompetitionConfig: competition.CompetitionConfig{
-			MaxPlayerCount: *maxPlayers,
-			MinPlayerCount: *minPlayers,
-		},
-		MatchmakingTimeout:     *timeout,
-		LevelMatchingTolerance: *levelOverlap,
+	validator, err := validation.NewJoinValidator(validation.ValidationConfig{
+		MinLevel:    *minLevel,
+		MaxLevel:    *maxLevel,
+		MaxIDLength: *maxPlayerIDLength,
+		IDPattern:   *playerIDPattern,
 	})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	queueConfigs := map[string]matchmaking.MatchmakingConfig{}
+	if *queuesFile == "" {
+		config, err := defaultQueueFlags.getMatchmakingConfig()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		queueConfigs[matchmaking.DefaultQueueName] = config
+	} else {
+		queueConfigs, err = readQueueConfigs(*queuesFile, flag.CommandLine)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
+	for queueName, config := range queueConfigs {
+		fmt.Printf("Queue %s: max players %d, min players %d, level overlap %d, and timeout %s\n", queueName, config.CompetitionConfig.GetMaxPlayerCount(), config.CompetitionConfig.MinPlayerCount, config.LevelMatchingTolerance, config.MatchmakingTimeout)
+	}
+	queues := matchmaking.NewQueueRegistry(queueConfigs)
+	fmt.Printf("Starting TCP server on port %d\n", *port)
+	servers := []matchmakingServer{server.NewTCPServer(*port, queues, validator, *gameServerToken)}
+	if *webSocketPort != 0 {
+		fmt.Printf("Starting WebSocket server on port %d\n", *webSocketPort)
+		servers = append(servers, server.NewWebSocketServer(*webSocketPort, splitList(*webSocketOrigins), queues, validator))
+	}
+	if *httpPort != 0 {
+		fmt.Printf("Starting HTTP server on port %d, API description at %s\n", *httpPort, server.OpenAPIPath)
+		servers = append(servers, server.NewRestServer(*httpPort, queues, validator))
+	}
+	if *grpcPort != 0 {
+		fmt.Printf("Starting gRPC server on port %d\n", *grpcPort)
+		servers = append(servers, server.NewGRPCServer(*grpcPort, queues, validator, *gameServerToken))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
 
-	matchMakingTcpServer.Start()
+	serverStopped := make(chan error, len(servers))
+	for _, matchmakingServer := range servers {
+		go func() {
+			serverStopped <- matchmakingServer.Start()
+		}()
+	}
+
+	select {
+	case err := <-serverStopped:
+		fmt.Println(err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+	// a second signal terminates the server right away
+	stop()
+
+	fmt.Printf("Shutting down, waiting up to %s\n", *shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := shutdownServers(shutdownCtx, servers); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for range servers {
+		<-serverStopped
+	}
+}
+
+// shutdownServers shuts down the servers at the same time, so that none of them keeps accepting players
+// while the others drain the queues they share
+func shutdownServers(ctx context.Context, servers []matchmakingServer) error {
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, matchmakingServer := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = matchmakingServer.Shutdown(ctx)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
 
+// splitList splits a comma separated flag value, ignoring empty items
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
+	"github.com/SntrKslnn/matchmaking-service/internal/results"
+)
+
+// queueFlags are the flags configuring a matchmaking queue
+// The same flags are used on the command line and as keys in the queues file
+type queueFlags struct {
+	maxPlayers             *int
+	minPlayers             *int
+	levelOverlap           *int
+	timeout                *time.Duration
+	wideningStep           *int
+	wideningInterval       *time.Duration
+	wideningMax            *int
+	requeueMaxRetries      *int
+	ratingSystem           *string
+	ratingTolerance        *float64
+	ratingDeviationWeight  *float64
+	partyLevelAggregation  *string
+	partyMaxLevelWeight    *float64
+	teamCount              *int
+	teamSize               *int
+	teamBalancing          *string
+	maxPing                *int
+	pingRelaxationStep     *int
+	pingRelaxationInterval *time.Duration
+	pingRelaxationMax      *int
+	readyCheckWindow       *time.Duration
+	backfillWindow         *time.Duration
+	resultReportWindow     *time.Duration
+	duplicateJoinPolicy    *string
+	idempotencyKeyTTL      *time.Duration
+	shutdownPolicy         *string
+}
+
+func defineQueueFlags(flagSet *flag.FlagSet) *queueFlags {
+	return &queueFlags{
+		maxPlayers:             flagSet.Int("max-players", 10, "Maximum number of players per competition"),
+		minPlayers:             flagSet.Int("min-players", 2, "Minimum number of players to start competition"),
+		levelOverlap:           flagSet.Int("level-matching-tolerance", 3, "Level overlap for matchmaking"),
+		timeout:                flagSet.Duration("timeout", 20*time.Second, "Matchmaking timeout duration"),
+		wideningStep:           flagSet.Int("level-widening-step", 0, "Levels added to both ends of a waiting competition's level range on every widening"),
+		wideningInterval:       flagSet.Duration("level-widening-interval", 5*time.Second, "Time between two level range widenings"),
+		wideningMax:            flagSet.Int("level-widening-max", 0, "Maximum number of levels a competition's level range is widened by on both ends"),
+		requeueMaxRetries:      flagSet.Int("requeue-max-retries", 0, "Maximum number of times a player of an aborted competition is put back into matchmaking"),
+		ratingSystem:           flagSet.String("rating-system", "", "Rating system used to match players by skill in addition to level: elo or glicko2. Rating matching is disabled if empty"),
+		ratingTolerance:        flagSet.Float64("rating-matching-tolerance", 200, "Rating difference that is always accepted in rating matching"),
+		ratingDeviationWeight:  flagSet.Float64("rating-deviation-weight", 1, "How much the rating deviation widens the accepted rating difference"),
+		partyLevelAggregation:  flagSet.String("party-level-aggregation", string(matchmaking.PartyLevelAggregation_Average), "How the levels of party members are combined for matching: average, max or weighted"),
+		partyMaxLevelWeight:    flagSet.Float64("party-max-level-weight", 0.5, "Weight of the highest member level in the weighted party level aggregation, between 0 and 1"),
+		teamCount:              flagSet.Int("team-count", 0, "Number of teams players are split into when a competition starts. Teams are not used if less than 2"),
+		teamSize:               flagSet.Int("team-size", 0, "Number of players in a full team. If set, a competition is full with team-count * team-size players"),
+		teamBalancing:          flagSet.String("team-balancing", string(competition.TeamBalancing_Level), "Strength teams are balanced with: level or rating"),
+		maxPing:                flagSet.Int("max-ping", 0, "Highest ping in milliseconds a player may have to the region of a competition. Region matching is disabled if 0"),
+		pingRelaxationStep:     flagSet.Int("ping-relaxation-step", 0, "Milliseconds added to the ping threshold on every relaxation"),
+		pingRelaxationInterval: flagSet.Duration("ping-relaxation-interval", 5*time.Second, "Time waited between two ping threshold relaxations"),
+		pingRelaxationMax:      flagSet.Int("ping-relaxation-max", 0, "Maximum number of milliseconds the ping threshold is relaxed by"),
+		backfillWindow:         flagSet.Duration("backfill-window", 0, "Time after the start a competition with open slots takes new players. Backfill is disabled if 0"),
+		resultReportWindow:     flagSet.Duration("result-report-window", matchmaking.DefaultResultReportWindow, "Time after the start a competition takes its result before it is aborted and forgotten"),
+		readyCheckWindow:       flagSet.Duration("ready-check-window", 0, "Time players have to confirm they are ready before a competition starts. Ready check is disabled if 0"),
+		duplicateJoinPolicy:    flagSet.String("duplicate-join-policy", string(matchmaking.DuplicateJoinPolicy_Reject), "How a join of a player already in matchmaking is handled: reject, take_over or attach"),
+		idempotencyKeyTTL:      flagSet.Duration("idempotency-key-ttl", time.Minute, "Time a join made with an idempotency key is remembered after the player was placed in a started competition"),
+		shutdownPolicy:         flagSet.String("shutdown-policy", string(matchmaking.ShutdownPolicy_Abort), "What happens to open competitions when the server shuts down: abort, or start the ones that reached the minimum player count"),
+	}
+}
+
+func (f *queueFlags) getRatingMatchingConfig() (matchmaking.RatingMatchingConfig, error) {
+	ratingMatching := matchmaking.RatingMatchingConfig{
+		Matching: rating.MatchingConfig{
+			Tolerance:       *f.ratingTolerance,
+			DeviationWeight: *f.ratingDeviationWeight,
+		},
+	}
+	switch *f.ratingSystem {
+	case "":
+	case "elo":
+		ratingMatching.Enabled = true
+		ratingMatching.RatingService = rating.NewRatingService(rating.NewEloCalculator(rating.DefaultEloKFactor))
+	case "glicko2":
+		ratingMatching.Enabled = true
+		ratingMatching.RatingService = rating.NewRatingService(rating.NewGlicko2Calculator(rating.DefaultGlicko2Tau))
+	default:
+		return ratingMatching, fmt.Errorf("unknown rating system %q", *f.ratingSystem)
+	}
+	return ratingMatching, nil
+}
+
+func (f *queueFlags) getDuplicateJoinPolicy() (matchmaking.DuplicateJoinPolicy, error) {
+	duplicateJoinPolicy := matchmaking.DuplicateJoinPolicy(*f.duplicateJoinPolicy)
+	switch duplicateJoinPolicy {
+	case matchmaking.DuplicateJoinPolicy_Reject, matchmaking.DuplicateJoinPolicy_TakeOver, matchmaking.DuplicateJoinPolicy_Attach:
+		return duplicateJoinPolicy, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate join policy %q", *f.duplicateJoinPolicy)
+	}
+}
+
+func (f *queueFlags) getShutdownPolicy() (matchmaking.ShutdownPolicy, error) {
+	shutdownPolicy := matchmaking.ShutdownPolicy(*f.shutdownPolicy)
+	switch shutdownPolicy {
+	case matchmaking.ShutdownPolicy_Abort, matchmaking.ShutdownPolicy_Start:
+		return shutdownPolicy, nil
+	default:
+		return "", fmt.Errorf("unknown shutdown policy %q", *f.shutdownPolicy)
+	}
+}
+
+func (f *queueFlags) getPartyLevelAggregation() (matchmaking.PartyLevelAggregation, error) {
+	partyLevelAggregation := matchmaking.PartyLevelAggregation(*f.partyLevelAggregation)
+	switch partyLevelAggregation {
+	case matchmaking.PartyLevelAggregation_Average, matchmaking.PartyLevelAggregation_Max, matchmaking.PartyLevelAggregation_Weighted:
+		return partyLevelAggregation, nil
+	default:
+		return "", fmt.Errorf("unknown party level aggregation %q", *f.partyLevelAggregation)
+	}
+}
+
+func (f *queueFlags) getTeamBalancing() (competition.TeamBalancing, error) {
+	teamBalancing := competition.TeamBalancing(*f.teamBalancing)
+	switch teamBalancing {
+	case competition.TeamBalancing_Level, competition.TeamBalancing_Rating:
+		return teamBalancing, nil
+	default:
+		return "", fmt.Errorf("unknown team balancing %q", *f.teamBalancing)
+	}
+}
+
+func (f *queueFlags) getMatchmakingConfig() (matchmaking.MatchmakingConfig, error) {
+	ratingMatching, err := f.getRatingMatchingConfig()
+	if err != nil {
+		return matchmaking.MatchmakingConfig{}, err
+	}
+
+	duplicateJoinPolicy, err := f.getDuplicateJoinPolicy()
+	if err != nil {
+		return matchmaking.MatchmakingConfig{}, err
+	}
+
+	shutdownPolicy, err := f.getShutdownPolicy()
+	if err != nil {
+		return matchmaking.MatchmakingConfig{}, err
+	}
+
+	partyLevelAggregation, err := f.getPartyLevelAggregation()
+	if err != nil {
+		return matchmaking.MatchmakingConfig{}, err
+	}
+
+	teamBalancing, err := f.getTeamBalancing()
+	if err != nil {
+		return matchmaking.MatchmakingConfig{}, err
+	}
+
+	// ratings are updated from the reported results of the queue's competitions
+	resultListeners := []results.ResultListener{}
+	if ratingMatching.RatingService != nil {
+		resultListeners = append(resultListeners, results.NewRatingUpdater(ratingMatching.RatingService))
+	}
+
+	return matchmaking.MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: *f.maxPlayers,
+			MinPlayerCount: *f.minPlayers,
+			TeamCount:      *f.teamCount,
+			TeamSize:       *f.teamSize,
+			TeamBalancing:  teamBalancing,
+		},
+		MatchmakingTimeout:     *f.timeout,
+		LevelMatchingTolerance: *f.levelOverlap,
+		LevelRangeWidening: matchmaking.LevelRangeWideningConfig{
+			Step:        *f.wideningStep,
+			Interval:    *f.wideningInterval,
+			MaxWidening: *f.wideningMax,
+		},
+		RequeuePolicy: matchmaking.RequeuePolicy{
+			MaxRetries: *f.requeueMaxRetries,
+		},
+		RatingMatching: ratingMatching,
+		Party: matchmaking.PartyConfig{
+			LevelAggregation: partyLevelAggregation,
+			MaxLevelWeight:   *f.partyMaxLevelWeight,
+		},
+		RegionMatching: matchmaking.RegionMatchingConfig{
+			MaxPing:                *f.maxPing,
+			PingRelaxationStep:     *f.pingRelaxationStep,
+			PingRelaxationInterval: *f.pingRelaxationInterval,
+			MaxPingRelaxation:      *f.pingRelaxationMax,
+		},
+		ReadyCheck: matchmaking.ReadyCheckConfig{
+			Window: *f.readyCheckWindow,
+		},
+		Backfill: matchmaking.BackfillConfig{
+			Window: *f.backfillWindow,
+		},
+		Results: matchmaking.ResultsConfig{
+			Listeners:    resultListeners,
+			ReportWindow: *f.resultReportWindow,
+		},
+		DuplicateJoin: matchmaking.DuplicateJoinConfig{
+			Policy:            duplicateJoinPolicy,
+			IdempotencyKeyTTL: *f.idempotencyKeyTTL,
+		},
+		Shutdown: matchmaking.ShutdownConfig{
+			Policy: shutdownPolicy,
+		},
+	}, nil
+}
+
+// readQueueConfigs reads the queues file and builds the configuration of every queue
+// The file maps queue names to flag overrides, for example {"ranked": {"min-players": 4, "timeout": "30s"}}
+// Flags set on the command line apply to every queue unless the queue overrides them
+// @param path the path of the queues file
+// @param commandLine the parsed command line flags
+// @return the configuration of each queue by queue name
+func readQueueConfigs(path string, commandLine *flag.FlagSet) (map[string]matchmaking.MatchmakingConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queues file: %w", err)
+	}
+
+	// numbers are kept as written, so large values such as 1000000 are not turned into 1e+06
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	queueOverrides := map[string]map[string]any{}
+	if err := decoder.Decode(&queueOverrides); err != nil {
+		return nil, fmt.Errorf("invalid queues file: %w", err)
+	}
+	if len(queueOverrides) == 0 {
+		return nil, fmt.Errorf("queues file %s has no queues", path)
+	}
+
+	queueConfigs := make(map[string]matchmaking.MatchmakingConfig, len(queueOverrides))
+	for queueName, overrides := range queueOverrides {
+		queueFlagSet := flag.NewFlagSet(queueName, flag.ContinueOnError)
+		flags := defineQueueFlags(queueFlagSet)
+
+		var setErr error
+		commandLine.Visit(func(commandLineFlag *flag.Flag) {
+			if queueFlagSet.Lookup(commandLineFlag.Name) != nil && setErr == nil {
+				setErr = queueFlagSet.Set(commandLineFlag.Name, commandLineFlag.Value.String())
+			}
+		})
+		if setErr != nil {
+			return nil, fmt.Errorf("queue %s: %w", queueName, setErr)
+		}
+
+		for name, value := range overrides {
+			if queueFlagSet.Lookup(name) == nil {
+				return nil, fmt.Errorf("queue %s: unknown setting %q", queueName, name)
+			}
+			if err := queueFlagSet.Set(name, fmt.Sprint(value)); err != nil {
+				return nil, fmt.Errorf("queue %s: invalid value for %s: %w", queueName, name, err)
+			}
+		}
+
+		config, err := flags.getMatchmakingConfig()
+		if err != nil {
+			return nil, fmt.Errorf("queue %s: %w", queueName, err)
+		}
+		queueConfigs[queueName] = config
+	}
+	return queueConfigs, nil
+}
@@ -2,10 +2,19 @@ module github.com/SntrKslnn/matchmaking-service
 
 go 1.23.2
 
-require github.com/stretchr/testify v1.10.0
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/stretchr/testify v1.10.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241202173237-19429a94021a
+	google.golang.org/grpc v1.70.0
+	google.golang.org/protobuf v1.36.5
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.32.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,134 @@
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+type realClock struct{}
+
+func (c realClock) now() time.Time {
+	return time.Now()
+}
+
+func (c realClock) after(duration time.Duration) <-chan time.Time {
+	return time.After(duration)
+}
+
+func (c realClock) newTicker(interval time.Duration) Ticker {
+	return realTicker{ticker: time.NewTicker(interval)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+type fakeClock struct {
+	mutex  sync.Mutex
+	time   time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a timer or ticker of the fake clock
+type fakeTimer struct {
+	clock    *fakeClock
+	channel  chan time.Time
+	deadline time.Time
+	// interval is the interval of a ticker. Zero for a timer, which fires once
+	interval time.Duration
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{time: now}
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.time
+}
+
+func (c *fakeClock) after(duration time.Duration) <-chan time.Time {
+	return c.addTimer(duration, 0).channel
+}
+
+func (c *fakeClock) newTicker(interval time.Duration) Ticker {
+	if interval <= 0 {
+		panic("non-positive interval for fake clock ticker")
+	}
+	return c.addTimer(interval, interval)
+}
+
+func (c *fakeClock) addTimer(duration time.Duration, interval time.Duration) *fakeTimer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	timer := &fakeTimer{
+		clock:    c,
+		channel:  make(chan time.Time, 1),
+		deadline: c.time.Add(duration),
+		interval: interval,
+	}
+	if duration <= 0 {
+		timer.channel <- c.time
+		return timer
+	}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// advance moves the clock forward in one go and fires the due timers in the order of their deadlines
+// A ticker that is due several times ticks only once, as its channel holds a single tick
+func (c *fakeClock) advance(duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.time = c.time.Add(duration)
+	slices.SortStableFunc(c.timers, func(a, b *fakeTimer) int {
+		return a.deadline.Compare(b.deadline)
+	})
+
+	dueTimers := []*fakeTimer{}
+	c.timers = slices.DeleteFunc(c.timers, func(timer *fakeTimer) bool {
+		if timer.deadline.After(c.time) {
+			return false
+		}
+		dueTimers = append(dueTimers, timer)
+		return timer.interval == 0
+	})
+
+	for _, timer := range dueTimers {
+		select {
+		case timer.channel <- timer.deadline:
+		default:
+		}
+		for timer.interval > 0 && !timer.deadline.After(c.time) {
+			timer.deadline = timer.deadline.Add(timer.interval)
+		}
+	}
+}
+
+func (c *fakeClock) removeTimer(timer *fakeTimer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.timers = slices.DeleteFunc(c.timers, func(other *fakeTimer) bool {
+		return other == timer
+	})
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.channel
+}
+
+func (t *fakeTimer) Stop() {
+	t.clock.removeTimer(t)
+}
@@ -0,0 +1,89 @@
+package clock
+
+import (
+	"time"
+)
+
+// Clock tells the time and creates the timers of the services
+// Services take the clock from their configuration, so tests can replace the real clock with a fake clock
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// After returns a channel that receives the time once the duration has passed
+	After(duration time.Duration) <-chan time.Time
+
+	// NewTicker returns a ticker that ticks every interval until it is stopped
+	NewTicker(interval time.Duration) Ticker
+}
+
+// Ticker delivers ticks of a clock at an interval
+type Ticker interface {
+	// C returns the channel the ticks are delivered on
+	// Like with time.Ticker, ticks are dropped if the previous tick has not been received yet
+	C() <-chan time.Time
+
+	// Stop stops the ticker. No ticks are delivered after this
+	Stop()
+}
+
+// FakeClock is a clock that only moves when it is advanced
+// Timers and tickers of the fake clock fire while the clock is advanced past them
+type FakeClock interface {
+	Clock
+
+	// Advance moves the clock forward and fires the timers and tickers that are due
+	// @param duration the time to move the clock forward by
+	Advance(duration time.Duration)
+}
+
+// NewRealClock creates a clock that tells the system time
+// @return a new real clock
+func NewRealClock() Clock {
+	return realClock{}
+}
+
+// NewFakeClock creates a clock that only moves when it is advanced
+// @param now the time the clock starts at
+// @return a new fake clock
+func NewFakeClock(now time.Time) FakeClock {
+	return newFakeClock(now)
+}
+
+// OrReal returns the clock, or a real clock if the clock is not set
+// @param clock the configured clock
+// @return the clock to use
+func OrReal(clock Clock) Clock {
+	if clock == nil {
+		return NewRealClock()
+	}
+	return clock
+}
+
+func (c realClock) Now() time.Time {
+	return c.now()
+}
+
+func (c realClock) After(duration time.Duration) <-chan time.Time {
+	return c.after(duration)
+}
+
+func (c realClock) NewTicker(interval time.Duration) Ticker {
+	return c.newTicker(interval)
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now()
+}
+
+func (c *fakeClock) After(duration time.Duration) <-chan time.Time {
+	return c.after(duration)
+}
+
+func (c *fakeClock) NewTicker(interval time.Duration) Ticker {
+	return c.newTicker(interval)
+}
+
+func (c *fakeClock) Advance(duration time.Duration) {
+	c.advance(duration)
+}
@@ -0,0 +1,58 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var startTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestFakeClock_TimerFiresWhenAdvancedPastDeadline(t *testing.T) {
+	fakeClock := NewFakeClock(startTime)
+	timer := fakeClock.After(time.Second)
+
+	fakeClock.Advance(999 * time.Millisecond)
+	assert.Empty(t, timer)
+
+	fakeClock.Advance(time.Millisecond)
+	assert.Equal(t, startTime.Add(time.Second), <-timer)
+	assert.Equal(t, startTime.Add(time.Second), fakeClock.Now())
+
+	// a timer fires only once
+	fakeClock.Advance(time.Hour)
+	assert.Empty(t, timer)
+}
+
+func TestFakeClock_TimerWithoutDurationFiresImmediately(t *testing.T) {
+	fakeClock := NewFakeClock(startTime)
+	assert.Equal(t, startTime, <-fakeClock.After(0))
+}
+
+func TestFakeClock_TickerTicksEveryInterval(t *testing.T) {
+	fakeClock := NewFakeClock(startTime)
+	ticker := fakeClock.NewTicker(time.Second)
+
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, startTime.Add(time.Second), <-ticker.C())
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, startTime.Add(2*time.Second), <-ticker.C())
+
+	// ticks that are not received are dropped
+	fakeClock.Advance(5 * time.Second)
+	assert.Equal(t, startTime.Add(3*time.Second), <-ticker.C())
+	assert.Empty(t, ticker.C())
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, startTime.Add(8*time.Second), <-ticker.C())
+
+	ticker.Stop()
+	fakeClock.Advance(time.Minute)
+	assert.Empty(t, ticker.C())
+}
+
+func TestOrReal(t *testing.T) {
+	fakeClock := NewFakeClock(startTime)
+	assert.Equal(t, fakeClock, OrReal(fakeClock))
+	assert.Equal(t, NewRealClock(), OrReal(nil))
+}
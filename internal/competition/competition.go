@@ -1,36 +1,141 @@
 package competition
 
 import (
+	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
 	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
 )
 
 type competition struct {
 	id               int
 	config           CompetitionConfig
+	clock            clock.Clock
 	playerLevelRange CompetitionLevelRange
+	region           string
+	createdAt        time.Time
 
 	players map[string]model.PlayerData
+
+	// teams maps the id of a player to the player's team. Teams are assigned when the competition starts
+	teams map[string]int
+
+	state            CompetitionState
+	stateTransitions []StateTransition
+
+	// openForBackfill allows players to join the competition while it is in progress
+	openForBackfill bool
 }
 
-func newCompetition(id int, config CompetitionConfig, playerLevelRange CompetitionLevelRange) *competition {
+func newCompetition(id int, config CompetitionConfig, playerLevelRange CompetitionLevelRange, region string) *competition {
 	return &competition{
 		id:               id,
 		config:           config,
+		clock:            clock.OrReal(config.Clock),
 		playerLevelRange: playerLevelRange,
+		region:           region,
+		createdAt:        clock.OrReal(config.Clock).Now(),
 		players:          make(map[string]model.PlayerData),
+		state:            CompetitionState_Created,
+	}
+}
+
+// addParty checks that all members can join and then adds them
+func (c *competition) addParty(partyData model.PlayerData, members []model.PlayerData) error {
+	if err := c.checkPartyCanJoin(partyData, members); err != nil {
+		return err
+	}
+	for _, member := range members {
+		c.addPlayer(member)
 	}
+	return nil
+}
+
+func (c *competition) checkPartyCanJoin(partyData model.PlayerData, members []model.PlayerData) error {
+	if !c.isAcceptingPlayers() {
+		return fmt.Errorf("%w: competition %d is %s", ErrNotAcceptingPlayers, c.id, c.state)
+	}
+	joiningPlayerIDs := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		_, joined := c.players[member.ID]
+		_, joining := joiningPlayerIDs[member.ID]
+		if joined || joining {
+			return fmt.Errorf("%w: player %s in competition %d", ErrAlreadyJoined, member.ID, c.id)
+		}
+		joiningPlayerIDs[member.ID] = struct{}{}
+	}
+	if !c.isPlayerLevelMatching(partyData) {
+		return fmt.Errorf("%w: level %d in competition %d with levels %d-%d", ErrLevelOutOfRange, partyData.Level, c.id, c.playerLevelRange.Min, c.playerLevelRange.Max)
+	}
+	if len(c.players)+len(members) > c.config.GetMaxPlayerCount() {
+		return fmt.Errorf("%w: competition %d has %d of %d players", ErrCompetitionFull, c.id, len(c.players), c.config.GetMaxPlayerCount())
+	}
+	return nil
+}
+
+// isAcceptingPlayers checks if players can join the competition in its current state
+// A competition in progress only takes players to fill open slots while it is open for backfill
+func (c *competition) isAcceptingPlayers() bool {
+	if c.state == CompetitionState_InProgress {
+		return c.openForBackfill
+	}
+	return c.state == CompetitionState_Created || c.state == CompetitionState_Matchmaking
 }
 
 func (c *competition) addPlayer(playerData model.PlayerData) {
 	c.addPlayerToCompetition(playerData)
+	// A player joining a started team competition fills an open team slot
+	if c.teams != nil {
+		c.teams[playerData.ID] = c.findTeamForNewPlayer()
+	}
+}
+
+func (c *competition) removePlayer(playerID string) error {
+	if c.state == CompetitionState_Finished || c.state == CompetitionState_Aborted {
+		return fmt.Errorf("%w: competition %d is %s", ErrCompetitionClosed, c.id, c.state)
+	}
+	if _, joined := c.players[playerID]; !joined {
+		return fmt.Errorf("%w: player %s in competition %d", ErrNotJoined, playerID, c.id)
+	}
+	delete(c.players, playerID)
+	delete(c.teams, playerID)
+	return nil
 }
 
 func (c *competition) isPlayerLevelMatching(playerData model.PlayerData) bool {
 	return playerData.Level >= c.playerLevelRange.Min && playerData.Level <= c.playerLevelRange.Max
 }
 
+func (c *competition) isPlayerRatingMatching(playerData model.PlayerData, matchingConfig rating.MatchingConfig) bool {
+	if len(c.players) == 0 {
+		return true
+	}
+	return rating.IsMatching(rating.OfPlayer(playerData), c.getRating(), matchingConfig)
+}
+
+func (c *competition) getRating() rating.Rating {
+	ratings := make([]rating.Rating, 0, len(c.players))
+	for _, player := range c.players {
+		ratings = append(ratings, rating.OfPlayer(player))
+	}
+	return rating.Aggregate(ratings)
+}
+
+func (c *competition) getRegion() string {
+	return c.region
+}
+
+func (c *competition) getLevelRange() CompetitionLevelRange {
+	return c.playerLevelRange
+}
+
+func (c *competition) setLevelRange(playerLevelRange CompetitionLevelRange) {
+	c.playerLevelRange = playerLevelRange
+}
+
 func (c *competition) addPlayerToCompetition(playerData model.PlayerData) {
 	c.players[playerData.ID] = playerData
 }
@@ -47,6 +152,19 @@ func (c *competition) getNumberOfJoinedPlayers() int {
 	return len(c.players)
 }
 
-func (c *competition) start() {
-	slog.Info("Competition started", "id", c.id, "players", c.getPlayers())
+func (c *competition) getTeamOfPlayer(playerID string) (int, bool) {
+	team, assigned := c.teams[playerID]
+	return team, assigned
+}
+
+func (c *competition) start() error {
+	return c.transitionTo(CompetitionState_InProgress)
+}
+
+// onStart is called when the competition moves to CompetitionState_InProgress
+func (c *competition) onStart() {
+	if c.isTeamCompetition() {
+		c.assignTeams()
+	}
+	slog.Info("Competition started", "id", c.id, "players", c.getPlayers(), "teams", c.teams)
 }
@@ -1,23 +1,74 @@
 package competition
 
 import (
+	"errors"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
 	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
 )
 
 type Competition interface {
 	// AddPlayer adds a player to the competition
 	// @param playerData the data of the player to add
-	AddPlayer(playerData model.PlayerData)
+	// @return ErrNotAcceptingPlayers, ErrAlreadyJoined, ErrLevelOutOfRange or ErrCompetitionFull if the player cannot be added
+	AddPlayer(playerData model.PlayerData) error
+
+	// AddParty adds the members of a party to the competition together
+	// The party is level matched with the level of the party data instead of the levels of the members,
+	// and either all members are added or none
+	// @param partyData the level the party is matched with
+	// @param members the members of the party
+	// @return ErrNotAcceptingPlayers, ErrAlreadyJoined, ErrLevelOutOfRange or ErrCompetitionFull if the party cannot be added
+	AddParty(partyData model.PlayerData, members []model.PlayerData) error
+
+	// RemovePlayer removes a player from the competition
+	// @param playerID the id of the player to remove
+	// @return ErrNotJoined if the player is not in the competition, or ErrCompetitionClosed if the competition is finished or aborted
+	RemovePlayer(playerID string) error
 
 	// IsPlayerLevelMatching checks if a player's level is within the competition's level range
 	// @param playerData the data of the player to check
 	// @return true if the player's level is within the competition's level range, false otherwise
 	IsPlayerLevelMatching(playerData model.PlayerData) bool
 
+	// IsPlayerRatingMatching checks if a player's rating is close enough to the rating of the competition
+	// A competition without players matches every rating
+	// @param playerData the data of the player to check
+	// @param matchingConfig defines how close the ratings must be
+	// @return true if the player's rating matches the competition's rating, false otherwise
+	IsPlayerRatingMatching(playerData model.PlayerData, matchingConfig rating.MatchingConfig) bool
+
+	// GetRating returns the combined rating of the players in the competition
+	// @return the rating of the competition
+	GetRating() rating.Rating
+
+	// GetRegion returns the region the competition is played in
+	// @return the region of the competition, or empty if the competition is not bound to a region
+	GetRegion() string
+
+	// GetLevelRange returns the range of levels a player can be in to join the competition
+	// @return the level range of the competition
+	GetLevelRange() CompetitionLevelRange
+
+	// SetLevelRange changes the range of levels a player can be in to join the competition
+	// Players that have already joined are not affected
+	// @param playerLevelRange the new level range of the competition
+	SetLevelRange(playerLevelRange CompetitionLevelRange)
+
+	// SetOpenForBackfill opens or closes a competition in progress for players filling open slots
+	// @param open true if players can join the competition while it is in progress
+	SetOpenForBackfill(open bool)
+
 	// GetID returns the id of the competition
 	// @return the id of the competition
 	GetID() int
 
+	// GetCreatedAt returns the time the competition was created, read from the clock of the competition config
+	// @return the creation time of the competition
+	GetCreatedAt() time.Time
+
 	// GetPlayers returns the players of the competition
 	// @return the players of the competition
 	GetPlayers() map[string]model.PlayerData
@@ -26,10 +77,88 @@ type Competition interface {
 	// @return the number of joined players in the competition
 	GetNumberOfJoinedPlayers() int
 
-	// Start starts the competition
-	Start()
+	// GetTeamOfPlayer returns the team a player was assigned to when the competition started
+	// A player added to a started competition is assigned to the team with the fewest players
+	// @param playerID the id of the player
+	// @return the team number starting from 1 and true, or false if the player has no team
+	GetTeamOfPlayer(playerID string) (int, bool)
+
+	// Start starts the competition by moving it to CompetitionState_InProgress
+	// Players of a team competition are split into teams whose total strengths are as close as possible
+	// @return ErrIllegalStateTransition if the competition cannot be started in its current state
+	Start() error
+
+	// TransitionTo moves the competition to a new lifecycle state
+	// Moving to CompetitionState_InProgress is the same as calling Start
+	// @param state the state to move to
+	// @return ErrIllegalStateTransition if the transition is not allowed from the current state
+	TransitionTo(state CompetitionState) error
+
+	// GetState returns the current lifecycle state of the competition
+	// @return the current state
+	GetState() CompetitionState
+
+	// GetStateTransitions returns the transitions the competition has gone through, oldest first
+	// @return the state transitions
+	GetStateTransitions() []StateTransition
 }
 
+// CompetitionState is a state in the lifecycle of a competition
+// A competition moves from created to matchmaking, optionally through a ready check, to in progress and
+// finally to finished. It can be aborted in any state before it is finished
+type CompetitionState string
+
+const (
+	// The competition has been created but is not open for players yet
+	CompetitionState_Created CompetitionState = "created"
+
+	// The competition is waiting for players
+	CompetitionState_Matchmaking CompetitionState = "matchmaking"
+
+	// The competition is waiting for its players to confirm they are ready
+	CompetitionState_ReadyCheck CompetitionState = "ready_check"
+
+	// The competition is being played
+	CompetitionState_InProgress CompetitionState = "in_progress"
+
+	// The competition has been played to the end
+	CompetitionState_Finished CompetitionState = "finished"
+
+	// The competition was aborted before it was finished
+	CompetitionState_Aborted CompetitionState = "aborted"
+)
+
+// StateTransition is a change of the lifecycle state of a competition
+type StateTransition struct {
+	From CompetitionState
+	To   CompetitionState
+	At   time.Time
+}
+
+var (
+	// ErrCompetitionFull is returned when a player is added to a competition without room for the player
+	ErrCompetitionFull = errors.New("competition is full")
+
+	// ErrLevelOutOfRange is returned when a player is added to a competition whose level range does not contain the player's level
+	ErrLevelOutOfRange = errors.New("player level is out of the competition's level range")
+
+	// ErrAlreadyJoined is returned when a player is added to a competition the player has already joined
+	ErrAlreadyJoined = errors.New("player has already joined the competition")
+
+	// ErrNotAcceptingPlayers is returned when a player is added to a competition in a ready check, finished or aborted,
+	// or to a competition in progress that is not open for backfill
+	ErrNotAcceptingPlayers = errors.New("competition is not accepting players")
+
+	// ErrNotJoined is returned when a player that is not in the competition is removed from it
+	ErrNotJoined = errors.New("player has not joined the competition")
+
+	// ErrCompetitionClosed is returned when a player is removed from a finished or aborted competition
+	ErrCompetitionClosed = errors.New("competition is finished or aborted")
+)
+
+// ErrIllegalStateTransition is returned when a competition is moved to a state that cannot follow its current state
+var ErrIllegalStateTransition = errors.New("illegal competition state transition")
+
 // CompetitionLevelRange represents the range of levels a player can be in to join a competition
 type CompetitionLevelRange struct {
 	Min int
@@ -40,28 +169,100 @@ type CompetitionLevelRange struct {
 type CompetitionConfig struct {
 	MaxPlayerCount int
 	MinPlayerCount int
+
+	// TeamCount is the number of teams the players are split into when the competition starts
+	// Teams are not used if TeamCount is less than 2
+	TeamCount int
+	// TeamSize is the number of players in a full team
+	// When set, a team competition is full with TeamCount * TeamSize players instead of MaxPlayerCount
+	TeamSize int
+	// TeamBalancing defines the strength the teams are balanced with. TeamBalancing_Level is used if not set
+	TeamBalancing TeamBalancing
+
+	// Clock stamps the state transitions. The real clock is used if not set
+	Clock clock.Clock
 }
 
+// GetMaxPlayerCount returns the number of players that fill a competition
+func (c CompetitionConfig) GetMaxPlayerCount() int {
+	if c.TeamCount > 1 && c.TeamSize > 0 {
+		return c.TeamCount * c.TeamSize
+	}
+	return c.MaxPlayerCount
+}
+
+// TeamBalancing defines the strength of a player used to balance teams
+type TeamBalancing string
+
+const (
+	// Teams are balanced by the total level of the players
+	TeamBalancing_Level TeamBalancing = "level"
+
+	// Teams are balanced by the total rating of the players
+	TeamBalancing_Rating TeamBalancing = "rating"
+)
+
 // CompetitionStateChangeNotification is a notification about a competition state change
 type CompetitionStateChangeNotification bool
 
 // Creates new competition
 func NewCompetition(id int, config CompetitionConfig, playerLevelRange CompetitionLevelRange) Competition {
-	return newCompetition(id, config, playerLevelRange)
+	return newCompetition(id, config, playerLevelRange, "")
+}
+
+// Creates new competition bound to a region
+func NewCompetitionInRegion(id int, config CompetitionConfig, playerLevelRange CompetitionLevelRange, region string) Competition {
+	return newCompetition(id, config, playerLevelRange, region)
 }
 
-func (c *competition) AddPlayer(playerData model.PlayerData) {
-	c.addPlayer(playerData)
+func (c *competition) AddPlayer(playerData model.PlayerData) error {
+	return c.addParty(playerData, []model.PlayerData{playerData})
+}
+
+func (c *competition) AddParty(partyData model.PlayerData, members []model.PlayerData) error {
+	return c.addParty(partyData, members)
+}
+
+func (c *competition) RemovePlayer(playerID string) error {
+	return c.removePlayer(playerID)
 }
 
 func (c *competition) IsPlayerLevelMatching(playerData model.PlayerData) bool {
 	return c.isPlayerLevelMatching(playerData)
 }
 
+func (c *competition) IsPlayerRatingMatching(playerData model.PlayerData, matchingConfig rating.MatchingConfig) bool {
+	return c.isPlayerRatingMatching(playerData, matchingConfig)
+}
+
+func (c *competition) GetRating() rating.Rating {
+	return c.getRating()
+}
+
+func (c *competition) GetRegion() string {
+	return c.getRegion()
+}
+
+func (c *competition) GetLevelRange() CompetitionLevelRange {
+	return c.getLevelRange()
+}
+
+func (c *competition) SetLevelRange(playerLevelRange CompetitionLevelRange) {
+	c.setLevelRange(playerLevelRange)
+}
+
+func (c *competition) SetOpenForBackfill(open bool) {
+	c.openForBackfill = open
+}
+
 func (c *competition) GetID() int {
 	return c.getID()
 }
 
+func (c *competition) GetCreatedAt() time.Time {
+	return c.createdAt
+}
+
 func (c *competition) GetNumberOfJoinedPlayers() int {
 	return c.getNumberOfJoinedPlayers()
 }
@@ -70,6 +271,22 @@ func (c *competition) GetPlayers() map[string]model.PlayerData {
 	return c.getPlayers()
 }
 
-func (c *competition) Start() {
-	c.start()
+func (c *competition) GetTeamOfPlayer(playerID string) (int, bool) {
+	return c.getTeamOfPlayer(playerID)
+}
+
+func (c *competition) Start() error {
+	return c.start()
+}
+
+func (c *competition) TransitionTo(state CompetitionState) error {
+	return c.transitionTo(state)
+}
+
+func (c *competition) GetState() CompetitionState {
+	return c.getState()
+}
+
+func (c *competition) GetStateTransitions() []StateTransition {
+	return c.getStateTransitions()
 }
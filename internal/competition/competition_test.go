@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,3 +35,137 @@ func TestAddingPlayers(t *testing.T) {
 	assert.Equal(t, 9, competition.GetNumberOfJoinedPlayers())
 
 }
+
+// TestRemovingPlayers tests the removing of players from the competition
+func TestRemovingPlayers(t *testing.T) {
+	competition := NewCompetition(
+		1,
+		CompetitionConfig{
+			MaxPlayerCount: 10,
+			MinPlayerCount: 2,
+		},
+		CompetitionLevelRange{
+			Min: 1,
+			Max: 10,
+		},
+	)
+
+	competition.AddPlayer(model.PlayerData{ID: "test", Level: 1})
+	competition.AddPlayer(model.PlayerData{ID: "test2", Level: 2})
+
+	competition.RemovePlayer("test")
+	assert.Equal(t, 1, competition.GetNumberOfJoinedPlayers())
+	assert.NotContains(t, competition.GetPlayers(), "test")
+
+	// removing a player that is not in the competition has no effect
+	assert.ErrorIs(t, competition.RemovePlayer("unknown"), ErrNotJoined)
+	assert.Equal(t, 1, competition.GetNumberOfJoinedPlayers())
+}
+
+// TestChangingLevelRange tests that the changed level range is used for level matching
+func TestChangingLevelRange(t *testing.T) {
+	competition := NewCompetition(
+		1,
+		CompetitionConfig{
+			MaxPlayerCount: 10,
+			MinPlayerCount: 2,
+		},
+		CompetitionLevelRange{
+			Min: 5,
+			Max: 10,
+		},
+	)
+
+	assert.False(t, competition.IsPlayerLevelMatching(model.PlayerData{ID: "test", Level: 12}))
+
+	competition.SetLevelRange(CompetitionLevelRange{Min: 3, Max: 12})
+
+	assert.Equal(t, CompetitionLevelRange{Min: 3, Max: 12}, competition.GetLevelRange())
+	assert.True(t, competition.IsPlayerLevelMatching(model.PlayerData{ID: "test", Level: 12}))
+	assert.True(t, competition.IsPlayerLevelMatching(model.PlayerData{ID: "test", Level: 3}))
+}
+
+// TestRatingMatching tests that the player's rating is compared with the combined rating of the competition
+func TestRatingMatching(t *testing.T) {
+	competition := NewCompetition(
+		1,
+		CompetitionConfig{
+			MaxPlayerCount: 10,
+			MinPlayerCount: 2,
+		},
+		CompetitionLevelRange{
+			Min: 1,
+			Max: 10,
+		},
+	)
+	matchingConfig := rating.MatchingConfig{Tolerance: 100}
+
+	// a competition without players accepts every rating
+	assert.True(t, competition.IsPlayerRatingMatching(model.PlayerData{ID: "test", Level: 1, Rating: 3000, RatingDeviation: 1}, matchingConfig))
+
+	competition.AddPlayer(model.PlayerData{ID: "test", Level: 1, Rating: 1400, RatingDeviation: 1})
+	competition.AddPlayer(model.PlayerData{ID: "test2", Level: 2, Rating: 1600, RatingDeviation: 1})
+
+	assert.Equal(t, 1500.0, competition.GetRating().Value)
+	assert.True(t, competition.IsPlayerRatingMatching(model.PlayerData{ID: "test3", Level: 3, Rating: 1590, RatingDeviation: 1}, matchingConfig))
+	assert.False(t, competition.IsPlayerRatingMatching(model.PlayerData{ID: "test3", Level: 3, Rating: 1700, RatingDeviation: 1}, matchingConfig))
+}
+
+// TestAddingPlayersChecksInvariants tests that players breaking the rules of the competition are rejected
+func TestAddingPlayersChecksInvariants(t *testing.T) {
+	competition := NewCompetition(
+		1,
+		CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		CompetitionLevelRange{
+			Min: 1,
+			Max: 10,
+		},
+	)
+
+	assert.NoError(t, competition.AddPlayer(model.PlayerData{ID: "test", Level: 1}))
+	assert.ErrorIs(t, competition.AddPlayer(model.PlayerData{ID: "test", Level: 2}), ErrAlreadyJoined)
+	assert.Equal(t, 1, competition.GetPlayers()["test"].Level, "joined player should not be overwritten")
+	assert.ErrorIs(t, competition.AddPlayer(model.PlayerData{ID: "test2", Level: 11}), ErrLevelOutOfRange)
+
+	// a party is matched with its own level and joins only if all members fit
+	assert.ErrorIs(t, competition.AddParty(model.PlayerData{ID: "party", Level: 5}, []model.PlayerData{
+		{ID: "test2", Level: 2},
+		{ID: "test3", Level: 12},
+		{ID: "test4", Level: 5},
+	}), ErrCompetitionFull)
+	assert.Equal(t, 1, competition.GetNumberOfJoinedPlayers())
+	assert.NoError(t, competition.AddParty(model.PlayerData{ID: "party", Level: 5}, []model.PlayerData{
+		{ID: "test2", Level: 2},
+		{ID: "test3", Level: 12},
+	}))
+	assert.ErrorIs(t, competition.AddPlayer(model.PlayerData{ID: "test4", Level: 4}), ErrCompetitionFull)
+
+	assert.NoError(t, competition.TransitionTo(CompetitionState_Matchmaking))
+	assert.NoError(t, competition.RemovePlayer("test3"))
+	assert.NoError(t, competition.TransitionTo(CompetitionState_ReadyCheck))
+	assert.ErrorIs(t, competition.AddPlayer(model.PlayerData{ID: "test4", Level: 4}), ErrNotAcceptingPlayers)
+
+	assert.NoError(t, competition.TransitionTo(CompetitionState_Aborted))
+	assert.ErrorIs(t, competition.AddPlayer(model.PlayerData{ID: "test4", Level: 4}), ErrNotAcceptingPlayers)
+	assert.ErrorIs(t, competition.RemovePlayer("test"), ErrCompetitionClosed)
+}
+
+func TestAddingPlayersAfterStartRequiresBackfill(t *testing.T) {
+	competition := NewCompetition(1, CompetitionConfig{MaxPlayerCount: 3, MinPlayerCount: 2}, CompetitionLevelRange{Min: 1, Max: 10})
+	assert.NoError(t, competition.AddPlayer(model.PlayerData{ID: "test1", Level: 5}))
+	assert.NoError(t, competition.AddPlayer(model.PlayerData{ID: "test2", Level: 5}))
+	assert.NoError(t, competition.TransitionTo(CompetitionState_Matchmaking))
+	assert.NoError(t, competition.Start())
+
+	assert.ErrorIs(t, competition.AddPlayer(model.PlayerData{ID: "test3", Level: 5}), ErrNotAcceptingPlayers)
+
+	competition.SetOpenForBackfill(true)
+	assert.NoError(t, competition.AddPlayer(model.PlayerData{ID: "test3", Level: 5}))
+
+	assert.NoError(t, competition.RemovePlayer("test3"))
+	competition.SetOpenForBackfill(false)
+	assert.ErrorIs(t, competition.AddPlayer(model.PlayerData{ID: "test3", Level: 5}), ErrNotAcceptingPlayers)
+}
@@ -0,0 +1,47 @@
+package competition
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// allowedStateTransitions maps every state to the states that can follow it
+// A competition in a ready check goes back to matchmaking when it lost players that must be replaced
+var allowedStateTransitions = map[CompetitionState][]CompetitionState{
+	CompetitionState_Created:     {CompetitionState_Matchmaking, CompetitionState_Aborted},
+	CompetitionState_Matchmaking: {CompetitionState_ReadyCheck, CompetitionState_InProgress, CompetitionState_Aborted},
+	CompetitionState_ReadyCheck:  {CompetitionState_Matchmaking, CompetitionState_InProgress, CompetitionState_Aborted},
+	CompetitionState_InProgress:  {CompetitionState_Finished, CompetitionState_Aborted},
+}
+
+func isStateTransitionAllowed(from CompetitionState, to CompetitionState) bool {
+	return slices.Contains(allowedStateTransitions[from], to)
+}
+
+func (c *competition) transitionTo(state CompetitionState) error {
+	if !isStateTransitionAllowed(c.state, state) {
+		return fmt.Errorf("%w: competition %d from %s to %s", ErrIllegalStateTransition, c.id, c.state, state)
+	}
+
+	c.stateTransitions = append(c.stateTransitions, StateTransition{
+		From: c.state,
+		To:   state,
+		At:   c.clock.Now(),
+	})
+	c.state = state
+	slog.Debug("Competition state changed", "id", c.id, "from", c.stateTransitions[len(c.stateTransitions)-1].From, "to", state)
+
+	if state == CompetitionState_InProgress {
+		c.onStart()
+	}
+	return nil
+}
+
+func (c *competition) getState() CompetitionState {
+	return c.state
+}
+
+func (c *competition) getStateTransitions() []StateTransition {
+	return slices.Clone(c.stateTransitions)
+}
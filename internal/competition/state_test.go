@@ -0,0 +1,54 @@
+package competition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newStateTestCompetition() Competition {
+	return NewCompetition(1, CompetitionConfig{MaxPlayerCount: 2, MinPlayerCount: 2}, CompetitionLevelRange{Min: 1, Max: 10})
+}
+
+func TestStateLifecycle(t *testing.T) {
+	competition := newStateTestCompetition()
+	assert.Equal(t, CompetitionState_Created, competition.GetState())
+	assert.Empty(t, competition.GetStateTransitions())
+
+	assert.NoError(t, competition.TransitionTo(CompetitionState_Matchmaking))
+	assert.NoError(t, competition.TransitionTo(CompetitionState_ReadyCheck))
+	// players lost during the ready check are replaced in matchmaking
+	assert.NoError(t, competition.TransitionTo(CompetitionState_Matchmaking))
+	assert.NoError(t, competition.TransitionTo(CompetitionState_ReadyCheck))
+	assert.NoError(t, competition.Start())
+	assert.NoError(t, competition.TransitionTo(CompetitionState_Finished))
+	assert.Equal(t, CompetitionState_Finished, competition.GetState())
+
+	transitions := competition.GetStateTransitions()
+	assert.Len(t, transitions, 6)
+	assert.Equal(t, StateTransition{From: CompetitionState_Created, To: CompetitionState_Matchmaking, At: transitions[0].At}, transitions[0])
+	assert.Equal(t, CompetitionState_ReadyCheck, transitions[4].From)
+	assert.Equal(t, CompetitionState_InProgress, transitions[4].To)
+	for i := 1; i < len(transitions); i++ {
+		assert.Equal(t, transitions[i-1].To, transitions[i].From)
+		assert.False(t, transitions[i].At.Before(transitions[i-1].At))
+	}
+}
+
+func TestIllegalStateTransitionsAreRejected(t *testing.T) {
+	competition := newStateTestCompetition()
+
+	assert.ErrorIs(t, competition.Start(), ErrIllegalStateTransition)
+	assert.ErrorIs(t, competition.TransitionTo(CompetitionState_Finished), ErrIllegalStateTransition)
+	assert.Equal(t, CompetitionState_Created, competition.GetState())
+
+	assert.NoError(t, competition.TransitionTo(CompetitionState_Matchmaking))
+	assert.ErrorIs(t, competition.TransitionTo(CompetitionState_Matchmaking), ErrIllegalStateTransition)
+	assert.NoError(t, competition.TransitionTo(CompetitionState_Aborted))
+
+	// aborted and finished competitions are final
+	for _, state := range []CompetitionState{CompetitionState_Created, CompetitionState_Matchmaking, CompetitionState_InProgress, CompetitionState_Finished} {
+		assert.ErrorIs(t, competition.TransitionTo(state), ErrIllegalStateTransition)
+	}
+	assert.Len(t, competition.GetStateTransitions(), 2)
+}
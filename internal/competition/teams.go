@@ -0,0 +1,135 @@
+package competition
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
+)
+
+// getPlayerStrength returns the value the teams are balanced with
+func (c *competition) getPlayerStrength(playerData model.PlayerData) float64 {
+	if c.config.TeamBalancing == TeamBalancing_Rating {
+		return rating.OfPlayer(playerData).Value
+	}
+	return float64(playerData.Level)
+}
+
+func (c *competition) isTeamCompetition() bool {
+	return c.config.TeamCount > 1
+}
+
+// assignTeams splits the players into balanced teams and stores the team of each player
+func (c *competition) assignTeams() {
+	teams := balanceTeams(slices.Collect(maps.Values(c.players)), c.config.TeamCount, c.getPlayerStrength)
+
+	c.teams = make(map[string]int, len(c.players))
+	for teamIndex, team := range teams {
+		for _, playerData := range team {
+			c.teams[playerData.ID] = teamIndex + 1
+		}
+	}
+}
+
+// findTeamForNewPlayer returns the team a player joining a started competition is assigned to
+// The team with the fewest players is chosen, and ties are broken by the lowest total strength
+// and then by the lowest team number
+// @return the team number starting from 1
+func (c *competition) findTeamForNewPlayer() int {
+	sizes := make([]int, c.config.TeamCount)
+	totals := make([]float64, c.config.TeamCount)
+	for playerID, team := range c.teams {
+		sizes[team-1]++
+		totals[team-1] += c.getPlayerStrength(c.players[playerID])
+	}
+
+	bestTeam := 0
+	for team := 1; team < c.config.TeamCount; team++ {
+		if sizes[team] < sizes[bestTeam] || (sizes[team] == sizes[bestTeam] && totals[team] < totals[bestTeam]) {
+			bestTeam = team
+		}
+	}
+	return bestTeam + 1
+}
+
+// balanceTeams splits players into teams whose total strengths are as close as possible
+// Team sizes differ by at most one player. The players are first assigned greedily from the strongest,
+// always to the weakest team with room left, and then players are swapped between teams as long as
+// a swap brings the team totals closer together
+// When the players do not split evenly, the teams are padded with empty slots of zero strength,
+// so the short teams can get the stronger players
+// @param players the players to split
+// @param teamCount the number of teams
+// @param strength returns the strength of a player
+// @return the teams
+func balanceTeams(players []model.PlayerData, teamCount int, strength func(model.PlayerData) float64) [][]model.PlayerData {
+	slices.SortFunc(players, func(a, b model.PlayerData) int {
+		if order := cmp.Compare(strength(b), strength(a)); order != 0 {
+			return order
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	teamSize := (len(players) + teamCount - 1) / teamCount
+	slots := make([]teamSlot, teamCount*teamSize)
+	for i, playerData := range players {
+		slots[i] = teamSlot{playerData: playerData, strength: strength(playerData), taken: true}
+	}
+
+	teams := make([][]teamSlot, teamCount)
+	totals := make([]float64, teamCount)
+	for _, slot := range slots {
+		weakestTeam := -1
+		for i := range teams {
+			if len(teams[i]) < teamSize && (weakestTeam == -1 || totals[i] < totals[weakestTeam]) {
+				weakestTeam = i
+			}
+		}
+		teams[weakestTeam] = append(teams[weakestTeam], slot)
+		totals[weakestTeam] += slot.strength
+	}
+
+	for improveTeamsBySwapping(teams, totals) {
+	}
+
+	balancedTeams := make([][]model.PlayerData, teamCount)
+	for i, team := range teams {
+		for _, slot := range team {
+			if slot.taken {
+				balancedTeams[i] = append(balancedTeams[i], slot.playerData)
+			}
+		}
+	}
+	return balancedTeams
+}
+
+// teamSlot is a place in a team, either taken by a player or empty
+type teamSlot struct {
+	playerData model.PlayerData
+	strength   float64
+	taken      bool
+}
+
+// improveTeamsBySwapping swaps the first pair of slots whose swap brings two team totals closer together
+// @return true if slots were swapped
+func improveTeamsBySwapping(teams [][]teamSlot, totals []float64) bool {
+	for a := range teams {
+		for b := a + 1; b < len(teams); b++ {
+			for i, slotA := range teams[a] {
+				for j, slotB := range teams[b] {
+					difference := slotB.strength - slotA.strength
+					newTotalA := totals[a] + difference
+					newTotalB := totals[b] - difference
+					if newTotalA*newTotalA+newTotalB*newTotalB < totals[a]*totals[a]+totals[b]*totals[b] {
+						teams[a][i], teams[b][j] = slotB, slotA
+						totals[a], totals[b] = newTotalA, newTotalB
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package competition
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func getLevel(playerData model.PlayerData) float64 {
+	return float64(playerData.Level)
+}
+
+func getTeamTotals(teams [][]model.PlayerData) []float64 {
+	totals := make([]float64, len(teams))
+	for i, team := range teams {
+		for _, playerData := range team {
+			totals[i] += getLevel(playerData)
+		}
+	}
+	return totals
+}
+
+// TestBalanceTeams tests that the teams are balanced where greedy assignment alone is not enough
+func TestBalanceTeams(t *testing.T) {
+	players := []model.PlayerData{}
+	for i, level := range []int{8, 7, 6, 5, 4, 3, 2, 1} {
+		players = append(players, model.PlayerData{ID: fmt.Sprintf("test%d", i), Level: level})
+	}
+
+	teams := balanceTeams(players, 2, getLevel)
+
+	assert.Len(t, teams, 2)
+	assert.Len(t, teams[0], 4)
+	assert.Len(t, teams[1], 4)
+	assert.Equal(t, []float64{18, 18}, getTeamTotals(teams))
+}
+
+// TestBalanceTeams_UnevenPlayerCount tests that team sizes differ by at most one player
+func TestBalanceTeams_UnevenPlayerCount(t *testing.T) {
+	players := []model.PlayerData{
+		{ID: "test1", Level: 10},
+		{ID: "test2", Level: 1},
+		{ID: "test3", Level: 1},
+		{ID: "test4", Level: 1},
+		{ID: "test5", Level: 1},
+	}
+
+	teams := balanceTeams(players, 2, getLevel)
+
+	assert.ElementsMatch(t, []int{2, 3}, []int{len(teams[0]), len(teams[1])})
+	assert.ElementsMatch(t, []float64{11, 3}, getTeamTotals(teams))
+}
+
+// TestStartAssignsTeams tests that players of a team competition get a team when the competition starts
+func TestStartAssignsTeams(t *testing.T) {
+	competition := NewCompetition(
+		1,
+		CompetitionConfig{
+			MinPlayerCount: 2,
+			TeamCount:      2,
+			TeamSize:       2,
+		},
+		CompetitionLevelRange{
+			Min: 1,
+			Max: 10,
+		},
+	)
+	competition.AddPlayer(model.PlayerData{ID: "test1", Level: 10})
+	competition.AddPlayer(model.PlayerData{ID: "test2", Level: 9})
+	competition.AddPlayer(model.PlayerData{ID: "test3", Level: 2})
+	competition.AddPlayer(model.PlayerData{ID: "test4", Level: 1})
+
+	_, assigned := competition.GetTeamOfPlayer("test1")
+	assert.False(t, assigned)
+
+	assert.NoError(t, competition.TransitionTo(CompetitionState_Matchmaking))
+	assert.NoError(t, competition.Start())
+
+	teamOfStrongest, _ := competition.GetTeamOfPlayer("test1")
+	teamOfWeakest, _ := competition.GetTeamOfPlayer("test4")
+	teamOfSecondStrongest, _ := competition.GetTeamOfPlayer("test2")
+	assert.Equal(t, teamOfStrongest, teamOfWeakest)
+	assert.NotEqual(t, teamOfStrongest, teamOfSecondStrongest)
+	assert.Equal(t, 4, CompetitionConfig{MaxPlayerCount: 10, TeamCount: 2, TeamSize: 2}.GetMaxPlayerCount())
+}
+
+// TestPlayerAddedAfterStartFillsOpenTeam tests that a player joining a started team competition replaces a lost player
+func TestPlayerAddedAfterStartFillsOpenTeam(t *testing.T) {
+	competition := NewCompetition(1, CompetitionConfig{MinPlayerCount: 2, TeamCount: 2, TeamSize: 2}, CompetitionLevelRange{Min: 1, Max: 10})
+	competition.AddPlayer(model.PlayerData{ID: "test1", Level: 10})
+	competition.AddPlayer(model.PlayerData{ID: "test2", Level: 9})
+	competition.AddPlayer(model.PlayerData{ID: "test3", Level: 2})
+	competition.AddPlayer(model.PlayerData{ID: "test4", Level: 1})
+	assert.NoError(t, competition.TransitionTo(CompetitionState_Matchmaking))
+	assert.NoError(t, competition.Start())
+	competition.SetOpenForBackfill(true)
+
+	teamOfLeavingPlayer, _ := competition.GetTeamOfPlayer("test2")
+	competition.RemovePlayer("test2")
+	_, assigned := competition.GetTeamOfPlayer("test2")
+	assert.False(t, assigned)
+
+	assert.NoError(t, competition.AddPlayer(model.PlayerData{ID: "test5", Level: 8}))
+	teamOfNewPlayer, assigned := competition.GetTeamOfPlayer("test5")
+	assert.True(t, assigned)
+	assert.Equal(t, teamOfLeavingPlayer, teamOfNewPlayer)
+}
@@ -0,0 +1,132 @@
+package matchmaking
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+)
+
+func (m *matchmakingService) isBackfillEnabled() bool {
+	return m.config.Backfill.Window > 0
+}
+
+func (m *matchmakingService) reportPlayerDropped(competitionID int, playerID string) {
+	m.sendCommand(playerDropCommand{competitionID: competitionID, playerID: playerID})
+}
+
+// isBackfillingCompetition checks if a competition has started and is kept for backfill
+func (m *matchmakingService) isBackfillingCompetition(competition competition.Competition) bool {
+	_, backfilling := m.backfillCompetitions[competition.GetID()]
+	return backfilling
+}
+
+// getCompetitionData returns the data of a competition waiting for players or kept for backfill
+func (m *matchmakingService) getCompetitionData(competitionID int) competitionData {
+	if competitionData, exists := m.competitionsInMatchmaking[competitionID]; exists {
+		return competitionData
+	}
+	return m.backfillCompetitions[competitionID]
+}
+
+// registerBackfillCompetition keeps a started competition for the backfill window
+// The competition is advertised to matchmaking whenever it has open slots
+// @param competitionData the data of the started competition
+func (m *matchmakingService) registerBackfillCompetition(competitionData competitionData) {
+	backfillCancel := make(chan struct{})
+	competitionData.timeoutCancel = backfillCancel
+	m.backfillCompetitions[competitionData.GetID()] = competitionData
+	competitionData.SetOpenForBackfill(true)
+	m.updateBackfillAdvertisement(competitionData.Competition)
+
+	window := m.clock.After(m.config.Backfill.Window)
+	m.startGoroutine(func() {
+		m.startBackfillWindowForCompetition(competitionData.Competition, window, backfillCancel)
+	})
+}
+
+func (m *matchmakingService) startBackfillWindowForCompetition(competition competition.Competition, window <-chan time.Time, backfillCancel <-chan struct{}) {
+	select {
+	case <-window:
+		m.sendCommand(stateChangeNotification{
+			origin:      matchmakingStateChangeOrigin_BackfillEnd,
+			competition: competition,
+		})
+	case <-backfillCancel:
+		return
+	}
+}
+
+// updateBackfillAdvertisement advertises a backfill competition to matchmaking while it has open slots
+// and withdraws it once it is full
+func (m *matchmakingService) updateBackfillAdvertisement(competition competition.Competition) {
+	if competition.GetNumberOfJoinedPlayers() < m.config.CompetitionConfig.GetMaxPlayerCount() {
+		m.competitionLevelIndex.update(competition)
+		return
+	}
+	m.competitionLevelIndex.remove(competition)
+}
+
+// handlePlayerDropped removes a player that dropped out of a started competition
+// The open slot is advertised to matchmaking while the competition is in its backfill window
+// @param competitionID the id of the started competition
+// @param playerID the id of the player that dropped
+func (m *matchmakingService) handlePlayerDropped(competitionID int, playerID string) {
+	competitionData, backfilling := m.backfillCompetitions[competitionID]
+	if !backfilling {
+		slog.Info("Competition is not open for backfill. Ignoring dropped player", "id", competitionID, "player_id", playerID)
+		return
+	}
+	if err := competitionData.RemovePlayer(playerID); err != nil {
+		slog.Info("Player cannot be removed from competition. Ignoring dropped player", "id", competitionID, "player_id", playerID, "error", err)
+		return
+	}
+	slog.Info("Player dropped from started competition", "id", competitionID, "player_id", playerID)
+	m.updateBackfillAdvertisement(competitionData.Competition)
+}
+
+// registerBackfilledPlayer completes the matchmaking of a player added to an open slot of a started competition
+// The player is notified with the team and region of the competition and leaves matchmaking
+// @param playerData the added player
+// @param backfillCompetition the started competition
+func (m *matchmakingService) registerBackfilledPlayer(playerData model.PlayerData, backfillCompetition competition.Competition) {
+	team, _ := backfillCompetition.GetTeamOfPlayer(playerData.ID)
+	m.publishEvent(PlayerPlacedEvent{
+		Time:          m.clock.Now(),
+		PlayerID:      playerData.ID,
+		CompetitionID: backfillCompetition.GetID(),
+		Backfill:      true,
+	})
+
+	m.sendNotificationToPlayer(playerData.ID, MatchMakingNotification{
+		CompetitionID: backfillCompetition.GetID(),
+		State:         State_Backfill,
+		Team:          team,
+		Region:        backfillCompetition.GetRegion(),
+	})
+	m.unregisterPlayerFromMatchmakingStage(playerData.ID)
+
+	slog.Info("Player backfilled competition", "id", backfillCompetition.GetID(), "player_id", playerData.ID)
+}
+
+// stopBackfill ends the backfill window of a competition before the window has passed
+// Has no effect if the competition is not in its backfill window
+func (m *matchmakingService) stopBackfill(competition competition.Competition) {
+	if competitionData, backfilling := m.backfillCompetitions[competition.GetID()]; backfilling {
+		close(competitionData.timeoutCancel)
+		m.endBackfillWindow(competition)
+	}
+}
+
+// endBackfillWindow stops advertising a started competition and forgets it
+// @param competition the competition whose backfill window ended
+func (m *matchmakingService) endBackfillWindow(competition competition.Competition) {
+	if !m.isBackfillingCompetition(competition) {
+		return
+	}
+	delete(m.backfillCompetitions, competition.GetID())
+	competition.SetOpenForBackfill(false)
+	m.competitionLevelIndex.remove(competition)
+	slog.Info("Backfill window ended", "id", competition.GetID())
+}
@@ -0,0 +1,139 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchmakingService_DroppedPlayerIsReplaced(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     300 * time.Millisecond,
+		LevelMatchingTolerance: 3,
+		Backfill:               BackfillConfig{Window: time.Second},
+		Clock:                  clock.NewFakeClock(time.Now()),
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-second).State)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-first)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-second)
+
+	// a full started competition is not advertised
+	waiting := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-waiting)
+
+	matchmakingService.ReportPlayerDropped(1, "test_user_2")
+
+	// players outside the level range of the started competition are not backfilled
+	outOfRange := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_4", Level: 20})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 3, State: State_WaitingForPlayers}, <-outOfRange)
+
+	replacement := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_5", Level: 6})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Backfill}, <-replacement)
+	_, ok := <-replacement
+	assert.False(t, ok, "backfilled player should leave matchmaking")
+
+	// the competition is full again
+	next := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_6", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-next)
+}
+
+func TestMatchmakingService_CompetitionStartedWithOpenSlotsIsBackfilled(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 4,
+			MinPlayerCount: 2,
+			TeamCount:      2,
+			TeamSize:       2,
+		},
+		MatchmakingTimeout:     300 * time.Millisecond,
+		LevelMatchingTolerance: 3,
+		Backfill:               BackfillConfig{Window: time.Second},
+		Clock:                  fakeClock,
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-second).State)
+
+	// the competition starts on timeout with the minimum number of players
+	fakeClock.Advance(300 * time.Millisecond)
+	started := <-first
+	assert.Equal(t, State_Started, started.State)
+	assert.Equal(t, State_Started, (<-second).State)
+
+	late := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5})
+	backfilled := <-late
+	assert.Equal(t, State_Backfill, backfilled.State)
+	assert.Equal(t, 1, backfilled.CompetitionID)
+	assert.NotZero(t, backfilled.Team)
+}
+
+func TestMatchmakingService_CompetitionIsNotBackfilledAfterWindow(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     300 * time.Millisecond,
+		LevelMatchingTolerance: 3,
+		Backfill:               BackfillConfig{Window: 200 * time.Millisecond},
+		Clock:                  fakeClock,
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-second).State)
+	assert.Equal(t, State_Started, (<-first).State)
+
+	fakeClock.Advance(200 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		numberOfBackfillCompetitions := 0
+		inspectMatchmakingService(matchmakingService, func() {
+			numberOfBackfillCompetitions = len(matchmakingService.backfillCompetitions)
+		})
+		return numberOfBackfillCompetitions == 0
+	}, time.Second, time.Millisecond)
+	matchmakingService.ReportPlayerDropped(1, "test_user_2")
+
+	late := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-late)
+}
+
+func TestMatchmakingService_BackfillDisabledByDefault(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     300 * time.Millisecond,
+		LevelMatchingTolerance: 3,
+		Clock:                  clock.NewFakeClock(time.Now()),
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-second).State)
+	assert.Equal(t, State_Started, (<-first).State)
+
+	matchmakingService.ReportPlayerDropped(1, "test_user_2")
+
+	late := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-late)
+}
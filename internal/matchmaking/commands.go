@@ -0,0 +1,146 @@
+package matchmaking
+
+import (
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/results"
+)
+
+// matchmakingCommand is a message handled by the matchmaking loop
+// The loop is the only goroutine that reads or changes the state of the service. API calls and timers
+// never touch the state themselves, they send a command and wait on its reply channel if they need an answer
+type matchmakingCommand interface {
+	handle(m *matchmakingService)
+}
+
+// playerJoinCommand registers a joining player and places the player in a competition
+type playerJoinCommand struct {
+	playerData model.PlayerData
+	join       joinRequest
+	// reply receives ErrPlayerAlreadyInMatchmaking if the join is rejected as a duplicate
+	reply chan error
+}
+
+func (c playerJoinCommand) handle(m *matchmakingService) {
+	c.reply <- m.handlePlayerJoinRequest(c.playerData, c.join)
+}
+
+// partyJoinCommand registers the members of a joining party and places them in a competition together
+type partyJoinCommand struct {
+	members []model.PlayerData
+	reply   chan partyJoinResult
+}
+
+type partyJoinResult struct {
+	notificationChans map[string]<-chan MatchMakingNotification
+	err               error
+}
+
+func (c partyJoinCommand) handle(m *matchmakingService) {
+	notificationChans, err := m.handlePartyJoinRequest(c.members)
+	c.reply <- partyJoinResult{notificationChans: notificationChans, err: err}
+}
+
+// leaveCommand removes a player from matchmaking
+type leaveCommand struct {
+	playerID string
+}
+
+func (c leaveCommand) handle(m *matchmakingService) {
+	m.handlePlayerLeavingMatchmaking(c.playerID)
+}
+
+// detachListenerCommand stops sending a player's notifications to one of the player's connections
+type detachListenerCommand struct {
+	playerID string
+	listener <-chan MatchMakingNotification
+}
+
+func (c detachListenerCommand) handle(m *matchmakingService) {
+	m.handleListenerDetach(c.playerID, c.listener)
+}
+
+// abandonedPlayerCommand removes a player that no connection listens to anymore
+type abandonedPlayerCommand struct {
+	playerID string
+}
+
+func (c abandonedPlayerCommand) handle(m *matchmakingService) {
+	m.handleAbandonedPlayer(c.playerID)
+}
+
+// readyConfirmCommand confirms the ready check of a player
+type readyConfirmCommand struct {
+	playerID string
+}
+
+func (c readyConfirmCommand) handle(m *matchmakingService) {
+	m.handleReadyConfirmation(c.playerID)
+}
+
+// playerDropCommand removes a player that dropped out of a started competition
+type playerDropCommand struct {
+	competitionID int
+	playerID      string
+}
+
+func (c playerDropCommand) handle(m *matchmakingService) {
+	m.handlePlayerDropped(c.competitionID, c.playerID)
+}
+
+// resultReportCommand reports the result of a competition in progress
+type resultReportCommand struct {
+	competitionID int
+	playerResults []results.PlayerResult
+	// reply receives an error if the result is rejected
+	reply chan error
+}
+
+func (c resultReportCommand) handle(m *matchmakingService) {
+	c.reply <- m.handleResultReport(c.competitionID, c.playerResults)
+}
+
+// playerStatusCommand looks up the status of a player in matchmaking
+type playerStatusCommand struct {
+	playerID string
+	reply    chan playerStatusResult
+}
+
+type playerStatusResult struct {
+	status PlayerStatus
+	err    error
+}
+
+func (c playerStatusCommand) handle(m *matchmakingService) {
+	status, err := m.handlePlayerStatusRequest(c.playerID)
+	c.reply <- playerStatusResult{status: status, err: err}
+}
+
+// subscribeCommand adds a subscriber for the events of the service
+type subscribeCommand struct {
+	subscription *subscription
+}
+
+func (c subscribeCommand) handle(m *matchmakingService) {
+	m.handleSubscribe(c.subscription)
+}
+
+// unsubscribeCommand removes a subscriber and closes its events channel
+type unsubscribeCommand struct {
+	subscription *subscription
+}
+
+func (c unsubscribeCommand) handle(m *matchmakingService) {
+	m.handleUnsubscribe(c.subscription)
+}
+
+// shutdownCommand settles the open competitions and stops the matchmaking loop
+type shutdownCommand struct{}
+
+func (c shutdownCommand) handle(m *matchmakingService) {
+	m.handleShutdown()
+}
+
+// stateChangeNotification is sent by the timers of competitions and ready checks
+func (n stateChangeNotification) handle(m *matchmakingService) {
+	m.processMatchmakingStateMutation(n)
+}
@@ -0,0 +1,104 @@
+package matchmaking
+
+import (
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+)
+
+// competitionLevelIndex indexes the competitions waiting for players by the levels their level range covers
+// Every level maps to the competitions accepting that level, so finding the competitions for a player
+// costs the number of competitions matching the player's level instead of the number of all competitions
+// Level ranges are bounded by the level matching tolerance and the widening cap, so a competition
+// is stored in a small number of level buckets
+type competitionLevelIndex struct {
+	competitionsByLevel map[int]map[int]competition.Competition
+
+	// indexedLevelRanges holds the level range each competition is currently indexed with
+	indexedLevelRanges map[int]competition.CompetitionLevelRange
+}
+
+func newCompetitionLevelIndex() *competitionLevelIndex {
+	return &competitionLevelIndex{
+		competitionsByLevel: make(map[int]map[int]competition.Competition),
+		indexedLevelRanges:  make(map[int]competition.CompetitionLevelRange),
+	}
+}
+
+// add indexes a competition with its current level range
+// @param competition the competition to index
+func (i *competitionLevelIndex) add(competition competition.Competition) {
+	levelRange := competition.GetLevelRange()
+	for level := levelRange.Min; level <= levelRange.Max; level++ {
+		i.addToLevel(level, competition)
+	}
+	i.indexedLevelRanges[competition.GetID()] = levelRange
+}
+
+// remove removes a competition from the index
+// @param competition the competition to remove
+func (i *competitionLevelIndex) remove(competition competition.Competition) {
+	levelRange, indexed := i.indexedLevelRanges[competition.GetID()]
+	if !indexed {
+		return
+	}
+	for level := levelRange.Min; level <= levelRange.Max; level++ {
+		i.removeFromLevel(level, competition.GetID())
+	}
+	delete(i.indexedLevelRanges, competition.GetID())
+}
+
+// update re-indexes a competition after its level range has changed
+// Only the levels that entered or left the level range are touched
+// @param competition the competition to re-index
+func (i *competitionLevelIndex) update(competition competition.Competition) {
+	oldLevelRange, indexed := i.indexedLevelRanges[competition.GetID()]
+	if !indexed {
+		i.add(competition)
+		return
+	}
+
+	newLevelRange := competition.GetLevelRange()
+	for level := oldLevelRange.Min; level <= oldLevelRange.Max; level++ {
+		if !isLevelInRange(level, newLevelRange) {
+			i.removeFromLevel(level, competition.GetID())
+		}
+	}
+	for level := newLevelRange.Min; level <= newLevelRange.Max; level++ {
+		if !isLevelInRange(level, oldLevelRange) {
+			i.addToLevel(level, competition)
+		}
+	}
+	i.indexedLevelRanges[competition.GetID()] = newLevelRange
+}
+
+// findCompetitionsMatchingLevel returns the indexed competitions whose level range contains the level
+// @param level the level to look up
+// @return the competitions accepting the level, in no particular order
+func (i *competitionLevelIndex) findCompetitionsMatchingLevel(level int) []competition.Competition {
+	competitions := i.competitionsByLevel[level]
+	matchingCompetitions := make([]competition.Competition, 0, len(competitions))
+	for _, competition := range competitions {
+		matchingCompetitions = append(matchingCompetitions, competition)
+	}
+	return matchingCompetitions
+}
+
+func (i *competitionLevelIndex) addToLevel(level int, competitionToAdd competition.Competition) {
+	competitions, exists := i.competitionsByLevel[level]
+	if !exists {
+		competitions = make(map[int]competition.Competition)
+		i.competitionsByLevel[level] = competitions
+	}
+	competitions[competitionToAdd.GetID()] = competitionToAdd
+}
+
+func (i *competitionLevelIndex) removeFromLevel(level int, competitionID int) {
+	competitions := i.competitionsByLevel[level]
+	delete(competitions, competitionID)
+	if len(competitions) == 0 {
+		delete(i.competitionsByLevel, level)
+	}
+}
+
+func isLevelInRange(level int, levelRange competition.CompetitionLevelRange) bool {
+	return level >= levelRange.Min && level <= levelRange.Max
+}
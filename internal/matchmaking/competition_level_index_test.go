@@ -0,0 +1,142 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func getCompetitionIDs(competitions []competition.Competition) []int {
+	ids := make([]int, len(competitions))
+	for i, competition := range competitions {
+		ids[i] = competition.GetID()
+	}
+	return ids
+}
+
+func TestCompetitionLevelIndex_FindCompetitionsMatchingLevel(t *testing.T) {
+	index := newCompetitionLevelIndex()
+	index.add(createTestCompetition(1, competition.CompetitionLevelRange{Min: 1, Max: 4}, 0))
+	index.add(createTestCompetition(2, competition.CompetitionLevelRange{Min: 3, Max: 9}, 0))
+	index.add(createTestCompetition(3, competition.CompetitionLevelRange{Min: 20, Max: 26}, 0))
+
+	assert.ElementsMatch(t, []int{1}, getCompetitionIDs(index.findCompetitionsMatchingLevel(1)))
+	assert.ElementsMatch(t, []int{1, 2}, getCompetitionIDs(index.findCompetitionsMatchingLevel(4)))
+	assert.ElementsMatch(t, []int{2}, getCompetitionIDs(index.findCompetitionsMatchingLevel(9)))
+	assert.ElementsMatch(t, []int{3}, getCompetitionIDs(index.findCompetitionsMatchingLevel(26)))
+	assert.Empty(t, index.findCompetitionsMatchingLevel(15))
+}
+
+func TestCompetitionLevelIndex_Remove(t *testing.T) {
+	index := newCompetitionLevelIndex()
+	removedCompetition := createTestCompetition(1, competition.CompetitionLevelRange{Min: 1, Max: 4}, 0)
+	index.add(removedCompetition)
+	index.add(createTestCompetition(2, competition.CompetitionLevelRange{Min: 3, Max: 9}, 0))
+
+	index.remove(removedCompetition)
+
+	assert.Empty(t, index.findCompetitionsMatchingLevel(1))
+	assert.ElementsMatch(t, []int{2}, getCompetitionIDs(index.findCompetitionsMatchingLevel(3)))
+	assert.NotContains(t, index.competitionsByLevel, 1, "empty level buckets should be dropped")
+
+	// removing a competition that is not indexed has no effect
+	index.remove(removedCompetition)
+	assert.ElementsMatch(t, []int{2}, getCompetitionIDs(index.findCompetitionsMatchingLevel(3)))
+}
+
+func TestCompetitionLevelIndex_UpdateAfterLevelRangeChange(t *testing.T) {
+	index := newCompetitionLevelIndex()
+	updatedCompetition := createTestCompetition(1, competition.CompetitionLevelRange{Min: 5, Max: 10}, 0)
+	index.add(updatedCompetition)
+
+	updatedCompetition.SetLevelRange(competition.CompetitionLevelRange{Min: 3, Max: 8})
+	index.update(updatedCompetition)
+
+	assert.ElementsMatch(t, []int{1}, getCompetitionIDs(index.findCompetitionsMatchingLevel(3)))
+	assert.ElementsMatch(t, []int{1}, getCompetitionIDs(index.findCompetitionsMatchingLevel(8)))
+	assert.Empty(t, index.findCompetitionsMatchingLevel(9))
+	assert.Empty(t, index.findCompetitionsMatchingLevel(10))
+
+	index.remove(updatedCompetition)
+	assert.Empty(t, index.competitionsByLevel)
+}
+
+func TestMatchmakingService_LevelIndexFollowsCompetitions(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+	})
+
+	firstPlayer := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 10})
+	assert.Equal(t, State_WaitingForPlayers, (<-firstPlayer).State)
+	assert.ElementsMatch(t, []int{1}, getCompetitionIDs(findCompetitionsMatchingLevel(matchmakingService, 7)))
+
+	secondPlayer := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 12})
+	assert.Equal(t, State_WaitingForPlayers, (<-secondPlayer).State)
+	assert.Equal(t, State_Started, (<-secondPlayer).State)
+
+	assert.Empty(t, findCompetitionsMatchingLevel(matchmakingService, 10))
+}
+
+// createIndexedTestCompetitions indexes competitions spread over 10000 levels
+// so that every level is accepted by a handful of competitions
+func createIndexedTestCompetitions(numberOfCompetitions int) (*competitionLevelIndex, map[int]competitionData) {
+	index := newCompetitionLevelIndex()
+	competitions := make(map[int]competitionData, numberOfCompetitions)
+	for id := 1; id <= numberOfCompetitions; id++ {
+		level := id % 10000
+		testCompetition := competition.NewCompetition(id, competition.CompetitionConfig{
+			MaxPlayerCount: 10,
+			MinPlayerCount: 2,
+		}, competition.CompetitionLevelRange{Min: level - 3, Max: level + 3})
+		index.add(testCompetition)
+		competitions[id] = competitionData{Competition: testCompetition}
+	}
+	return index, competitions
+}
+
+// BenchmarkCompetitionLevelIndex_Lookup100k measures the lookup of matching competitions with 100k open competitions
+func BenchmarkCompetitionLevelIndex_Lookup100k(b *testing.B) {
+	index, _ := createIndexedTestCompetitions(100_000)
+
+	b.ResetTimer()
+	for i := range b.N {
+		index.findCompetitionsMatchingLevel(i % 10000)
+	}
+}
+
+// BenchmarkLinearScan_Lookup100k measures the linear scan over 100k open competitions the level index replaced
+func BenchmarkLinearScan_Lookup100k(b *testing.B) {
+	_, competitions := createIndexedTestCompetitions(100_000)
+
+	b.ResetTimer()
+	for i := range b.N {
+		playerData := model.PlayerData{Level: i % 10000}
+		matchingCompetitions := []competition.Competition{}
+		for _, competitionData := range competitions {
+			if competitionData.IsPlayerLevelMatching(playerData) {
+				matchingCompetitions = append(matchingCompetitions, competitionData.Competition)
+			}
+		}
+	}
+}
+
+// BenchmarkCompetitionLevelIndex_Update100k measures widening the level range of a competition with 100k open competitions
+func BenchmarkCompetitionLevelIndex_Update100k(b *testing.B) {
+	index, competitions := createIndexedTestCompetitions(100_000)
+
+	b.ResetTimer()
+	for i := range b.N {
+		widenedCompetition := competitions[i%100_000+1].Competition
+		levelRange := widenedCompetition.GetLevelRange()
+		widenedCompetition.SetLevelRange(competition.CompetitionLevelRange{Min: levelRange.Min - 1, Max: levelRange.Max + 1})
+		index.update(widenedCompetition)
+	}
+}
@@ -0,0 +1,98 @@
+package matchmaking
+
+import (
+	"math"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+)
+
+// CompetitionSelector selects the competition a player is placed in
+type CompetitionSelector interface {
+	// SelectCompetition selects the best fitting competition for the player
+	// @param playerData the player to place
+	// @param candidates the competitions waiting for players whose level range matches the player's level
+	// @return the selected competition and true, or false if there are no candidates
+	SelectCompetition(playerData model.PlayerData, candidates []competition.Competition) (competition.Competition, bool)
+}
+
+// CompetitionScorer scores how well a competition fits a player. Higher score is a better fit
+type CompetitionScorer func(playerData model.PlayerData, competition competition.Competition) float64
+
+// ClosestLevelCentreScorer prefers competitions whose level range centre is closest to the player's level
+func ClosestLevelCentreScorer(playerData model.PlayerData, competition competition.Competition) float64 {
+	levelRange := competition.GetLevelRange()
+	levelRangeCentre := float64(levelRange.Min+levelRange.Max) / 2
+	return -math.Abs(float64(playerData.Level) - levelRangeCentre)
+}
+
+// MostFilledScorer prefers competitions with the most joined players
+func MostFilledScorer(playerData model.PlayerData, competition competition.Competition) float64 {
+	return float64(competition.GetNumberOfJoinedPlayers())
+}
+
+// OldestScorer prefers competitions that were created first
+// Creation times are compared in microseconds, which a float64 holds exactly
+func OldestScorer(playerData model.PlayerData, competition competition.Competition) float64 {
+	return -float64(competition.GetCreatedAt().UnixMicro())
+}
+
+// scoringCompetitionSelector selects the competition with the best scores
+// Scorers are compared in order and a later scorer is only used when all earlier scores are equal
+// Remaining ties are broken by the lowest competition id, so the selection does not depend on candidate order
+type scoringCompetitionSelector struct {
+	scorers []CompetitionScorer
+}
+
+// NewScoringCompetitionSelector creates a competition selector that ranks candidates with the given scorers
+// @param scorers the scorers in order of precedence
+// @return a new competition selector
+func NewScoringCompetitionSelector(scorers ...CompetitionScorer) CompetitionSelector {
+	return &scoringCompetitionSelector{scorers: scorers}
+}
+
+// NewDefaultCompetitionSelector creates the competition selector used when none is configured
+// It prefers the closest level centre and then the most filled competition
+// @return a new competition selector
+func NewDefaultCompetitionSelector() CompetitionSelector {
+	return NewScoringCompetitionSelector(ClosestLevelCentreScorer, MostFilledScorer)
+}
+
+func (s *scoringCompetitionSelector) SelectCompetition(playerData model.PlayerData, candidates []competition.Competition) (competition.Competition, bool) {
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if s.isBetterFit(playerData, candidate, best) {
+			best = candidate
+		}
+	}
+	return best, true
+}
+
+func (s *scoringCompetitionSelector) isBetterFit(playerData model.PlayerData, candidate competition.Competition, best competition.Competition) bool {
+	for _, scorer := range s.scorers {
+		candidateScore := scorer(playerData, candidate)
+		bestScore := scorer(playerData, best)
+		if candidateScore != bestScore {
+			return candidateScore > bestScore
+		}
+	}
+	return candidate.GetID() < best.GetID()
+}
+
+// LowestPingScorer prefers competitions in regions the player has the lowest ping to
+// Competitions that are not bound to a region score as if the ping was zero
+func LowestPingScorer(playerData model.PlayerData, competition competition.Competition) float64 {
+	region := competition.GetRegion()
+	if region == "" {
+		return 0
+	}
+	ping, measured := playerData.Pings[region]
+	if !measured {
+		return math.Inf(-1)
+	}
+	return -float64(ping)
+}
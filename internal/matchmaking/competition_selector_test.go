@@ -0,0 +1,124 @@
+package matchmaking
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestCompetition(id int, levelRange competition.CompetitionLevelRange, numberOfPlayers int) competition.Competition {
+	testCompetition := competition.NewCompetition(id, competition.CompetitionConfig{
+		MaxPlayerCount: 10,
+		MinPlayerCount: 2,
+	}, levelRange)
+	for i := range numberOfPlayers {
+		testCompetition.AddPlayer(model.PlayerData{ID: fmt.Sprintf("competition_%d_player_%d", id, i), Level: levelRange.Min})
+	}
+	return testCompetition
+}
+
+func TestCompetitionSelector_NoCandidates(t *testing.T) {
+	_, found := NewDefaultCompetitionSelector().SelectCompetition(model.PlayerData{ID: "test_user_1", Level: 5}, nil)
+	assert.False(t, found)
+}
+
+func TestCompetitionSelector_ClosestLevelCentre(t *testing.T) {
+	candidates := []competition.Competition{
+		createTestCompetition(1, competition.CompetitionLevelRange{Min: 1, Max: 7}, 5),
+		createTestCompetition(2, competition.CompetitionLevelRange{Min: 4, Max: 10}, 1),
+		createTestCompetition(3, competition.CompetitionLevelRange{Min: 6, Max: 12}, 3),
+	}
+
+	selected, found := NewScoringCompetitionSelector(ClosestLevelCentreScorer).SelectCompetition(model.PlayerData{ID: "test_user_1", Level: 7}, candidates)
+	assert.True(t, found)
+	assert.Equal(t, 2, selected.GetID())
+}
+
+func TestCompetitionSelector_MostFilled(t *testing.T) {
+	candidates := []competition.Competition{
+		createTestCompetition(1, competition.CompetitionLevelRange{Min: 1, Max: 7}, 2),
+		createTestCompetition(2, competition.CompetitionLevelRange{Min: 4, Max: 10}, 1),
+		createTestCompetition(3, competition.CompetitionLevelRange{Min: 6, Max: 12}, 8),
+	}
+
+	selected, found := NewScoringCompetitionSelector(MostFilledScorer).SelectCompetition(model.PlayerData{ID: "test_user_1", Level: 7}, candidates)
+	assert.True(t, found)
+	assert.Equal(t, 3, selected.GetID())
+}
+
+func TestCompetitionSelector_Oldest(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	config := competition.CompetitionConfig{MaxPlayerCount: 10, MinPlayerCount: 2, Clock: fakeClock}
+
+	// the creation time decides, not the id
+	candidates := []competition.Competition{}
+	for _, id := range []int{5, 2, 3} {
+		candidates = append(candidates, competition.NewCompetition(id, config, competition.CompetitionLevelRange{Min: 1, Max: 10}))
+		fakeClock.Advance(time.Second)
+	}
+
+	selected, found := NewScoringCompetitionSelector(OldestScorer).SelectCompetition(model.PlayerData{ID: "test_user_1", Level: 7}, candidates)
+	assert.True(t, found)
+	assert.Equal(t, 5, selected.GetID())
+}
+
+func TestCompetitionSelector_LaterScorersBreakTies(t *testing.T) {
+	candidates := []competition.Competition{
+		createTestCompetition(1, competition.CompetitionLevelRange{Min: 2, Max: 8}, 1),
+		createTestCompetition(2, competition.CompetitionLevelRange{Min: 2, Max: 8}, 4),
+		createTestCompetition(3, competition.CompetitionLevelRange{Min: 1, Max: 4}, 9),
+	}
+
+	selected, found := NewDefaultCompetitionSelector().SelectCompetition(model.PlayerData{ID: "test_user_1", Level: 5}, candidates)
+	assert.True(t, found)
+	assert.Equal(t, 2, selected.GetID())
+}
+
+func TestCompetitionSelector_TieBreakIsDeterministic(t *testing.T) {
+	candidates := []competition.Competition{
+		createTestCompetition(7, competition.CompetitionLevelRange{Min: 2, Max: 8}, 3),
+		createTestCompetition(4, competition.CompetitionLevelRange{Min: 2, Max: 8}, 3),
+		createTestCompetition(9, competition.CompetitionLevelRange{Min: 2, Max: 8}, 3),
+	}
+
+	selector := NewDefaultCompetitionSelector()
+	for range 10 {
+		selected, found := selector.SelectCompetition(model.PlayerData{ID: "test_user_1", Level: 5}, candidates)
+		assert.True(t, found)
+		assert.Equal(t, 4, selected.GetID())
+
+		// candidate order must not change the selection
+		candidates = append(candidates[1:], candidates[0])
+	}
+}
+
+// fixedCompetitionSelector always selects the same competition, even if it is not a candidate
+type fixedCompetitionSelector struct {
+	competition competition.Competition
+}
+
+func (s fixedCompetitionSelector) SelectCompetition(playerData model.PlayerData, candidates []competition.Competition) (competition.Competition, bool) {
+	return s.competition, true
+}
+
+func TestMatchmakingService_RejectedSelectionCreatesNewCompetition(t *testing.T) {
+	fullCompetition := createTestCompetition(100, competition.CompetitionLevelRange{Min: 1, Max: 10}, 10)
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 10,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		CompetitionSelector:    fixedCompetitionSelector{competition: fullCompetition},
+	})
+
+	player := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-player)
+	assert.Equal(t, 10, fullCompetition.GetNumberOfJoinedPlayers())
+}
@@ -0,0 +1,219 @@
+package matchmaking
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+)
+
+// joinRequest is the part of a player's join request that is not player data
+type joinRequest struct {
+	// idempotencyKey identifies the join across retries. Empty if the client does not retry joins
+	idempotencyKey string
+	// notificationChan is the channel of the joining connection
+	notificationChan chan MatchMakingNotification
+}
+
+// completedJoin is a join made with an idempotency key that ended with the player in a started competition
+type completedJoin struct {
+	playerID       string
+	idempotencyKey string
+	notification   MatchMakingNotification
+	completedAt    time.Time
+}
+
+// completedJoins remembers completed joins for the idempotency key TTL, so that a retry arriving after the
+// player has left matchmaking does not queue the player again
+type completedJoins struct {
+	ttl   time.Duration
+	joins map[string]completedJoin
+	// order holds the joins in the order they completed, so expired joins are found from the front
+	order []completedJoin
+}
+
+func newCompletedJoins(ttl time.Duration) *completedJoins {
+	return &completedJoins{
+		ttl:   ttl,
+		joins: make(map[string]completedJoin),
+	}
+}
+
+func (c *completedJoins) add(join completedJoin) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.removeExpired(join.completedAt)
+	c.joins[join.playerID] = join
+	c.order = append(c.order, join)
+}
+
+// get returns the completed join of a player if it was made with the given idempotency key and has not expired
+func (c *completedJoins) get(playerID string, idempotencyKey string, now time.Time) (completedJoin, bool) {
+	c.removeExpired(now)
+	join, found := c.joins[playerID]
+	if !found || idempotencyKey == "" || join.idempotencyKey != idempotencyKey {
+		return completedJoin{}, false
+	}
+	return join, true
+}
+
+func (c *completedJoins) removeExpired(now time.Time) {
+	for len(c.order) > 0 && now.Sub(c.order[0].completedAt) >= c.ttl {
+		expired := c.order[0]
+		// The player may have completed another join since, which is further back in the order
+		if c.joins[expired.playerID].completedAt.Equal(expired.completedAt) {
+			delete(c.joins, expired.playerID)
+		}
+		c.order = c.order[1:]
+	}
+}
+
+// handlePlayerJoinRequest registers a joining player and places the player in a competition
+// A player that is already in matchmaking is handled according to the duplicate join policy.
+// A retry of a join that has already completed receives the outcome of the join instead of being queued again
+// @param playerData the joining player
+// @param join the idempotency key and notification channel of the join
+// @return ErrPlayerAlreadyInMatchmaking if the join is rejected as a duplicate
+func (m *matchmakingService) handlePlayerJoinRequest(playerData model.PlayerData, join joinRequest) error {
+	if player, exists := m.playersInMatchmaking[playerData.ID]; exists {
+		return m.handleDuplicateJoin(player, join)
+	}
+
+	if completed, found := m.completedJoins.get(playerData.ID, join.idempotencyKey, m.clock.Now()); found {
+		slog.Info("Join has already completed. Replaying outcome", "id", completed.notification.CompetitionID, "player_id", playerData.ID)
+		notifyListener(join.notificationChan, completed.notification)
+		close(join.notificationChan)
+		return nil
+	}
+
+	m.playersInMatchmaking[playerData.ID] = playerInMatchmaking{
+		PlayerData:        playerData,
+		notificationChans: []chan MatchMakingNotification{join.notificationChan},
+		queuedAt:          m.clock.Now(),
+		idempotencyKey:    join.idempotencyKey,
+	}
+	m.publishEvent(PlayerQueuedEvent{
+		Time:     m.clock.Now(),
+		PlayerID: playerData.ID,
+		Level:    playerData.Level,
+	})
+	m.processMatchmakingStateMutation(stateChangeNotification{
+		origin:     matchmakingStateChangeOrigin_PlayerAdd,
+		playerData: playerData,
+	})
+	return nil
+}
+
+// handleDuplicateJoin handles a join of a player that is already in matchmaking
+// A retry of the player's join, made with the same idempotency key, takes over the session whatever the policy is,
+// because the client retries only when it has lost the connection of the original join
+// The player stays in matchmaking with the original queue time and competition
+func (m *matchmakingService) handleDuplicateJoin(player playerInMatchmaking, join joinRequest) error {
+	if join.idempotencyKey != "" && join.idempotencyKey == player.idempotencyKey {
+		slog.Info("Player retried join. Taking over session", "player_id", player.ID)
+		m.replaceSession(player, join)
+		return nil
+	}
+
+	switch m.config.DuplicateJoin.Policy {
+	case DuplicateJoinPolicy_TakeOver:
+		slog.Info("Player joined again. Taking over session", "player_id", player.ID)
+		m.replaceSession(player, join)
+	case DuplicateJoinPolicy_Attach:
+		slog.Info("Player joined again. Attaching listener", "player_id", player.ID, "listeners", len(player.notificationChans)+1)
+		m.attachListener(player, join)
+	default:
+		slog.Info("Player is already in matchmaking. Rejecting join", "player_id", player.ID)
+		return fmt.Errorf("%w: %s", ErrPlayerAlreadyInMatchmaking, player.ID)
+	}
+	return nil
+}
+
+// replaceSession moves the notifications of a player to the connection of a new join
+// The connections listening so far receive a session replaced notification and their channels are closed
+// A connection whose buffer is full misses the notification, but its channel is closed all the same
+func (m *matchmakingService) replaceSession(player playerInMatchmaking, join joinRequest) {
+	for _, notificationChan := range player.notificationChans {
+		replaced := notifyListener(notificationChan, MatchMakingNotification{
+			CompetitionID: m.competitionIDsOfPlayers[player.ID],
+			State:         State_SessionReplaced,
+		})
+		if !replaced {
+			slog.Warn("Listener is not reading notifications. Closing replaced session", "player_id", player.ID)
+		}
+		close(notificationChan)
+	}
+
+	player.notificationChans = []chan MatchMakingNotification{join.notificationChan}
+	player.idempotencyKey = join.idempotencyKey
+	m.playersInMatchmaking[player.ID] = player
+	m.replayLastNotification(player, join.notificationChan)
+}
+
+// attachListener adds the connection of a new join to the connections listening to a player's notifications
+func (m *matchmakingService) attachListener(player playerInMatchmaking, join joinRequest) {
+	player.notificationChans = append(slices.Clip(player.notificationChans), join.notificationChan)
+	m.playersInMatchmaking[player.ID] = player
+	m.replayLastNotification(player, join.notificationChan)
+}
+
+// replayLastNotification tells a new connection where the player is in matchmaking
+// Has no effect if the player has not received any notification yet
+func (m *matchmakingService) replayLastNotification(player playerInMatchmaking, notificationChan chan MatchMakingNotification) {
+	if player.lastNotification != nil {
+		notifyListener(notificationChan, *player.lastNotification)
+	}
+}
+
+func (m *matchmakingService) detachListener(playerID string, listener <-chan MatchMakingNotification) {
+	m.sendCommand(detachListenerCommand{playerID: playerID, listener: listener})
+}
+
+// handleListenerDetach stops sending a player's notifications to a connection and closes its channel
+// The player leaves matchmaking when the last listening connection is detached
+// @param playerID the id of the player
+// @param listener the notification channel of the connection
+func (m *matchmakingService) handleListenerDetach(playerID string, listener <-chan MatchMakingNotification) {
+	player, exists := m.playersInMatchmaking[playerID]
+	if !exists {
+		slog.Info("Player is not in matchmaking. Ignoring detached listener", "player_id", playerID)
+		return
+	}
+	index := slices.IndexFunc(player.notificationChans, func(notificationChan chan MatchMakingNotification) bool {
+		return notificationChan == listener
+	})
+	if index < 0 {
+		slog.Info("Listener is not attached to player. Ignoring detached listener", "player_id", playerID)
+		return
+	}
+	if len(player.notificationChans) == 1 {
+		m.handlePlayerLeavingMatchmaking(playerID)
+		return
+	}
+
+	close(player.notificationChans[index])
+	player.notificationChans = slices.Delete(slices.Clone(player.notificationChans), index, index+1)
+	m.playersInMatchmaking[playerID] = player
+	slog.Info("Detached listener from player", "player_id", playerID, "listeners", len(player.notificationChans))
+}
+
+// recordCompletedJoin remembers the outcome of a player's join made with an idempotency key
+// Only joins that placed the player in a started competition are remembered. A retry of a join that was cancelled
+// or aborted joins matchmaking again
+func (m *matchmakingService) recordCompletedJoin(player playerInMatchmaking) {
+	if player.idempotencyKey == "" || player.lastNotification == nil {
+		return
+	}
+	if state := player.lastNotification.State; state != State_Started && state != State_Backfill {
+		return
+	}
+	m.completedJoins.add(completedJoin{
+		playerID:       player.ID,
+		idempotencyKey: player.idempotencyKey,
+		notification:   *player.lastNotification,
+		completedAt:    m.clock.Now(),
+	})
+}
@@ -0,0 +1,212 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchmakingService_DuplicateJoinIsRejectedByDefault(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-first)
+
+	duplicate, err := matchmakingService.HandlePlayerJoin(model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.ErrorIs(t, err, ErrPlayerAlreadyInMatchmaking)
+	assert.Nil(t, duplicate)
+
+	// the first join is not affected
+	joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-first)
+}
+
+func TestMatchmakingService_DuplicateJoinTakesOverSession(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		DuplicateJoin:          DuplicateJoinConfig{Policy: DuplicateJoinPolicy_TakeOver},
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-first)
+
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_SessionReplaced}, <-first)
+	_, ok := <-first
+	assert.False(t, ok, "notification channel of the replaced session should be closed")
+
+	// the new session learns where the player is waiting and receives the following notifications
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-second)
+	joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-second)
+}
+
+func TestMatchmakingService_DuplicateJoinTakesOverStalledSession(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		DuplicateJoin:          DuplicateJoinConfig{Policy: DuplicateJoinPolicy_TakeOver},
+	})
+
+	// the first session never reads its notifications, so its buffer is full
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	inspectMatchmakingService(matchmakingService, func() {
+		for range notificationChannelBufferSize - 1 {
+			matchmakingService.sendNotificationToPlayer("test_user_1", MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers})
+		}
+	})
+
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-second)
+
+	// the stalled session misses the session replaced notification, but its channel is closed
+	for range notificationChannelBufferSize {
+		assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	}
+	_, ok := <-first
+	assert.False(t, ok, "notification channel of the replaced session should be closed")
+}
+
+func TestMatchmakingService_DuplicateJoinAttachesListener(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		DuplicateJoin:          DuplicateJoinConfig{Policy: DuplicateJoinPolicy_Attach},
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-first)
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-second)
+	third := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-third)
+
+	// a detached listener does not take the player out of matchmaking
+	matchmakingService.DetachListener("test_user_1", third)
+	_, ok := <-third
+	assert.False(t, ok, "notification channel of the detached listener should be closed")
+
+	joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-first)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-second)
+}
+
+func TestMatchmakingService_DetachingLastListenerLeavesMatchmaking(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		DuplicateJoin:          DuplicateJoinConfig{Policy: DuplicateJoinPolicy_Attach},
+	})
+
+	notifications := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-notifications).State)
+
+	matchmakingService.DetachListener("test_user_1", notifications)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Cancelled}, <-notifications)
+
+	assert.Eventually(t, func() bool {
+		return getNumberOfCompetitionsInMatchmaking(matchmakingService) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMatchmakingService_RetriedJoinTakesOverSession(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		DuplicateJoin:          DuplicateJoinConfig{Policy: DuplicateJoinPolicy_Reject},
+	})
+
+	first, err := matchmakingService.HandleIdempotentPlayerJoin(model.PlayerData{ID: "test_user_1", Level: 5}, "join_1")
+	assert.NoError(t, err)
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+
+	// a join with another key is still a duplicate
+	_, err = matchmakingService.HandleIdempotentPlayerJoin(model.PlayerData{ID: "test_user_1", Level: 5}, "join_2")
+	assert.ErrorIs(t, err, ErrPlayerAlreadyInMatchmaking)
+
+	retry, err := matchmakingService.HandleIdempotentPlayerJoin(model.PlayerData{ID: "test_user_1", Level: 5}, "join_1")
+	assert.NoError(t, err)
+	assert.Equal(t, State_SessionReplaced, (<-first).State)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-retry)
+}
+
+func TestMatchmakingService_RetriedJoinReceivesOutcomeOfCompletedJoin(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		DuplicateJoin:          DuplicateJoinConfig{IdempotencyKeyTTL: time.Minute},
+	})
+
+	first, err := matchmakingService.HandleIdempotentPlayerJoin(model.PlayerData{ID: "test_user_1", Level: 5}, "join_1")
+	assert.NoError(t, err)
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-first)
+
+	retry, err := matchmakingService.HandleIdempotentPlayerJoin(model.PlayerData{ID: "test_user_1", Level: 5}, "join_1")
+	assert.NoError(t, err)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-retry)
+	_, ok := <-retry
+	assert.False(t, ok, "retried join should not queue the player again")
+
+	// a new join is queued as usual
+	next, err := matchmakingService.HandleIdempotentPlayerJoin(model.PlayerData{ID: "test_user_1", Level: 5}, "join_2")
+	assert.NoError(t, err)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-next)
+}
+
+func TestCompletedJoinsExpire(t *testing.T) {
+	completedJoins := newCompletedJoins(time.Minute)
+	completedAt := time.Now()
+	completedJoins.add(completedJoin{playerID: "test_user_1", idempotencyKey: "join_1", completedAt: completedAt})
+	completedJoins.add(completedJoin{playerID: "test_user_2", idempotencyKey: "join_2", completedAt: completedAt.Add(time.Second)})
+	// the player completes another join before the first one expires
+	completedJoins.add(completedJoin{playerID: "test_user_1", idempotencyKey: "join_3", completedAt: completedAt.Add(2 * time.Second)})
+
+	_, found := completedJoins.get("test_user_1", "join_1", completedAt)
+	assert.False(t, found, "a join is only found by its latest key")
+	_, found = completedJoins.get("test_user_2", "", completedAt)
+	assert.False(t, found, "a join without a key is never a retry")
+
+	_, found = completedJoins.get("test_user_1", "join_3", completedAt.Add(time.Minute+time.Second))
+	assert.True(t, found)
+	_, found = completedJoins.get("test_user_2", "join_2", completedAt.Add(time.Minute+time.Second))
+	assert.False(t, found)
+	_, found = completedJoins.get("test_user_1", "join_3", completedAt.Add(time.Minute+2*time.Second))
+	assert.False(t, found)
+}
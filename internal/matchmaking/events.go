@@ -0,0 +1,103 @@
+package matchmaking
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"sync/atomic"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+)
+
+type subscription struct {
+	service    *matchmakingService
+	events     chan Event
+	dropPolicy DropPolicy
+	dropped    atomic.Uint64
+}
+
+func newSubscription(service *matchmakingService, options SubscriptionOptions) *subscription {
+	bufferSize := options.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultEventBufferSize
+	}
+	dropPolicy := options.DropPolicy
+	if dropPolicy == "" {
+		dropPolicy = DropPolicy_DropNewest
+	}
+	return &subscription{
+		service:    service,
+		events:     make(chan Event, bufferSize),
+		dropPolicy: dropPolicy,
+	}
+}
+
+// publish delivers an event to the subscriber without waiting for it
+// Only the matchmaking loop sends on the events channel, so after the oldest event is dropped
+// there is always room for the new event
+func (s *subscription) publish(event Event) {
+	select {
+	case s.events <- event:
+		return
+	default:
+	}
+
+	if s.dropPolicy == DropPolicy_DropOldest {
+		select {
+		case <-s.events:
+			s.dropped.Add(1)
+		default:
+			// the subscriber has received the buffered events meanwhile, so nothing is dropped
+		}
+		s.events <- event
+		return
+	}
+	s.dropped.Add(1)
+}
+
+func (s *subscription) unsubscribe() {
+	s.service.sendCommand(unsubscribeCommand{subscription: s})
+}
+
+// subscribe registers a subscriber for the events of the service
+// The events channel of a subscription made after the shutdown is closed right away
+func (m *matchmakingService) subscribe(options SubscriptionOptions) Subscription {
+	subscription := newSubscription(m, options)
+	if !m.sendCommand(subscribeCommand{subscription: subscription}) {
+		close(subscription.events)
+	}
+	return subscription
+}
+
+func (m *matchmakingService) handleSubscribe(subscription *subscription) {
+	m.subscriptions[subscription] = struct{}{}
+	slog.Info("Event subscriber added", "subscribers", len(m.subscriptions), "buffer_size", cap(subscription.events), "drop_policy", subscription.dropPolicy)
+}
+
+func (m *matchmakingService) handleUnsubscribe(subscription *subscription) {
+	if _, subscribed := m.subscriptions[subscription]; !subscribed {
+		return
+	}
+	delete(m.subscriptions, subscription)
+	close(subscription.events)
+	slog.Info("Event subscriber removed", "subscribers", len(m.subscriptions), "dropped_events", subscription.dropped.Load())
+}
+
+// closeSubscriptions ends the event stream of every subscriber
+func (m *matchmakingService) closeSubscriptions() {
+	for subscription := range m.subscriptions {
+		m.handleUnsubscribe(subscription)
+	}
+}
+
+// publishEvent delivers an event to every subscriber
+func (m *matchmakingService) publishEvent(event Event) {
+	for subscription := range m.subscriptions {
+		subscription.publish(event)
+	}
+}
+
+// getPlayerIDs returns the ids of the players of a competition in alphabetical order
+func getPlayerIDs(competition competition.Competition) []string {
+	return slices.Sorted(maps.Keys(competition.GetPlayers()))
+}
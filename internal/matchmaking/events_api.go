@@ -0,0 +1,176 @@
+package matchmaking
+
+import (
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+)
+
+// Event is a domain event published by the matchmaking service
+// Subscribers receive one of the event types below and can tell them apart with a type switch or GetType
+type Event interface {
+	// GetType returns the type of the event
+	GetType() EventType
+
+	// GetTime returns the time the event happened at, according to the clock of the service
+	GetTime() time.Time
+}
+
+// EventType is the type of a matchmaking event
+type EventType string
+
+const (
+	EventType_PlayerQueued       EventType = "player_queued"
+	EventType_PlayerLeft         EventType = "player_left"
+	EventType_CompetitionCreated EventType = "competition_created"
+	EventType_PlayerPlaced       EventType = "player_placed"
+	EventType_CompetitionStarted EventType = "competition_started"
+	EventType_CompetitionAborted EventType = "competition_aborted"
+)
+
+// PlayerQueuedEvent is published when a player or a party member joins matchmaking
+type PlayerQueuedEvent struct {
+	Time     time.Time
+	PlayerID string
+	Level    int
+	// PartyID is the id of the party the player joined with. Empty for a single player
+	PartyID string
+}
+
+// PlayerLeftEvent is published when a player leaves matchmaking before the competition started
+type PlayerLeftEvent struct {
+	Time     time.Time
+	PlayerID string
+	// CompetitionID is the competition the player was waiting in
+	CompetitionID int
+}
+
+// CompetitionCreatedEvent is published when a new competition enters matchmaking
+type CompetitionCreatedEvent struct {
+	Time          time.Time
+	CompetitionID int
+	LevelRange    competition.CompetitionLevelRange
+	// Region is the region of the competition. Empty if region matching is disabled
+	Region string
+}
+
+// PlayerPlacedEvent is published when a player is placed in a competition
+type PlayerPlacedEvent struct {
+	Time          time.Time
+	PlayerID      string
+	CompetitionID int
+	// Backfill is true if the player filled an open slot of a competition that has already started
+	Backfill bool
+}
+
+// CompetitionStartedEvent is published when a competition starts
+type CompetitionStartedEvent struct {
+	Time          time.Time
+	CompetitionID int
+	PlayerIDs     []string
+}
+
+// CompetitionAbortedEvent is published when a competition is aborted before it started
+type CompetitionAbortedEvent struct {
+	Time          time.Time
+	CompetitionID int
+	// PlayerIDs are the players in the competition when it was aborted, including players that are requeued
+	PlayerIDs []string
+}
+
+// DropPolicy defines which event is dropped when the buffer of a subscriber is full
+// The matchmaking loop never waits for a subscriber, so a slow subscriber loses events instead of slowing down matchmaking
+type DropPolicy string
+
+const (
+	// The new event is dropped, the subscriber receives the events it has not received yet first
+	DropPolicy_DropNewest DropPolicy = "drop_newest"
+
+	// The oldest buffered event is dropped to make room for the new event
+	DropPolicy_DropOldest DropPolicy = "drop_oldest"
+)
+
+// DefaultEventBufferSize is the buffer size of a subscription that does not set one
+const DefaultEventBufferSize = 256
+
+// SubscriptionOptions are the options of an event subscription
+type SubscriptionOptions struct {
+	// BufferSize is the number of events buffered for the subscriber. DefaultEventBufferSize is used if not positive
+	BufferSize int
+	// DropPolicy defines which event is dropped when the buffer is full. DropPolicy_DropNewest is used if not set
+	DropPolicy DropPolicy
+}
+
+// Subscription is a stream of the events of a matchmaking service
+type Subscription interface {
+	// Events returns the channel the events are delivered on
+	// The channel is closed when the subscription is cancelled or the service is shut down
+	Events() <-chan Event
+
+	// Dropped returns the number of events dropped because the buffer of the subscription was full
+	Dropped() uint64
+
+	// Unsubscribe cancels the subscription and closes the events channel
+	// Events that are already buffered can still be received
+	Unsubscribe()
+}
+
+func (e PlayerQueuedEvent) GetType() EventType {
+	return EventType_PlayerQueued
+}
+
+func (e PlayerQueuedEvent) GetTime() time.Time {
+	return e.Time
+}
+
+func (e PlayerLeftEvent) GetType() EventType {
+	return EventType_PlayerLeft
+}
+
+func (e PlayerLeftEvent) GetTime() time.Time {
+	return e.Time
+}
+
+func (e CompetitionCreatedEvent) GetType() EventType {
+	return EventType_CompetitionCreated
+}
+
+func (e CompetitionCreatedEvent) GetTime() time.Time {
+	return e.Time
+}
+
+func (e PlayerPlacedEvent) GetType() EventType {
+	return EventType_PlayerPlaced
+}
+
+func (e PlayerPlacedEvent) GetTime() time.Time {
+	return e.Time
+}
+
+func (e CompetitionStartedEvent) GetType() EventType {
+	return EventType_CompetitionStarted
+}
+
+func (e CompetitionStartedEvent) GetTime() time.Time {
+	return e.Time
+}
+
+func (e CompetitionAbortedEvent) GetType() EventType {
+	return EventType_CompetitionAborted
+}
+
+func (e CompetitionAbortedEvent) GetTime() time.Time {
+	return e.Time
+}
+
+func (s *subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *subscription) Unsubscribe() {
+	s.unsubscribe()
+}
@@ -0,0 +1,145 @@
+package matchmaking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var eventTestTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// receiveEvents receives the given number of events from a subscription
+func receiveEvents(subscription Subscription, numberOfEvents int) []Event {
+	events := make([]Event, numberOfEvents)
+	for i := range events {
+		events[i] = <-subscription.Events()
+	}
+	return events
+}
+
+func TestMatchmakingService_EventsOfStartedCompetition(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Minute,
+		LevelMatchingTolerance: 3,
+		Clock:                  clock.NewFakeClock(eventTestTime),
+	})
+	subscription := matchmakingService.Subscribe(SubscriptionOptions{})
+
+	joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 6})
+
+	assert.Equal(t, []Event{
+		PlayerQueuedEvent{Time: eventTestTime, PlayerID: "test_user_1", Level: 5},
+		CompetitionCreatedEvent{Time: eventTestTime, CompetitionID: 1, LevelRange: competition.CompetitionLevelRange{Min: 2, Max: 8}},
+		PlayerPlacedEvent{Time: eventTestTime, PlayerID: "test_user_1", CompetitionID: 1},
+		PlayerQueuedEvent{Time: eventTestTime, PlayerID: "test_user_2", Level: 6},
+		PlayerPlacedEvent{Time: eventTestTime, PlayerID: "test_user_2", CompetitionID: 1},
+		CompetitionStartedEvent{Time: eventTestTime, CompetitionID: 1, PlayerIDs: []string{"test_user_1", "test_user_2"}},
+	}, receiveEvents(subscription, 6))
+}
+
+func TestMatchmakingService_EventsOfLeavingPlayer(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Minute,
+		LevelMatchingTolerance: 3,
+		Clock:                  clock.NewFakeClock(eventTestTime),
+	})
+	subscription := matchmakingService.Subscribe(SubscriptionOptions{})
+
+	_, err := matchmakingService.HandlePartyJoin([]model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 5},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Event{
+		PlayerQueuedEvent{Time: eventTestTime, PlayerID: "test_user_1", Level: 5, PartyID: "party_1"},
+		PlayerQueuedEvent{Time: eventTestTime, PlayerID: "test_user_2", Level: 5, PartyID: "party_1"},
+	}, receiveEvents(subscription, 2))
+	receiveEvents(subscription, 4)
+
+	lonely := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5})
+	receiveEvents(subscription, 3)
+	assert.Equal(t, State_WaitingForPlayers, (<-lonely).State)
+	matchmakingService.LeaveMatchmaking("test_user_3")
+	assert.Equal(t, State_Cancelled, (<-lonely).State)
+
+	assert.Equal(t, []Event{
+		PlayerLeftEvent{Time: eventTestTime, PlayerID: "test_user_3", CompetitionID: 2},
+		CompetitionAbortedEvent{Time: eventTestTime, CompetitionID: 2},
+	}, receiveEvents(subscription, 2))
+}
+
+func TestSubscription_DropPolicies(t *testing.T) {
+	testCases := []struct {
+		dropPolicy     DropPolicy
+		expectedEvents []EventType
+	}{
+		{dropPolicy: "", expectedEvents: []EventType{EventType_PlayerQueued, EventType_CompetitionCreated}},
+		{dropPolicy: DropPolicy_DropNewest, expectedEvents: []EventType{EventType_PlayerQueued, EventType_CompetitionCreated}},
+		{dropPolicy: DropPolicy_DropOldest, expectedEvents: []EventType{EventType_CompetitionCreated, EventType_PlayerPlaced}},
+	}
+
+	for _, testCase := range testCases {
+		matchmakingService := newMatchmakingService(MatchmakingConfig{
+			CompetitionConfig: competition.CompetitionConfig{
+				MaxPlayerCount: 2,
+				MinPlayerCount: 2,
+			},
+			MatchmakingTimeout:     time.Minute,
+			LevelMatchingTolerance: 3,
+			Clock:                  clock.NewFakeClock(eventTestTime),
+		})
+		subscription := matchmakingService.Subscribe(SubscriptionOptions{BufferSize: 2, DropPolicy: testCase.dropPolicy})
+
+		// the join publishes three events, which do not fit into the buffer
+		joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+		subscription.Unsubscribe()
+
+		eventTypes := []EventType{}
+		for event := range subscription.Events() {
+			eventTypes = append(eventTypes, event.GetType())
+		}
+		assert.Equal(t, testCase.expectedEvents, eventTypes, testCase.dropPolicy)
+		assert.Equal(t, uint64(1), subscription.Dropped(), testCase.dropPolicy)
+	}
+}
+
+func TestMatchmakingService_SubscriptionsEndOnShutdown(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Minute,
+		LevelMatchingTolerance: 3,
+		Clock:                  clock.NewFakeClock(eventTestTime),
+	})
+	subscription := matchmakingService.Subscribe(SubscriptionOptions{})
+	joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+
+	assert.NoError(t, matchmakingService.Shutdown(context.Background()))
+
+	events := []Event{}
+	for event := range subscription.Events() {
+		events = append(events, event)
+	}
+	assert.Equal(t, CompetitionAbortedEvent{Time: eventTestTime, CompetitionID: 1, PlayerIDs: []string{"test_user_1"}}, events[len(events)-1])
+
+	_, open := <-matchmakingService.Subscribe(SubscriptionOptions{}).Events()
+	assert.False(t, open, "subscription made after the shutdown should be closed")
+	// unsubscribing after the shutdown has no effect
+	subscription.Unsubscribe()
+}
@@ -2,88 +2,235 @@ package matchmaking
 
 import (
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
 	"github.com/SntrKslnn/matchmaking-service/internal/competition"
 	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
+	"github.com/SntrKslnn/matchmaking-service/internal/results"
 )
 
 type playerInMatchmaking struct {
 	model.PlayerData
-	matchMakingNotificationChan chan MatchMakingNotification
+
+	// notificationChans are the channels of the connections listening to the player's notifications
+	// There is more than one only if duplicate joins are attached as extra listeners
+	notificationChans []chan MatchMakingNotification
+	// lastNotification is the last notification sent to the player. It is replayed to a connection taking over
+	// or attaching to the player
+	lastNotification *MatchMakingNotification
+	// idempotencyKey is the key the player joined with. Empty if the join was made without a key
+	idempotencyKey string
+
+	// queuedAt is the time the player originally joined matchmaking. It is kept when the player is requeued
+	queuedAt time.Time
+	// requeueCount is the number of times the player has been requeued after an aborted competition
+	requeueCount int
+	// partyID is the id of the party the player queued with. Empty if the player queued alone
+	partyID string
 }
 
 type competitionData struct {
 	competition.Competition
 	timeoutCancel chan struct{}
+
+	// levelRangeWidening is the number of levels the level range has been widened by on both ends
+	levelRangeWidening int
+	// createdAt is the time the competition was created
+	createdAt time.Time
+	// readyPlayerIDs are the players that confirmed an earlier ready check of the competition. They are not asked again
+	readyPlayerIDs map[string]struct{}
+}
+
+// notificationChannelBufferSize is the capacity of a player's notification channel.
+// A player receives only a handful of notifications during matchmaking, so a listener that
+// lets the buffer fill up is not reading and is detached instead of blocking the matchmaking loop
+const notificationChannelBufferSize = 8
+
+// competitionIDSequence hands out competition ids that are unique across the services sharing the sequence
+type competitionIDSequence struct {
+	lastID atomic.Int64
+}
+
+func (s *competitionIDSequence) next() int {
+	return int(s.lastID.Add(1))
 }
 
 type matchmakingService struct {
-	nextCompetitionID int
-	config            MatchmakingConfig
+	competitionIDs      *competitionIDSequence
+	config              MatchmakingConfig
+	competitionSelector CompetitionSelector
+	clock               clock.Clock
 
 	playersInMatchmaking      map[string]playerInMatchmaking
 	competitionsInMatchmaking map[int]competitionData
 
-	competitionJoinRequests chan model.PlayerData
+	// competitionLevelIndex indexes competitionsInMatchmaking by the levels they accept
+	competitionLevelIndex *competitionLevelIndex
+
+	// competitionIDsOfPlayers maps a player's ID to the competition the player has been placed in
+	competitionIDsOfPlayers map[string]int
+
+	// readyChecks are the competitions waiting for their players to confirm they are ready, by competition id
+	readyChecks map[int]*readyCheck
+
+	// backfillCompetitions are the started competitions in their backfill window, by competition id
+	// A backfill competition with open slots is in the level index next to the competitions waiting for players
+	backfillCompetitions map[int]competitionData
+
+	// competitionsInProgress are the started competitions waiting for their result, by competition id
+	competitionsInProgress map[int]competitionInProgress
+	resultStore            results.ResultStore
+
+	// completedJoins are the recently completed joins made with an idempotency key, by player id
+	completedJoins *completedJoins
 
-	stateMutationChan chan stateChangeNotification
+	// nextPartyID is the number of the last party that joined matchmaking
+	nextPartyID int
+
+	// subscriptions are the subscribers the events of the service are published to
+	subscriptions map[*subscription]struct{}
+
+	// commands are handled one by one by the matchmaking loop, which owns all of the state above
+	commands chan matchmakingCommand
+
+	// shutDown is set by the shutdown command, after which the matchmaking loop stops
+	shutDown bool
+	// stopped is closed when the matchmaking loop has stopped. Commands are no longer accepted after this
+	stopped chan struct{}
+	// goroutines are the matchmaking loop and the timers of the service, which the shutdown waits for
+	goroutines sync.WaitGroup
 }
 
 type matchmakingStateChangeOrigin string
 
 const (
 	matchmakingStateChangeOrigin_PlayerAdd matchmakingStateChangeOrigin = "player_added"
+	matchmakingStateChangeOrigin_PartyAdd  matchmakingStateChangeOrigin = "party_added"
 	matchmakingStateChangeOrigin_Timeout   matchmakingStateChangeOrigin = "matchmaking_timeout"
+	matchmakingStateChangeOrigin_Widening  matchmakingStateChangeOrigin = "level_range_widening"
+
+	matchmakingStateChangeOrigin_ReadyCheckTimeout matchmakingStateChangeOrigin = "ready_check_timeout"
+
+	matchmakingStateChangeOrigin_BackfillEnd matchmakingStateChangeOrigin = "backfill_window_end"
+
+	matchmakingStateChangeOrigin_ResultReportTimeout matchmakingStateChangeOrigin = "result_report_timeout"
+)
+
+// Lifecycle states of the competitions handled by matchmaking
+// They are declared here because competition variables shadow the competition package in most of the service
+const (
+	competitionState_Matchmaking = competition.CompetitionState_Matchmaking
+	competitionState_ReadyCheck  = competition.CompetitionState_ReadyCheck
+	competitionState_InProgress  = competition.CompetitionState_InProgress
+	competitionState_Aborted     = competition.CompetitionState_Aborted
+	competitionState_Finished    = competition.CompetitionState_Finished
 )
 
 type stateChangeNotification struct {
 	origin      matchmakingStateChangeOrigin
 	competition competition.Competition
 	playerData  model.PlayerData
+	party       []model.PlayerData
+	readyCheck  *readyCheck
 }
 
 func newMatchmakingService(config MatchmakingConfig) *matchmakingService {
+	return newMatchmakingServiceWithCompetitionIDs(config, &competitionIDSequence{})
+}
+
+// newMatchmakingServiceWithCompetitionIDs creates a matchmaking service that takes the ids of its competitions
+// from the given sequence, so that services sharing the sequence never create competitions with the same id
+func newMatchmakingServiceWithCompetitionIDs(config MatchmakingConfig, competitionIDs *competitionIDSequence) *matchmakingService {
+	competitionSelector := config.CompetitionSelector
+	if competitionSelector == nil {
+		competitionSelector = NewDefaultCompetitionSelector()
+	}
+	matchmakingClock := clock.OrReal(config.Clock)
+	if config.CompetitionConfig.Clock == nil {
+		config.CompetitionConfig.Clock = matchmakingClock
+	}
+	resultStore := config.Results.Store
+	if resultStore == nil {
+		resultStore = results.NewResultStore()
+	}
+
 	matchmakingService := &matchmakingService{
+		competitionSelector:       competitionSelector,
+		clock:                     matchmakingClock,
 		competitionsInMatchmaking: make(map[int]competitionData),
+		competitionLevelIndex:     newCompetitionLevelIndex(),
 		playersInMatchmaking:      make(map[string]playerInMatchmaking),
-		competitionJoinRequests:   make(chan model.PlayerData),
-		nextCompetitionID:         1,
+		competitionIDsOfPlayers:   make(map[string]int),
+		readyChecks:               make(map[int]*readyCheck),
+		backfillCompetitions:      make(map[int]competitionData),
+		competitionsInProgress:    make(map[int]competitionInProgress),
+		resultStore:               resultStore,
+		completedJoins:            newCompletedJoins(config.DuplicateJoin.IdempotencyKeyTTL),
+		subscriptions:             make(map[*subscription]struct{}),
+		competitionIDs:            competitionIDs,
 		config:                    config,
-		stateMutationChan:         make(chan stateChangeNotification),
+		commands:                  make(chan matchmakingCommand),
+		stopped:                   make(chan struct{}),
 	}
 	matchmakingService.start()
 	return matchmakingService
 }
 
-// TODO This would need to be fixed. Noticed it very late that this is actually a race condition
-// because MatchmakingService.HandlePlayerJoin can be called from different goroutines.
-// It leads to situation where different goroutines can access concurrently m.playersInMatchmaking
-// Better approach would be to mutate m.playersInMatchmaking from single goroutine always
-// Other option would be to use mutex
-func (m *matchmakingService) handlePlayerJoin(playerData model.PlayerData) <-chan MatchMakingNotification {
-	if _, exists := m.playersInMatchmaking[playerData.ID]; !exists {
-		matchMakingNotificationChan := make(chan MatchMakingNotification)
-		m.playersInMatchmaking[playerData.ID] = playerInMatchmaking{
-			PlayerData:                  playerData,
-			matchMakingNotificationChan: matchMakingNotificationChan,
-		}
+// handlePlayerJoin sends a player's join request to the matchmaking loop and waits until it has been handled
+// The notification channel is created here, because the player may already be placed in a competition
+// and notified by the time the request has been handled
+func (m *matchmakingService) handlePlayerJoin(playerData model.PlayerData, idempotencyKey string) (<-chan MatchMakingNotification, error) {
+	notificationChan := make(chan MatchMakingNotification, notificationChannelBufferSize)
+	reply := make(chan error)
+	accepted := m.sendCommand(playerJoinCommand{
+		playerData: m.withPlayerRating(playerData),
+		join: joinRequest{
+			idempotencyKey:   idempotencyKey,
+			notificationChan: notificationChan,
+		},
+		reply: reply,
+	})
+	if !accepted {
+		return nil, ErrMatchmakingShutDown
+	}
+	if err := <-reply; err != nil {
+		return nil, err
 	}
-	m.competitionJoinRequests <- playerData
-	return m.playersInMatchmaking[playerData.ID].matchMakingNotificationChan
+	return notificationChan, nil
 }
 
-func (m *matchmakingService) listenCompetitionJoinRequest() {
-	for playerData := range m.competitionJoinRequests {
-		m.updateMatchmakingWithPlayerJoinRequest(playerData)
+// withPlayerRating fills in the player's rating when rating matching is enabled
+// The rating is taken from the rating service if one is configured, otherwise missing values are defaulted
+func (m *matchmakingService) withPlayerRating(playerData model.PlayerData) model.PlayerData {
+	if !m.config.RatingMatching.Enabled {
+		return playerData
 	}
+
+	playerRating := rating.OfPlayer(playerData)
+	if m.config.RatingMatching.RatingService != nil {
+		playerRating = m.config.RatingMatching.RatingService.GetRating(playerData.ID)
+	}
+	playerData.Rating = playerRating.Value
+	playerData.RatingDeviation = playerRating.Deviation
+	return playerData
+}
+
+func (m *matchmakingService) leaveMatchmaking(playerID string) {
+	m.sendCommand(leaveCommand{playerID: playerID})
 }
 
 func (m *matchmakingService) getMatchMakingState(notificationOrigin matchmakingStateChangeOrigin, competition competition.Competition) MatchmakingState {
-	maxPlayerCountReached := competition.GetNumberOfJoinedPlayers() == m.config.CompetitionConfig.MaxPlayerCount
+	maxPlayerCountReached := competition.GetNumberOfJoinedPlayers() >= m.config.CompetitionConfig.GetMaxPlayerCount()
 	minPlayerCountReached := competition.GetNumberOfJoinedPlayers() >= m.config.CompetitionConfig.MinPlayerCount
 
-	if notificationOrigin == matchmakingStateChangeOrigin_PlayerAdd {
+	if notificationOrigin == matchmakingStateChangeOrigin_PlayerAdd || notificationOrigin == matchmakingStateChangeOrigin_PartyAdd {
 		if maxPlayerCountReached {
 			slog.Info("Max player count reached. Starting competition", "id", competition.GetID())
 			// Competition is full, start it
@@ -110,52 +257,191 @@ func (m *matchmakingService) processMatchmakingStateMutation(stateChangeNotifica
 	competition := stateChangeNotification.competition
 	notificationOrigin := stateChangeNotification.origin
 
-	if notificationOrigin == matchmakingStateChangeOrigin_PlayerAdd {
+	switch notificationOrigin {
+	case matchmakingStateChangeOrigin_PlayerAdd:
 		playerData := stateChangeNotification.playerData
-		competition = m.handleAddingPlayerToCompetition(playerData)
+		if !m.isPlayerWaitingForCompetition(playerData.ID) {
+			return
+		}
+		addedToCompetition, err := m.handleAddingPlayerToCompetition(playerData)
+		if err != nil {
+			m.rejectPlayers([]model.PlayerData{playerData}, err)
+			return
+		}
+		competition = addedToCompetition
+	case matchmakingStateChangeOrigin_PartyAdd:
+		members := slices.DeleteFunc(stateChangeNotification.party, func(member model.PlayerData) bool {
+			return !m.isPlayerWaitingForCompetition(member.ID)
+		})
+		if len(members) == 0 {
+			return
+		}
+		addedToCompetition, err := m.handleAddingPartyToCompetition(members)
+		if err != nil {
+			m.rejectPlayers(members, err)
+			return
+		}
+		competition = addedToCompetition
+	case matchmakingStateChangeOrigin_Widening:
+		m.widenCompetitionLevelRange(competition)
+		return
+	case matchmakingStateChangeOrigin_ReadyCheckTimeout:
+		m.handleReadyCheckTimeout(stateChangeNotification.readyCheck)
+		return
+	case matchmakingStateChangeOrigin_BackfillEnd:
+		m.endBackfillWindow(competition)
+		return
+	case matchmakingStateChangeOrigin_ResultReportTimeout:
+		m.handleResultReportTimeout(competition)
+		return
+	case matchmakingStateChangeOrigin_Timeout:
+		// The competition may have been started or aborted while the timeout was in flight
+		if competition.GetState() != competitionState_Matchmaking {
+			return
+		}
+	}
+
+	// A started competition taking players only needs to stop advertising once it is full again
+	if m.isBackfillingCompetition(competition) {
+		m.updateBackfillAdvertisement(competition)
+		return
 	}
 
 	competitionState := m.getMatchMakingState(notificationOrigin, competition)
 
 	switch competitionState {
 	case State_Started:
+		if m.isReadyCheckEnabled() {
+			m.startReadyCheck(competition)
+			return
+		}
 		m.startCompetition(competition)
 	case State_Aborted:
 		m.abortCompetition(competition)
 	}
 }
 
-func (m *matchmakingService) listenCompetitionStatusCheckChan() {
-	for stateChangeNotification := range m.stateMutationChan {
-		m.processMatchmakingStateMutation(stateChangeNotification)
+// runMatchmakingLoop handles the commands of the service one at a time until the service is shut down
+// All state of the service is read and changed only here
+func (m *matchmakingService) runMatchmakingLoop() {
+	defer close(m.stopped)
+	for command := range m.commands {
+		command.handle(m)
+		if m.shutDown {
+			slog.Info("Matchmaking loop stopped")
+			return
+		}
 	}
 }
 
-// This is a naive implementation that iterates over all the competitions to find the first one that matches the player's level
-// This is not efficient for a large number of competitions
-// This could be improved by using more efficient data structures for constant time lookup
-func (m *matchmakingService) findFirstCompetitionThatMatchesPlayerLevel(playerData model.PlayerData) (competition.Competition, bool) {
-	for _, competitionData := range m.competitionsInMatchmaking {
-		competition := competitionData.Competition
-		if competition.IsPlayerLevelMatching(playerData) {
-			return competition, true
+// findCompetitionsThatMatchPlayerLevel looks up the competitions that match the player's level from the level index
+// The cost of the lookup depends on the number of matching competitions, not on the number of all competitions
+func (m *matchmakingService) findCompetitionsThatMatchPlayerLevel(playerData model.PlayerData) []competition.Competition {
+	return m.competitionLevelIndex.findCompetitionsMatchingLevel(playerData.Level)
+}
+
+// findCompetitionsThatMatchPlayer returns the competitions matching the player's level
+// and, when enabled, the player's rating and ping
+// Only competitions with room for the given number of players are returned
+func (m *matchmakingService) findCompetitionsThatMatchPlayer(playerData model.PlayerData, queuedAt time.Time, numberOfPlayers int) []competition.Competition {
+	competitions := m.findCompetitionsThatMatchPlayerLevel(playerData)
+	return slices.DeleteFunc(competitions, func(competition competition.Competition) bool {
+		if competition.GetNumberOfJoinedPlayers()+numberOfPlayers > m.config.CompetitionConfig.GetMaxPlayerCount() {
+			return true
 		}
-	}
-	return nil, false
+		if m.config.RatingMatching.Enabled && !competition.IsPlayerRatingMatching(playerData, m.config.RatingMatching.Matching) {
+			return true
+		}
+		return m.isRegionMatchingEnabled() && !m.isPlayerPingMatching(playerData, queuedAt, m.getCompetitionData(competition.GetID()))
+	})
+}
+
+// findCompetitionForPlayers finds the best fitting competition for a group of players that is placed together
+// @param matchingData the level, rating and pings the players are matched with
+// @param queuedAt the time the earliest of the players joined matchmaking
+// @param numberOfPlayers the number of players that must fit into the competition
+// @return the competition and true, or false if no competition is found
+func (m *matchmakingService) findCompetitionForPlayers(matchingData model.PlayerData, queuedAt time.Time, numberOfPlayers int) (competition.Competition, bool) {
+	return m.competitionSelector.SelectCompetition(matchingData, m.findCompetitionsThatMatchPlayer(matchingData, queuedAt, numberOfPlayers))
 }
 
-func (m *matchmakingService) findCompetitionForPlayer(playerData model.PlayerData) (competition.Competition, bool) {
-	return m.findFirstCompetitionThatMatchesPlayerLevel(playerData)
+// isPlayerWaitingForCompetition checks if a player is in matchmaking but not yet placed in a competition
+// A player that has left matchmaking or has already been placed is not waiting
+func (m *matchmakingService) isPlayerWaitingForCompetition(playerID string) bool {
+	if _, exists := m.playersInMatchmaking[playerID]; !exists {
+		slog.Info("Player is no longer in matchmaking. Ignoring join request", "player_id", playerID)
+		return false
+	}
+	if competitionID, placed := m.competitionIDsOfPlayers[playerID]; placed {
+		slog.Info("Player is already in competition. Ignoring join request", "id", competitionID, "player_id", playerID)
+		return false
+	}
+	return true
 }
 
 func (m *matchmakingService) sendNotificationToPlayer(playerID string, matchMakingNotification MatchMakingNotification) {
-	m.playersInMatchmaking[playerID].matchMakingNotificationChan <- matchMakingNotification
+	player, exists := m.playersInMatchmaking[playerID]
+	if !exists {
+		slog.Warn("Player is not in matchmaking. Dropping notification", "player_id", playerID, "state", matchMakingNotification.State)
+		return
+	}
+	listeners := make([]chan MatchMakingNotification, 0, len(player.notificationChans))
+	for _, notificationChan := range player.notificationChans {
+		if !notifyListener(notificationChan, matchMakingNotification) {
+			slog.Warn("Listener is not reading notifications. Detaching listener", "player_id", playerID, "state", matchMakingNotification.State)
+			close(notificationChan)
+			continue
+		}
+		listeners = append(listeners, notificationChan)
+	}
+	if len(listeners) == 0 && len(player.notificationChans) > 0 && !m.shutDown {
+		// The player is removed by a command, because the callers may still be iterating over the player's competition
+		m.startGoroutine(func() {
+			m.sendCommand(abandonedPlayerCommand{playerID: playerID})
+		})
+	}
+	player.notificationChans = listeners
+	player.lastNotification = &matchMakingNotification
+	m.playersInMatchmaking[playerID] = player
+}
+
+// notifyListener sends a notification to a listener without blocking
+// @return false if the buffer of the listener is full
+func notifyListener(notificationChan chan MatchMakingNotification, notification MatchMakingNotification) bool {
+	select {
+	case notificationChan <- notification:
+		return true
+	default:
+		return false
+	}
 }
 
-func (m *matchmakingService) addPlayerToCompetition(playerData model.PlayerData, competitionToAddPlayerTo competition.Competition) {
-	competitionToAddPlayerTo.AddPlayer(playerData)
+// handleAbandonedPlayer removes a player whose listeners have all been detached for not reading notifications
+// Has no effect if the player has left or a new connection has joined as the player meanwhile
+func (m *matchmakingService) handleAbandonedPlayer(playerID string) {
+	player, exists := m.playersInMatchmaking[playerID]
+	if !exists || len(player.notificationChans) > 0 {
+		return
+	}
+	slog.Info("No listener left for player. Removing player from matchmaking", "player_id", playerID)
+	m.handlePlayerLeavingMatchmaking(playerID)
+}
 
-	go m.sendNotificationToPlayer(playerData.ID, MatchMakingNotification{
+// registerPlayerInCompetition records that a player was added to a competition and notifies the player
+func (m *matchmakingService) registerPlayerInCompetition(playerData model.PlayerData, competitionToAddPlayerTo competition.Competition) {
+	if m.isBackfillingCompetition(competitionToAddPlayerTo) {
+		m.registerBackfilledPlayer(playerData, competitionToAddPlayerTo)
+		return
+	}
+
+	m.competitionIDsOfPlayers[playerData.ID] = competitionToAddPlayerTo.GetID()
+	m.publishEvent(PlayerPlacedEvent{
+		Time:          m.clock.Now(),
+		PlayerID:      playerData.ID,
+		CompetitionID: competitionToAddPlayerTo.GetID(),
+	})
+
+	m.sendNotificationToPlayer(playerData.ID, MatchMakingNotification{
 		CompetitionID: competitionToAddPlayerTo.GetID(),
 		State:         State_WaitingForPlayers,
 	})
@@ -171,44 +457,239 @@ func (m *matchmakingService) addPlayerToCompetition(playerData model.PlayerData,
 // It will find a competition for the player or create a new one if no competition is found
 // It will then add the player to the competition
 // @param playerData the player to add to the competition
-// @return the competition that the player was added to
-func (m *matchmakingService) handleAddingPlayerToCompetition(playerData model.PlayerData) competition.Competition {
-	competition, found := m.findCompetitionForPlayer(playerData)
-	if !found {
-		competition = m.createNewCompetition(playerData)
+// @return the competition that the player was added to, or an error if the player could not be added to any competition
+func (m *matchmakingService) handleAddingPlayerToCompetition(playerData model.PlayerData) (competition.Competition, error) {
+	return m.handleAddingPlayersToCompetition(playerData, []model.PlayerData{playerData})
+}
+
+// handleAddingPlayersToCompetition handles the adding of a group of players to the same competition
+// It will find a competition with room for all the players or create a new one if no competition is found
+// A new competition is also created if the found competition rejects the players
+// @param matchingData the level, rating and pings the players are matched with
+// @param players the players to add to the competition
+// @return the competition that the players were added to, or an error if the players could not be added to any competition
+func (m *matchmakingService) handleAddingPlayersToCompetition(matchingData model.PlayerData, players []model.PlayerData) (competition.Competition, error) {
+	queuedAt := m.getEarliestQueueTime(players)
+	if foundCompetition, found := m.findCompetitionForPlayers(matchingData, queuedAt, len(players)); found {
+		err := foundCompetition.AddParty(matchingData, players)
+		if err == nil {
+			m.registerPlayersInCompetition(players, foundCompetition)
+			return foundCompetition, nil
+		}
+		slog.Warn("Competition rejected players. Creating new competition", "id", foundCompetition.GetID(), "player_id", matchingData.ID, "error", err)
 	}
-	m.addPlayerToCompetition(playerData, competition)
-	return competition
+
+	newCompetition := m.createNewCompetition(matchingData, queuedAt)
+	if err := newCompetition.AddParty(matchingData, players); err != nil {
+		m.abortCompetition(newCompetition)
+		return nil, err
+	}
+	m.registerPlayersInCompetition(players, newCompetition)
+	return newCompetition, nil
+}
+
+func (m *matchmakingService) registerPlayersInCompetition(players []model.PlayerData, competition competition.Competition) {
+	for _, playerData := range players {
+		m.registerPlayerInCompetition(playerData, competition)
+	}
+}
+
+// rejectPlayers removes players that could not be added to any competition from matchmaking
+// The players are notified that the matchmaking was aborted
+// @param players the rejected players
+// @param err the reason the players were rejected
+func (m *matchmakingService) rejectPlayers(players []model.PlayerData, err error) {
+	for _, playerData := range players {
+		slog.Error("Player could not be added to a competition", "player_id", playerData.ID, "error", err)
+		m.sendNotificationToPlayer(playerData.ID, MatchMakingNotification{State: State_Aborted})
+		m.unregisterPlayerFromMatchmakingStage(playerData.ID)
+	}
+}
+
+func (m *matchmakingService) getEarliestQueueTime(players []model.PlayerData) time.Time {
+	earliestQueueTime := m.playersInMatchmaking[players[0].ID].queuedAt
+	for _, playerData := range players[1:] {
+		if queuedAt := m.playersInMatchmaking[playerData.ID].queuedAt; queuedAt.Before(earliestQueueTime) {
+			earliestQueueTime = queuedAt
+		}
+	}
+	return earliestQueueTime
+}
+
+// sendCommand sends a command to the matchmaking loop
+// @return false if the command was not accepted because the service has been shut down
+func (m *matchmakingService) sendCommand(command matchmakingCommand) bool {
+	select {
+	case m.commands <- command:
+		return true
+	case <-m.stopped:
+		return false
+	}
+}
+
+// startGoroutine runs a function in a goroutine that the shutdown of the service waits for
+func (m *matchmakingService) startGoroutine(run func()) {
+	m.goroutines.Add(1)
+	go func() {
+		defer m.goroutines.Done()
+		run()
+	}()
+}
+
+// handlePlayerLeavingMatchmaking removes a player from matchmaking and from the competition the player was placed in
+// The player is notified that the matchmaking was cancelled. A competition left without players is aborted
+// @param playerID the id of the player leaving matchmaking
+func (m *matchmakingService) handlePlayerLeavingMatchmaking(playerID string) {
+	if _, exists := m.playersInMatchmaking[playerID]; !exists {
+		slog.Info("Player is not in matchmaking. Ignoring leave request", "player_id", playerID)
+		return
+	}
+
+	competitionID, placed := m.competitionIDsOfPlayers[playerID]
+	m.publishEvent(PlayerLeftEvent{
+		Time:          m.clock.Now(),
+		PlayerID:      playerID,
+		CompetitionID: competitionID,
+	})
+
+	if check, inReadyCheck := m.readyChecks[competitionID]; placed && inReadyCheck {
+		slog.Info("Player left ready check", "id", competitionID, "player_id", playerID)
+		m.removePlayerFromReadyCheck(check, playerID)
+	} else if placed {
+		m.removePlayerFromCompetitionInMatchmaking(competitionID, playerID)
+	}
+
+	m.sendNotificationToPlayer(playerID, MatchMakingNotification{
+		CompetitionID: competitionID,
+		State:         State_Cancelled,
+	})
+	m.unregisterPlayerFromMatchmakingStage(playerID)
 }
 
-func (m *matchmakingService) sendStateMutationCommands(stateMutationCommand stateChangeNotification) {
-	m.stateMutationChan <- stateMutationCommand
+// removePlayerFromCompetitionInMatchmaking removes a player leaving matchmaking from its waiting competition
+// The competition is aborted if no player is left. Nothing is removed if the competition is no longer in matchmaking
+// @param competitionID the id of the competition the player is placed in
+// @param playerID the id of the player leaving
+func (m *matchmakingService) removePlayerFromCompetitionInMatchmaking(competitionID int, playerID string) {
+	competitionData, inMatchmaking := m.competitionsInMatchmaking[competitionID]
+	if !inMatchmaking {
+		slog.Warn("Competition of leaving player is not in matchmaking. Skipping removal", "id", competitionID, "player_id", playerID)
+		return
+	}
+	competition := competitionData.Competition
+	// a player joining again must confirm again, even if placed in the same competition
+	delete(competitionData.readyPlayerIDs, playerID)
+	if err := competition.RemovePlayer(playerID); err != nil {
+		slog.Error("Failed to remove player from competition", "id", competitionID, "player_id", playerID, "error", err)
+	}
+	slog.Info("Player left competition", "id", competitionID, "player_id", playerID)
+
+	if competition.GetNumberOfJoinedPlayers() == 0 {
+		m.abortCompetition(competition)
+	}
 }
 
-func (m *matchmakingService) updateMatchmakingWithPlayerJoinRequest(playerData model.PlayerData) {
-	m.sendStateMutationCommands(stateChangeNotification{
-		origin:     matchmakingStateChangeOrigin_PlayerAdd,
-		playerData: playerData,
+// startCompetitionTimers starts the matchmaking timeout and the level range widening schedule of a competition
+// The timers are created on the matchmaking loop, so they run from the moment the competition enters matchmaking
+func (m *matchmakingService) startCompetitionTimers(competition competition.Competition, timeoutCancel <-chan struct{}) {
+	timeout := m.clock.After(m.config.MatchmakingTimeout)
+	m.startGoroutine(func() {
+		m.startTimeoutTimerForCompetition(competition, timeout, timeoutCancel)
 	})
+
+	if numberOfWidenings := m.getNumberOfLevelRangeWidenings(); numberOfWidenings > 0 {
+		ticker := m.clock.NewTicker(m.config.LevelRangeWidening.Interval)
+		m.startGoroutine(func() {
+			m.startLevelRangeWideningForCompetition(competition, ticker, numberOfWidenings, timeoutCancel)
+		})
+	}
 }
 
-func (m *matchmakingService) startTimeoutTimerForCompetition(competition competition.Competition) {
+func (m *matchmakingService) startTimeoutTimerForCompetition(competition competition.Competition, timeout <-chan time.Time, timeoutCancel <-chan struct{}) {
 
 	select {
-	case <-time.After(m.config.MatchmakingTimeout):
+	case <-timeout:
 		slog.Info("Matchmaking timeouted. Checking for minimum player count", "id", competition.GetID())
-		m.sendStateMutationCommands(stateChangeNotification{
+		m.sendCommand(stateChangeNotification{
 			origin:      matchmakingStateChangeOrigin_Timeout,
 			competition: competition,
 		})
-	case <-m.competitionsInMatchmaking[competition.GetID()].timeoutCancel:
+	case <-timeoutCancel:
 		return
 	}
 }
 
+// getNumberOfLevelRangeWidenings returns how many times the level range of a competition is widened
+// according to the configured schedule before the widening cap is reached
+func (m *matchmakingService) getNumberOfLevelRangeWidenings() int {
+	widening := m.config.LevelRangeWidening
+	if widening.Step <= 0 || widening.Interval <= 0 || widening.MaxWidening <= 0 {
+		return 0
+	}
+	return (widening.MaxWidening + widening.Step - 1) / widening.Step
+}
+
+func (m *matchmakingService) startLevelRangeWideningForCompetition(competition competition.Competition, ticker clock.Ticker, numberOfWidenings int, timeoutCancel <-chan struct{}) {
+	defer ticker.Stop()
+
+	for range numberOfWidenings {
+		select {
+		case <-ticker.C():
+			m.sendCommand(stateChangeNotification{
+				origin:      matchmakingStateChangeOrigin_Widening,
+				competition: competition,
+			})
+		case <-timeoutCancel:
+			return
+		}
+	}
+}
+
+// getLevelRangeWideningForWaitTime returns the number of levels the configured schedule widens
+// a level range by on both ends after the given wait time
+// @param waitTime the time waited in matchmaking
+// @return the widening of the level range, capped to the configured maximum
+func (m *matchmakingService) getLevelRangeWideningForWaitTime(waitTime time.Duration) int {
+	if m.getNumberOfLevelRangeWidenings() == 0 {
+		return 0
+	}
+	widening := m.config.LevelRangeWidening
+	return min(int(waitTime/widening.Interval)*widening.Step, widening.MaxWidening)
+}
+
+func widenLevelRange(levelRange competition.CompetitionLevelRange, widening int) competition.CompetitionLevelRange {
+	return competition.CompetitionLevelRange{
+		Min: max(levelRange.Min-widening, 1),
+		Max: levelRange.Max + widening,
+	}
+}
+
+// widenCompetitionLevelRange widens the level range of a competition waiting for players by one step
+// of the configured schedule, without exceeding the configured cap
+// @param competition the competition to widen the level range of
+func (m *matchmakingService) widenCompetitionLevelRange(competition competition.Competition) {
+	competitionData, exists := m.competitionsInMatchmaking[competition.GetID()]
+	if !exists {
+		return
+	}
+
+	step := min(m.config.LevelRangeWidening.Step, m.config.LevelRangeWidening.MaxWidening-competitionData.levelRangeWidening)
+	if step <= 0 {
+		return
+	}
+
+	levelRange := widenLevelRange(competition.GetLevelRange(), step)
+	competition.SetLevelRange(levelRange)
+	m.competitionLevelIndex.update(competition)
+
+	competitionData.levelRangeWidening += step
+	m.competitionsInMatchmaking[competition.GetID()] = competitionData
+
+	slog.Info("Widened competition level range", "id", competition.GetID(), "min_level", levelRange.Min, "max_level", levelRange.Max)
+}
+
 func (m *matchmakingService) start() {
-	go m.listenCompetitionStatusCheckChan()
-	go m.listenCompetitionJoinRequest()
+	m.startGoroutine(m.runMatchmakingLoop)
 }
 
 func (m *matchmakingService) getLevelRangeMatchmakingConfiguratedOverlap(playerData model.PlayerData) (int, int) {
@@ -220,53 +701,206 @@ func (m *matchmakingService) getLevelRangeMatchmakingConfiguratedOverlap(playerD
 	return playerMinLevel, playerMaxLevel
 }
 
-func (m *matchmakingService) createNewCompetition(playerData model.PlayerData) competition.Competition {
+// createNewCompetition creates a new competition with a level range around the player's level
+// The level range of a competition created for a requeued player is widened according to the time
+// the player has already waited, so the player's original wait time is not lost
+// When region matching is enabled the competition is bound to the region the player has the lowest ping to
+// @param playerData the player the competition is created for
+// @param queuedAt the time the player originally joined matchmaking
+// @return the created competition
+func (m *matchmakingService) createNewCompetition(playerData model.PlayerData, queuedAt time.Time) competition.Competition {
 	playerMinLevel, playerMaxLevel := m.getLevelRangeMatchmakingConfiguratedOverlap(playerData)
-
-	competition := competition.NewCompetition(m.nextCompetitionID, m.config.CompetitionConfig, competition.CompetitionLevelRange{
+	levelRangeWidening := m.getLevelRangeWideningForWaitTime(m.clock.Now().Sub(queuedAt))
+	levelRange := widenLevelRange(competition.CompetitionLevelRange{
 		Min: playerMinLevel,
 		Max: playerMaxLevel,
-	})
-
-	slog.Info("Creating new competition", "id", competition.GetID(), "min_level", playerMinLevel, "max_level", playerMaxLevel)
+	}, levelRangeWidening)
 
-	m.competitionsInMatchmaking[competition.GetID()] = competitionData{
-		Competition:   competition,
-		timeoutCancel: make(chan struct{}),
+	region := ""
+	if m.isRegionMatchingEnabled() {
+		region = selectRegion(playerData)
 	}
+	competition := competition.NewCompetitionInRegion(m.competitionIDs.next(), m.config.CompetitionConfig, levelRange, region)
+	m.transitionCompetition(competition, competitionState_Matchmaking)
 
-	go m.startTimeoutTimerForCompetition(competition)
-
-	m.nextCompetitionID++
+	slog.Info("Creating new competition", "id", competition.GetID(), "min_level", levelRange.Min, "max_level", levelRange.Max, "region", region)
 
+	timeoutCancel := make(chan struct{})
+	m.competitionsInMatchmaking[competition.GetID()] = competitionData{
+		Competition:        competition,
+		timeoutCancel:      timeoutCancel,
+		levelRangeWidening: levelRangeWidening,
+		createdAt:          competition.GetCreatedAt(),
+	}
+	m.competitionLevelIndex.add(competition)
+	m.startCompetitionTimers(competition, timeoutCancel)
+
+	m.publishEvent(CompetitionCreatedEvent{
+		Time:          m.clock.Now(),
+		CompetitionID: competition.GetID(),
+		LevelRange:    levelRange,
+		Region:        region,
+	})
 	return competition
 }
 
 func (m *matchmakingService) unregisterCompetitionFromMatchmakingStage(competition competition.Competition) {
 	delete(m.competitionsInMatchmaking, competition.GetID())
+	m.competitionLevelIndex.remove(competition)
 	slog.Info("Deleted competition from pending competitions", "id", competition.GetID())
 }
 
+// unregisterPlayerFromMatchmakingStage removes a player from matchmaking and closes the player's notification channel
+// No notifications are sent to the player after this
+func (m *matchmakingService) unregisterPlayerFromMatchmakingStage(playerID string) {
+	player := m.playersInMatchmaking[playerID]
+	for _, notificationChan := range player.notificationChans {
+		close(notificationChan)
+	}
+	m.recordCompletedJoin(player)
+	delete(m.playersInMatchmaking, playerID)
+	delete(m.competitionIDsOfPlayers, playerID)
+	slog.Info("Deleted player from matchmaking", "id", playerID)
+}
+
 func (m *matchmakingService) unregisterPlayersFromMatchmakingStage(competition competition.Competition) {
 	for _, player := range competition.GetPlayers() {
-		delete(m.playersInMatchmaking, player.ID)
-		slog.Info("Deleted player from matchmaking", "id", player.ID)
+		m.unregisterPlayerFromMatchmakingStage(player.ID)
 	}
 }
 
 func (m *matchmakingService) startCompetition(competition competition.Competition) {
-	competition.Start()
+	competitionData := m.competitionsInMatchmaking[competition.GetID()]
 	m.closeTimeoutCancelChannelForCompetition(competition)
-	m.notifyPlayers(competition, State_Started)
 	m.unregisterCompetitionFromMatchmakingStage(competition)
-	m.unregisterPlayersFromMatchmakingStage(competition)
+	m.commitCompetition(competitionData)
+}
+
+// commitCompetition starts a competition that is no longer in matchmaking and notifies its players
+// When backfill is enabled, the started competition is kept for the backfill window
+// @param competitionData the data of the competition to start
+func (m *matchmakingService) commitCompetition(competitionData competitionData) {
+	m.transitionCompetition(competitionData.Competition, competitionState_InProgress)
+	m.publishEvent(CompetitionStartedEvent{
+		Time:          m.clock.Now(),
+		CompetitionID: competitionData.GetID(),
+		PlayerIDs:     getPlayerIDs(competitionData.Competition),
+	})
+	m.notifyPlayers(competitionData.Competition, State_Started)
+	m.unregisterPlayersFromMatchmakingStage(competitionData.Competition)
+	m.registerCompetitionInProgress(competitionData.Competition)
+
+	// a competition started while shutting down is not kept for backfill, as no player can join anymore
+	if m.isBackfillEnabled() && !m.shutDown {
+		m.registerBackfillCompetition(competitionData)
+	}
 }
 
+// abortCompetition aborts a competition waiting for players
+// Players are requeued according to the requeue policy, the rest are notified that the competition was aborted
 func (m *matchmakingService) abortCompetition(competition competition.Competition) {
+	m.transitionCompetition(competition, competitionState_Aborted)
 	m.closeTimeoutCancelChannelForCompetition(competition)
-	m.notifyPlayers(competition, State_Aborted)
 	m.unregisterCompetitionFromMatchmakingStage(competition)
-	m.unregisterPlayersFromMatchmakingStage(competition)
+	m.publishCompetitionAborted(competition)
+
+	playersToRequeue := []model.PlayerData{}
+	for _, player := range m.getPlayersInOrderOfQueueTime(competition) {
+		if !m.canRequeuePlayer(player.ID) {
+			m.sendNotificationToPlayer(player.ID, MatchMakingNotification{
+				CompetitionID: competition.GetID(),
+				State:         State_Aborted,
+			})
+			m.unregisterPlayerFromMatchmakingStage(player.ID)
+			continue
+		}
+
+		m.sendNotificationToPlayer(player.ID, MatchMakingNotification{
+			CompetitionID: competition.GetID(),
+			State:         State_Requeued,
+		})
+		playersToRequeue = append(playersToRequeue, player)
+	}
+
+	for _, players := range m.groupPlayersByParty(playersToRequeue) {
+		m.requeuePlayers(players)
+	}
+}
+
+// getPlayersInOrderOfQueueTime returns the players of a competition, the player that has waited the longest first
+func (m *matchmakingService) getPlayersInOrderOfQueueTime(competition competition.Competition) []model.PlayerData {
+	players := slices.Collect(maps.Values(competition.GetPlayers()))
+	slices.SortFunc(players, func(a, b model.PlayerData) int {
+		if order := m.playersInMatchmaking[a.ID].queuedAt.Compare(m.playersInMatchmaking[b.ID].queuedAt); order != 0 {
+			return order
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return players
+}
+
+func (m *matchmakingService) publishCompetitionAborted(competition competition.Competition) {
+	m.publishEvent(CompetitionAbortedEvent{
+		Time:          m.clock.Now(),
+		CompetitionID: competition.GetID(),
+		PlayerIDs:     getPlayerIDs(competition),
+	})
+}
+
+func (m *matchmakingService) canRequeuePlayer(playerID string) bool {
+	return !m.shutDown && m.playersInMatchmaking[playerID].requeueCount < m.config.RequeuePolicy.MaxRetries
+}
+
+// groupPlayersByParty groups players that queued together in a party, keeping the order of the players
+// A player that queued alone is in a group of its own
+func (m *matchmakingService) groupPlayersByParty(players []model.PlayerData) [][]model.PlayerData {
+	groups := [][]model.PlayerData{}
+	groupIndexesOfParties := make(map[string]int)
+	for _, playerData := range players {
+		partyID := m.playersInMatchmaking[playerData.ID].partyID
+		if groupIndex, exists := groupIndexesOfParties[partyID]; exists && partyID != "" {
+			groups[groupIndex] = append(groups[groupIndex], playerData)
+			continue
+		}
+		groupIndexesOfParties[partyID] = len(groups)
+		groups = append(groups, []model.PlayerData{playerData})
+	}
+	return groups
+}
+
+// requeuePlayers puts players of an aborted competition back into matchmaking
+// Players that queued in a party are requeued together
+// The players keep the original queue time, so the time already waited counts when a new competition is created
+// @param players the players to requeue
+func (m *matchmakingService) requeuePlayers(players []model.PlayerData) {
+	for _, playerData := range players {
+		player := m.playersInMatchmaking[playerData.ID]
+		player.requeueCount++
+		m.playersInMatchmaking[playerData.ID] = player
+		delete(m.competitionIDsOfPlayers, playerData.ID)
+
+		slog.Info("Requeueing player", "player_id", playerData.ID, "requeue_count", player.requeueCount)
+	}
+
+	if m.playersInMatchmaking[players[0].ID].partyID == "" {
+		m.processMatchmakingStateMutation(stateChangeNotification{
+			origin:     matchmakingStateChangeOrigin_PlayerAdd,
+			playerData: players[0],
+		})
+		return
+	}
+	m.processMatchmakingStateMutation(stateChangeNotification{
+		origin: matchmakingStateChangeOrigin_PartyAdd,
+		party:  players,
+	})
+}
+
+// transitionCompetition moves a competition to a new lifecycle state
+// The matchmaking loop only makes transitions the lifecycle allows, so a rejected transition is logged as an error
+func (m *matchmakingService) transitionCompetition(competition competition.Competition, state competition.CompetitionState) {
+	if err := competition.TransitionTo(state); err != nil {
+		slog.Error("Failed to change competition state", "id", competition.GetID(), "error", err)
+	}
 }
 
 func (m *matchmakingService) closeTimeoutCancelChannelForCompetition(competition competition.Competition) {
@@ -275,9 +909,12 @@ func (m *matchmakingService) closeTimeoutCancelChannelForCompetition(competition
 
 func (m *matchmakingService) notifyPlayers(startedCompetition competition.Competition, state MatchmakingState) {
 	for _, player := range startedCompetition.GetPlayers() {
+		team, _ := startedCompetition.GetTeamOfPlayer(player.ID)
 		m.sendNotificationToPlayer(player.ID, MatchMakingNotification{
 			CompetitionID: startedCompetition.GetID(),
 			State:         state,
+			Team:          team,
+			Region:        startedCompetition.GetRegion(),
 		})
 	}
 }
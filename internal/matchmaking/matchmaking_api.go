@@ -1,29 +1,333 @@
 package matchmaking
 
 import (
+	"context"
+	"errors"
 	"time"
 
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
 	"github.com/SntrKslnn/matchmaking-service/internal/competition"
 	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
+	"github.com/SntrKslnn/matchmaking-service/internal/results"
 )
 
 type MatchmakingService interface {
 	// HandlePlayerJoin handles a player's request to join matchmaking and returns a notification channel
 	// that will receive updates about competition matching
-	HandlePlayerJoin(playerData model.PlayerData) <-chan MatchMakingNotification
+	// A player that is already in matchmaking is handled according to the duplicate join policy
+	// @param playerData the joining player
+	// @return the notification channel, or ErrPlayerAlreadyInMatchmaking if the join is rejected as a duplicate
+	HandlePlayerJoin(playerData model.PlayerData) (<-chan MatchMakingNotification, error)
+
+	// HandleIdempotentPlayerJoin handles a player's request to join matchmaking that the client may retry
+	// Joins with the same idempotency key are the same join. A retry made while the player is in matchmaking
+	// takes over the session of the join, and a retry made after the player was placed in a started competition
+	// receives the started notification again while the idempotency key TTL has not passed
+	// @param playerData the joining player
+	// @param idempotencyKey the key identifying the join across retries
+	// @return the notification channel, or ErrPlayerAlreadyInMatchmaking if the join is rejected as a duplicate
+	HandleIdempotentPlayerJoin(playerData model.PlayerData, idempotencyKey string) (<-chan MatchMakingNotification, error)
+
+	// DetachListener stops sending a player's notifications to a notification channel and closes the channel
+	// The player leaves matchmaking if no other channel listens to the player's notifications
+	// Has no effect if the player is not in matchmaking or the channel does not belong to the player
+	// @param playerID the id of the player
+	// @param notifications the notification channel returned when the player joined
+	DetachListener(playerID string, notifications <-chan MatchMakingNotification)
+
+	// LeaveMatchmaking removes a player from matchmaking and from the competition the player is waiting in
+	// The player receives a cancelled notification and the notification channel is closed
+	// Has no effect if the player is not in matchmaking
+	LeaveMatchmaking(playerID string)
+
+	// HandlePartyJoin handles a party's request to join matchmaking together
+	// All members are placed in the same competition and every member's channel receives the same updates
+	// A member leaving matchmaking leaves alone, the rest of the party stays in the competition
+	// @param members the players of the party
+	// @return the notification channels of the members by player id, or an error if the party cannot join
+	HandlePartyJoin(members []model.PlayerData) (map[string]<-chan MatchMakingNotification, error)
+
+	// ConfirmReady confirms that a player in a ready check is ready for the competition to start
+	// Has no effect if the player is not in a ready check
+	ConfirmReady(playerID string)
+
+	// ReportPlayerDropped removes a player that dropped out of a started competition
+	// When backfill is enabled, the open slot is filled with a new player during the backfill window
+	// Has no effect if the competition is not in its backfill window
+	// @param competitionID the id of the started competition
+	// @param playerID the id of the player that dropped
+	ReportPlayerDropped(competitionID int, playerID string)
+
+	// ReportResult reports the final placements and scores of a competition in progress
+	// The result must contain every player of the competition exactly once. It is stored and published
+	// to the result listeners, and the competition is finished
+	// @param competitionID the id of the competition
+	// @param playerResults the results of the players
+	// @return ErrUnknownCompetition if the competition is not in progress, or an error if the result is invalid
+	ReportResult(competitionID int, playerResults []results.PlayerResult) error
+
+	// Shutdown stops the service. Every player in matchmaking receives a server shutting down notification,
+	// the open competitions are started or aborted according to the shutdown policy and every notification
+	// channel is closed. Requests made after the shutdown are rejected with ErrMatchmakingShutDown
+	// @param ctx the deadline for the shutdown
+	// @return the error of the context if the goroutines of the service did not finish before its deadline
+	Shutdown(ctx context.Context) error
+
+	// Subscribe subscribes to the events of the service, for example for metrics or audit logging
+	// Events are buffered for every subscriber and dropped according to the drop policy when the subscriber
+	// does not keep up, so subscribers never slow down matchmaking
+	// @param options the buffer size and drop policy of the subscription. The zero value uses the defaults
+	// @return the subscription. Its events channel is closed right away if the service is shut down
+	Subscribe(options SubscriptionOptions) Subscription
+
+	// GetPlayerStatus returns where a player in matchmaking is, as of the last notification sent to the player
+	// @param playerID the id of the player
+	// @return the status, or ErrPlayerNotInMatchmaking if the player is not in matchmaking
+	GetPlayerStatus(playerID string) (PlayerStatus, error)
+}
+
+// PlayerStatus is the status of a player in matchmaking
+type PlayerStatus struct {
+	PlayerID string
+	// CompetitionID is the competition the player is waiting in
+	CompetitionID int
+	// State is the state of the last notification sent to the player
+	State MatchmakingState
+	// QueuedAt is the time the player joined matchmaking
+	QueuedAt time.Time
+	// PartyID is the id of the party the player joined with. Empty for a single player
+	PartyID string `json:",omitempty"`
 }
 
+var (
+	// ErrEmptyParty is returned when a party without members tries to join matchmaking
+	ErrEmptyParty = errors.New("party has no members")
+
+	// ErrPartyTooLarge is returned when a party has more members than fit into a competition
+	ErrPartyTooLarge = errors.New("party does not fit into a competition")
+
+	// ErrDuplicatePartyMember is returned when the same player is in a party more than once
+	ErrDuplicatePartyMember = errors.New("player is in the party more than once")
+
+	// ErrPlayerAlreadyInMatchmaking is returned when a joining player or party member is already in matchmaking
+	ErrPlayerAlreadyInMatchmaking = errors.New("player is already in matchmaking")
+
+	// ErrPlayerNotInMatchmaking is returned when the status of a player that is not in matchmaking is requested
+	ErrPlayerNotInMatchmaking = errors.New("player is not in matchmaking")
+
+	// ErrUnknownCompetition is returned when a result is reported for a competition that is not in progress
+	ErrUnknownCompetition = errors.New("competition is not in progress")
+
+	// ErrResultRosterMismatch is returned when a reported result does not contain exactly the players of the competition
+	ErrResultRosterMismatch = errors.New("result does not match the players of the competition")
+
+	// ErrDuplicatePlayerResult is returned when a player is in a reported result more than once
+	ErrDuplicatePlayerResult = errors.New("player is in the result more than once")
+
+	// ErrInvalidPlacement is returned when a reported placement is less than 1
+	ErrInvalidPlacement = errors.New("placement must be at least 1")
+
+	// ErrMatchmakingShutDown is returned when a request is made after the matchmaking service was shut down
+	ErrMatchmakingShutDown = errors.New("matchmaking is shut down")
+)
+
 // MatchmakingConfig is the configuration for the matchmaking service
 type MatchmakingConfig struct {
 	LevelMatchingTolerance int
 	MatchmakingTimeout     time.Duration
 	CompetitionConfig      competition.CompetitionConfig
+	LevelRangeWidening     LevelRangeWideningConfig
+
+	// CompetitionSelector selects the competition a player is placed in when several competitions match
+	// the player's level. NewDefaultCompetitionSelector is used if not set
+	CompetitionSelector CompetitionSelector
+
+	// RequeuePolicy defines if players of an aborted competition are put back into matchmaking
+	RequeuePolicy RequeuePolicy
+
+	// RatingMatching defines if players are matched by skill rating in addition to level
+	RatingMatching RatingMatchingConfig
+
+	// Party defines how parties are matched
+	Party PartyConfig
+
+	// RegionMatching defines if players are only placed in competitions of regions they have a low ping to
+	RegionMatching RegionMatchingConfig
+
+	// ReadyCheck defines if players must confirm they are ready before a competition starts
+	ReadyCheck ReadyCheckConfig
+
+	// Backfill defines if started competitions with open slots take new players
+	Backfill BackfillConfig
+
+	// Results defines where the results of finished competitions are stored and published
+	Results ResultsConfig
+
+	// DuplicateJoin defines what happens when a player that is already in matchmaking joins again
+	DuplicateJoin DuplicateJoinConfig
+
+	// Shutdown defines what happens to the open competitions when the service is shut down
+	Shutdown ShutdownConfig
+
+	// Clock drives the timeouts, schedules and timestamps of matchmaking. The real clock is used if not set
+	// The clock is also used for the competitions unless the competition config sets its own
+	Clock clock.Clock
+}
+
+// ShutdownConfig is the configuration for shutting down the service
+type ShutdownConfig struct {
+	// Policy defines what happens to the competitions waiting for players or in a ready check
+	// ShutdownPolicy_Abort is used if not set
+	Policy ShutdownPolicy
+}
+
+// ShutdownPolicy defines what happens to the open competitions when the service is shut down
+// Players are never requeued on shutdown
+type ShutdownPolicy string
+
+const (
+	// Every open competition is aborted
+	ShutdownPolicy_Abort ShutdownPolicy = "abort"
+
+	// Open competitions that have reached the minimum player count are started, the rest are aborted
+	ShutdownPolicy_Start ShutdownPolicy = "start"
+)
+
+// DuplicateJoinConfig is the configuration for joins of players that are already in matchmaking,
+// for example when a player joins from a second connection
+type DuplicateJoinConfig struct {
+	// Policy defines how the duplicate join is handled. DuplicateJoinPolicy_Reject is used if not set
+	Policy DuplicateJoinPolicy
+	// IdempotencyKeyTTL is the time a join made with an idempotency key is remembered after the player
+	// was placed in a started competition. Completed joins are not remembered if not positive
+	IdempotencyKeyTTL time.Duration
+}
+
+// DuplicateJoinPolicy defines how a join of a player that is already in matchmaking is handled
+// Parties are always rejected if a member is already in matchmaking
+type DuplicateJoinPolicy string
+
+const (
+	// The join is rejected with ErrPlayerAlreadyInMatchmaking
+	DuplicateJoinPolicy_Reject DuplicateJoinPolicy = "reject"
+
+	// The new connection takes over the player. The old connection receives a session replaced notification
+	DuplicateJoinPolicy_TakeOver DuplicateJoinPolicy = "take_over"
+
+	// The new connection receives the player's notifications in addition to the old connection
+	DuplicateJoinPolicy_Attach DuplicateJoinPolicy = "attach"
+)
+
+// DefaultResultReportWindow is the time a started competition waits for its result when ResultsConfig.ReportWindow is not set
+const DefaultResultReportWindow = 24 * time.Hour
+
+// ResultsConfig is the configuration for reporting competition results
+type ResultsConfig struct {
+	// Store stores the reported results. An in-memory store is used if not set
+	Store results.ResultStore
+	// Listeners are notified about every stored result, for example to update ratings or leaderboards
+	Listeners []results.ResultListener
+	// ReportWindow is the time after the start a competition takes its result. A competition whose result
+	// is not reported in time is aborted and forgotten. DefaultResultReportWindow is used if not positive
+	ReportWindow time.Duration
+}
+
+// BackfillConfig is the configuration for filling open slots of started competitions
+// A started competition is kept for the backfill window. While it has open slots, because it started
+// without being full or because players dropped out, new players within its level range are placed in it
+type BackfillConfig struct {
+	// Window is the time after the start a competition takes new players. Backfill is disabled if Window is not positive
+	Window time.Duration
+}
+
+// ReadyCheckConfig is the configuration for the ready check before a competition starts
+// When a competition is ready to start, its players receive a ready check notification and must confirm
+// within the window. Players that do not confirm are removed and the competition is backfilled
+// with new players, or dropped if nobody confirmed
+type ReadyCheckConfig struct {
+	// Window is the time players have to confirm. The ready check is disabled if Window is not positive
+	Window time.Duration
+}
+
+// RegionMatchingConfig is the configuration for latency-aware matchmaking
+// Every competition is bound to the region the first player has the lowest ping to, and a player is only placed
+// in a competition if the player's ping to its region is under the threshold
+// The threshold is relaxed the longer the player or the competition has waited
+type RegionMatchingConfig struct {
+	// MaxPing is the ping threshold in milliseconds. Region matching is disabled if MaxPing is not positive
+	MaxPing int
+	// PingRelaxationStep is the number of milliseconds the threshold is relaxed by on every interval
+	PingRelaxationStep int
+	// PingRelaxationInterval is the time between two relaxations. Relaxation is disabled if not positive
+	PingRelaxationInterval time.Duration
+	// MaxPingRelaxation caps the number of milliseconds the threshold is relaxed by
+	MaxPingRelaxation int
+}
+
+// PartyConfig is the configuration for matching parties of players queueing together
+type PartyConfig struct {
+	// LevelAggregation defines how the levels of the members are combined into the level the party is matched with
+	// PartyLevelAggregation_Average is used if not set
+	LevelAggregation PartyLevelAggregation
+	// MaxLevelWeight is the weight of the highest member level in the weighted aggregation, between 0 and 1
+	// The rest of the weight is given to the average level
+	MaxLevelWeight float64
+}
+
+// PartyLevelAggregation defines how the levels of party members are combined
+type PartyLevelAggregation string
+
+const (
+	// The party is matched with the average level of the members
+	PartyLevelAggregation_Average PartyLevelAggregation = "average"
+
+	// The party is matched with the highest level of the members
+	PartyLevelAggregation_Max PartyLevelAggregation = "max"
+
+	// The party is matched with a weighted combination of the average and the highest level of the members
+	PartyLevelAggregation_Weighted PartyLevelAggregation = "weighted"
+)
+
+// RatingMatchingConfig is the configuration for matching players by skill rating in addition to level
+type RatingMatchingConfig struct {
+	// Enabled enables rating matching
+	Enabled bool
+	// Matching defines how close the ratings of a player and a competition must be
+	Matching rating.MatchingConfig
+	// RatingService provides the ratings of joining players. If not set, the ratings in the player data are used
+	RatingService rating.RatingService
+}
+
+// RequeuePolicy is the policy for putting players of an aborted competition back into matchmaking
+// Requeued players keep their original queue time and receive a requeued notification instead of aborted
+type RequeuePolicy struct {
+	// MaxRetries is the maximum number of times a player is requeued. Requeueing is disabled if MaxRetries is not positive
+	MaxRetries int
+}
+
+// LevelRangeWideningConfig is the schedule for widening the level range of competitions waiting for players
+// For example Step 1, Interval 5s and MaxWidening 3 widens the level range by one level on both ends
+// every 5 seconds until the range is 3 levels wider on both ends than at creation
+type LevelRangeWideningConfig struct {
+	// Step is the number of levels added to both ends of the level range on every interval
+	Step int
+	// Interval is the time between two widenings. Widening is disabled if Interval or Step is not positive
+	Interval time.Duration
+	// MaxWidening caps the number of levels added to both ends of the level range
+	MaxWidening int
 }
 
 // MatchMakingNotification is a notification that is sent to the player to keep them updated about the matchmaking process
 type MatchMakingNotification struct {
 	CompetitionID int
 	State         MatchmakingState
+
+	// Team is the team the player was assigned to in a started team competition, starting from 1
+	Team int `json:",omitempty"`
+
+	// Region is the region a started competition is played in
+	Region string `json:",omitempty"`
 }
 
 // State of the competition in matchmaking
@@ -38,6 +342,28 @@ const (
 
 	// Indicates that the competition has been aborted
 	State_Aborted MatchmakingState = "aborted"
+
+	// Indicates that the player left matchmaking before the competition started
+	State_Cancelled MatchmakingState = "cancelled"
+
+	// Indicates that the competition has been aborted and the player has been put back into matchmaking
+	State_Requeued MatchmakingState = "requeued"
+
+	// Indicates that the competition is ready to start and the player must confirm being ready
+	State_ReadyCheck MatchmakingState = "ready_check"
+
+	// Indicates that the player did not confirm the ready check in time and was removed from matchmaking
+	State_ReadyCheckMissed MatchmakingState = "ready_check_missed"
+
+	// Indicates that the player was placed in an open slot of a competition that has already started
+	State_Backfill MatchmakingState = "backfill"
+
+	// Indicates that the player joined again from another connection, which now receives the notifications
+	State_SessionReplaced MatchmakingState = "session_replaced"
+
+	// Indicates that the server is shutting down. It is followed by the started or aborted notification
+	// of the competition the player is waiting in
+	State_ServerShuttingDown MatchmakingState = "server_shutting_down"
 )
 
 // NewMatchmakingService creates a new matchmaking service
@@ -47,6 +373,46 @@ func NewMatchmakingService(config MatchmakingConfig) MatchmakingService {
 	return newMatchmakingService(config)
 }
 
-func (m *matchmakingService) HandlePlayerJoin(playerData model.PlayerData) <-chan MatchMakingNotification {
-	return m.handlePlayerJoin(playerData)
+func (m *matchmakingService) HandlePlayerJoin(playerData model.PlayerData) (<-chan MatchMakingNotification, error) {
+	return m.handlePlayerJoin(playerData, "")
+}
+
+func (m *matchmakingService) HandleIdempotentPlayerJoin(playerData model.PlayerData, idempotencyKey string) (<-chan MatchMakingNotification, error) {
+	return m.handlePlayerJoin(playerData, idempotencyKey)
+}
+
+func (m *matchmakingService) DetachListener(playerID string, notifications <-chan MatchMakingNotification) {
+	m.detachListener(playerID, notifications)
+}
+
+func (m *matchmakingService) LeaveMatchmaking(playerID string) {
+	m.leaveMatchmaking(playerID)
+}
+
+func (m *matchmakingService) HandlePartyJoin(members []model.PlayerData) (map[string]<-chan MatchMakingNotification, error) {
+	return m.handlePartyJoin(members)
+}
+
+func (m *matchmakingService) ConfirmReady(playerID string) {
+	m.confirmReady(playerID)
+}
+
+func (m *matchmakingService) ReportPlayerDropped(competitionID int, playerID string) {
+	m.reportPlayerDropped(competitionID, playerID)
+}
+
+func (m *matchmakingService) ReportResult(competitionID int, playerResults []results.PlayerResult) error {
+	return m.reportResult(competitionID, playerResults)
+}
+
+func (m *matchmakingService) Shutdown(ctx context.Context) error {
+	return m.shutdown(ctx)
+}
+
+func (m *matchmakingService) Subscribe(options SubscriptionOptions) Subscription {
+	return m.subscribe(options)
+}
+
+func (m *matchmakingService) GetPlayerStatus(playerID string) (PlayerStatus, error) {
+	return m.getPlayerStatus(playerID)
 }
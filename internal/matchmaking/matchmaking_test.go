@@ -1,11 +1,16 @@
 package matchmaking
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
 	"github.com/SntrKslnn/matchmaking-service/internal/competition"
 	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,21 +27,18 @@ func createTesUsers(playerData []model.PlayerData) []TestPlayer {
 	return testUsers
 }
 
-func listenForCompetitionStartNotification(testPlayer TestPlayer, expectedNotification map[string]bool) {
-	for notification := range testPlayer.personalNotificationChannel {
-		if notification.State == State_Started {
-			expectedNotification[testPlayer.ID] = true
-		}
-	}
-}
-
-func listenPlayerNotifications(testUsers []TestPlayer, expectedNotification map[string]bool) {
+// drainPlayerNotifications receives the notifications of the players until their channels are closed
+func drainPlayerNotifications(testUsers []TestPlayer) {
 	for _, testPlayer := range testUsers {
-		go listenForCompetitionStartNotification(testPlayer, expectedNotification)
+		go func() {
+			for range testPlayer.personalNotificationChannel {
+			}
+		}()
 	}
 }
 
 func TestMatchmakingService_HandlePlayerJoin(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
 	matchmakingService := newMatchmakingService(MatchmakingConfig{
 		CompetitionConfig: competition.CompetitionConfig{
 			MaxPlayerCount: 10,
@@ -44,31 +46,25 @@ func TestMatchmakingService_HandlePlayerJoin(t *testing.T) {
 		},
 		MatchmakingTimeout:     3 * time.Second,
 		LevelMatchingTolerance: 3,
+		Clock:                  fakeClock,
 	})
 
 	testUsers := createTesUsers([]model.PlayerData{
 		{ID: "test_user_1", Level: 1},
 		{ID: "test_user_2", Level: 2},
 	})
-
-	playerReceivedExpectedNotification := map[string]bool{
-		"test_user_1": false,
-		"test_user_2": false,
+	joinPlayersToMatchmaking(t, matchmakingService, testUsers)
+	for _, testUser := range testUsers {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-testUser.personalNotificationChannel)
 	}
 
-	go func() {
-		joinPlayersToMatchmaking(matchmakingService, testUsers)
-		listenPlayerNotifications(testUsers, playerReceivedExpectedNotification)
-	}()
-
-	assert.Eventually(t, func() bool {
-		for _, expectedNotification := range playerReceivedExpectedNotification {
-			if !expectedNotification {
-				return false
-			}
-		}
-		return true
-	}, time.Second*100, time.Millisecond*100)
+	// the competition is not full and starts with the minimum player count when matchmaking times out
+	fakeClock.Advance(3*time.Second - time.Millisecond)
+	assert.Empty(t, testUsers[0].personalNotificationChannel)
+	fakeClock.Advance(time.Millisecond)
+	for _, testUser := range testUsers {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-testUser.personalNotificationChannel)
+	}
 }
 
 func TestMatchmakingService_OverlappingLevels(t *testing.T) {
@@ -107,27 +103,445 @@ func TestMatchmakingService_NewCompetitionAreCreatedRespectingLevelDistributionO
 			{PlayerData: model.PlayerData{ID: "test_user_2", Level: 2}},
 			{PlayerData: model.PlayerData{ID: "test_user_3", Level: 3}},
 
+			{PlayerData: model.PlayerData{ID: "test_user_4", Level: 25}},
 			{PlayerData: model.PlayerData{ID: "test_user_5", Level: 25}},
-			{PlayerData: model.PlayerData{ID: "test_user_5", Level: 25}},
-			{PlayerData: model.PlayerData{ID: "test_user_5", Level: 25}},
+			{PlayerData: model.PlayerData{ID: "test_user_10", Level: 25}},
 
 			{PlayerData: model.PlayerData{ID: "test_user_6", Level: 60}},
 			{PlayerData: model.PlayerData{ID: "test_user_7", Level: 61}},
 			{PlayerData: model.PlayerData{ID: "test_user_8", Level: 62}},
 			{PlayerData: model.PlayerData{ID: "test_user_9", Level: 63}},
 		}
-		joinPlayersToMatchmaking(matchmakingService, testPlayers)
-		listenPlayerNotifications(testPlayers, nil)
+		joinPlayersToMatchmaking(t, matchmakingService, testPlayers)
+		drainPlayerNotifications(testPlayers)
 	}()
 
 	assert.Eventually(t, func() bool {
-		return len(matchmakingService.competitionsInMatchmaking) == 3
+		return getNumberOfCompetitionsInMatchmaking(matchmakingService) == 3
 	}, 10*time.Second, 50*time.Millisecond)
 }
 
-func joinPlayersToMatchmaking(matchmakingService *matchmakingService, players []TestPlayer) {
+func TestMatchmakingService_LeaveMatchmaking(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		Clock:                  fakeClock,
+	})
+
+	leavingPlayer := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 1})
+	assert.Equal(t, State_WaitingForPlayers, (<-leavingPlayer).State)
+	stayingPlayer := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 2})
+	assert.Equal(t, State_WaitingForPlayers, (<-stayingPlayer).State)
+
+	matchmakingService.LeaveMatchmaking("test_user_1")
+
+	notification := <-leavingPlayer
+	assert.Equal(t, State_Cancelled, notification.State)
+	assert.Equal(t, 1, notification.CompetitionID)
+	_, open := <-leavingPlayer
+	assert.False(t, open, "notification channel should be closed after leaving")
+
+	// the remaining player alone does not reach the minimum player count and the competition is aborted
+	fakeClock.Advance(3 * time.Second)
+	assert.Equal(t, State_Aborted, (<-stayingPlayer).State)
+}
+
+func TestMatchmakingService_LeaveMatchmakingAbortsEmptyCompetition(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+	})
+
+	notifications := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 1})
+	assert.Equal(t, State_WaitingForPlayers, (<-notifications).State)
+
+	matchmakingService.LeaveMatchmaking("test_user_1")
+	assert.Equal(t, State_Cancelled, (<-notifications).State)
+
+	// leaving twice has no effect
+	matchmakingService.LeaveMatchmaking("test_user_1")
+
+	assert.Eventually(t, func() bool {
+		return getNumberOfCompetitionsInMatchmaking(matchmakingService) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMatchmakingService_LeaveMatchmakingOfPlayerWhoseCompetitionIsGone(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+	})
+
+	notifications := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 1})
+	assert.Equal(t, State_WaitingForPlayers, (<-notifications).State)
+	inspectMatchmakingService(matchmakingService, func() {
+		delete(matchmakingService.competitionsInMatchmaking, 1)
+	})
+
+	// the player still leaves matchmaking
+	matchmakingService.LeaveMatchmaking("test_user_1")
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Cancelled}, <-notifications)
+	_, open := <-notifications
+	assert.False(t, open, "notification channel should be closed after leaving")
+}
+
+func TestMatchmakingService_LevelRangeIsWidenedWhileWaiting(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		LevelRangeWidening: LevelRangeWideningConfig{
+			Step:        2,
+			Interval:    50 * time.Millisecond,
+			MaxWidening: 3,
+		},
+		Clock: fakeClock,
+	})
+
+	waitingPlayer := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 10})
+	assert.Equal(t, State_WaitingForPlayers, (<-waitingPlayer).State)
+
+	// level range 7-13 is widened to 5-15 and then capped to 4-16
+	for _, expectedLevelRangeWidening := range []int{2, 3} {
+		// a tick is only delivered once the previous one is handled, so the clock is advanced one interval at a time
+		fakeClock.Advance(50 * time.Millisecond)
+		assert.Eventually(t, func() bool {
+			levelRangeWidening := 0
+			inspectMatchmakingService(matchmakingService, func() {
+				levelRangeWidening = matchmakingService.competitionsInMatchmaking[1].levelRangeWidening
+			})
+			return levelRangeWidening == expectedLevelRangeWidening
+		}, time.Second, time.Millisecond)
+	}
+	inspectMatchmakingService(matchmakingService, func() {
+		assert.Equal(t, competition.CompetitionLevelRange{Min: 4, Max: 16}, matchmakingService.competitionsInMatchmaking[1].GetLevelRange())
+	})
+
+	distantPlayer := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 16})
+	notification := <-distantPlayer
+	assert.Equal(t, 1, notification.CompetitionID)
+	assert.Equal(t, State_Started, (<-distantPlayer).State)
+}
+
+func TestMatchmakingService_LevelRangeWideningIsDisabledByDefault(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		LevelMatchingTolerance: 3,
+		LevelRangeWidening: LevelRangeWideningConfig{
+			Step:     1,
+			Interval: 0,
+		},
+	})
+
+	assert.Equal(t, 0, matchmakingService.getNumberOfLevelRangeWidenings())
+}
+
+func TestMatchmakingService_PlayersAreRequeuedWhenCompetitionIsAborted(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     100 * time.Millisecond,
+		LevelMatchingTolerance: 3,
+		RequeuePolicy: RequeuePolicy{
+			MaxRetries: 1,
+		},
+		Clock: fakeClock,
+	})
+
+	notifications := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-notifications)
+	fakeClock.Advance(100 * time.Millisecond)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Requeued}, <-notifications)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-notifications)
+	fakeClock.Advance(100 * time.Millisecond)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_Aborted}, <-notifications)
+	_, open := <-notifications
+	assert.False(t, open, "notification channel should be closed when retries are exhausted")
+}
+
+func TestMatchmakingService_RequeuedPlayersKeepWaitTime(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		LevelMatchingTolerance: 3,
+		LevelRangeWidening: LevelRangeWideningConfig{
+			Step:        2,
+			Interval:    5 * time.Second,
+			MaxWidening: 5,
+		},
+	})
+
+	assert.Equal(t, 0, matchmakingService.getLevelRangeWideningForWaitTime(4*time.Second))
+	assert.Equal(t, 2, matchmakingService.getLevelRangeWideningForWaitTime(5*time.Second))
+	assert.Equal(t, 4, matchmakingService.getLevelRangeWideningForWaitTime(12*time.Second))
+	assert.Equal(t, 5, matchmakingService.getLevelRangeWideningForWaitTime(time.Minute))
+}
+
+func TestMatchmakingService_RatingMatching(t *testing.T) {
+	ratingService := rating.NewRatingService(rating.NewEloCalculator(rating.DefaultEloKFactor))
+	ratingService.UpdateRatings(map[string]int{"test_user_1": 1, "test_user_2": 2})
+
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 10,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		RatingMatching: RatingMatchingConfig{
+			Enabled:       true,
+			Matching:      rating.MatchingConfig{Tolerance: 10},
+			RatingService: ratingService,
+		},
+	})
+
+	// the rating carried in the player data is ignored when a rating service is configured
+	winner := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5, Rating: 1000})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-winner)
+
+	loser := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-loser)
+
+	newPlayer := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-newPlayer).State)
+	assert.Equal(t, 3, getNumberOfCompetitionsInMatchmaking(matchmakingService))
+}
+
+func TestMatchmakingService_StartedNotificationsIncludeTeams(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MinPlayerCount: 2,
+			TeamCount:      2,
+			TeamSize:       2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+	})
+
+	players := createTesUsers([]model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 6},
+		{ID: "test_user_3", Level: 7},
+		{ID: "test_user_4", Level: 8},
+	})
+	joinPlayersToMatchmaking(t, matchmakingService, players)
+
+	teamSizes := map[int]int{}
+	for _, player := range players {
+		assert.Equal(t, State_WaitingForPlayers, (<-player.personalNotificationChannel).State)
+		notification := <-player.personalNotificationChannel
+		assert.Equal(t, State_Started, notification.State)
+		teamSizes[notification.Team]++
+	}
+	assert.Equal(t, map[int]int{1: 2, 2: 2}, teamSizes)
+}
+
+func joinPlayersToMatchmaking(t *testing.T, matchmakingService *matchmakingService, players []TestPlayer) {
 	for i := range players {
-		notificationChannel := matchmakingService.HandlePlayerJoin(players[i].PlayerData)
+		notificationChannel := joinPlayer(t, matchmakingService, players[i].PlayerData)
 		players[i].personalNotificationChannel = notificationChannel
 	}
 }
+
+// inspectCommand runs a function on the matchmaking loop
+type inspectCommand struct {
+	inspect func()
+	done    chan struct{}
+}
+
+func (c inspectCommand) handle(m *matchmakingService) {
+	c.inspect()
+	close(c.done)
+}
+
+// inspectMatchmakingService runs a function on the matchmaking loop and waits for it,
+// so that tests read the state of the service without racing with the loop
+func inspectMatchmakingService(matchmakingService *matchmakingService, inspect func()) {
+	done := make(chan struct{})
+	if matchmakingService.sendCommand(inspectCommand{inspect: inspect, done: done}) {
+		<-done
+	}
+}
+
+func getNumberOfCompetitionsInMatchmaking(matchmakingService *matchmakingService) int {
+	numberOfCompetitions := 0
+	inspectMatchmakingService(matchmakingService, func() {
+		numberOfCompetitions = len(matchmakingService.competitionsInMatchmaking)
+	})
+	return numberOfCompetitions
+}
+
+// findCompetitionsMatchingLevel looks up the competitions matching a level on the matchmaking loop
+func findCompetitionsMatchingLevel(matchmakingService *matchmakingService, level int) []competition.Competition {
+	var competitions []competition.Competition
+	inspectMatchmakingService(matchmakingService, func() {
+		competitions = matchmakingService.findCompetitionsThatMatchPlayerLevel(model.PlayerData{Level: level})
+	})
+	return competitions
+}
+
+// joinPlayer joins a player to matchmaking and fails the test if the join is rejected
+func joinPlayer(t *testing.T, matchmakingService MatchmakingService, playerData model.PlayerData) <-chan MatchMakingNotification {
+	notificationChannel, err := matchmakingService.HandlePlayerJoin(playerData)
+	assert.NoError(t, err)
+	return notificationChannel
+}
+
+func TestMatchmakingService_CompetitionLifecycle(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     300 * time.Millisecond,
+		LevelMatchingTolerance: 3,
+		Clock:                  fakeClock,
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	startedCompetition := findCompetitionsMatchingLevel(matchmakingService, 5)[0]
+	assert.Equal(t, competition.CompetitionState_Matchmaking, startedCompetition.GetState())
+
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-second).State)
+	assert.Equal(t, State_Started, (<-second).State)
+	assert.Equal(t, competition.CompetitionState_InProgress, startedCompetition.GetState())
+
+	lonely := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-lonely).State)
+	abortedCompetition := findCompetitionsMatchingLevel(matchmakingService, 5)[0]
+	fakeClock.Advance(300 * time.Millisecond)
+	assert.Equal(t, State_Aborted, (<-lonely).State)
+	assert.Equal(t, competition.CompetitionState_Aborted, abortedCompetition.GetState())
+}
+
+func TestMatchmakingService_ConcurrentJoinsAndLeaves(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 10,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     100 * time.Millisecond,
+		LevelMatchingTolerance: 3,
+		LevelRangeWidening: LevelRangeWideningConfig{
+			Step:        1,
+			Interval:    20 * time.Millisecond,
+			MaxWidening: 3,
+		},
+		RequeuePolicy: RequeuePolicy{MaxRetries: 1},
+	})
+
+	const numberOfPlayers = 3000
+	lastStates := make([]MatchmakingState, numberOfPlayers)
+	waitGroup := sync.WaitGroup{}
+	for i := range numberOfPlayers {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			playerID := fmt.Sprintf("test_user_%d", i)
+			notifications := joinPlayer(t, matchmakingService, model.PlayerData{ID: playerID, Level: i%50 + 1})
+			if i%10 == 0 {
+				matchmakingService.LeaveMatchmaking(playerID)
+			}
+			for notification := range notifications {
+				lastStates[i] = notification.State
+			}
+		}()
+	}
+	waitGroup.Wait()
+
+	// every player ends up in a started or aborted competition or has left, and nobody is left behind
+	for i, lastState := range lastStates {
+		assert.Contains(t, []MatchmakingState{State_Started, State_Aborted, State_Cancelled}, lastState, "player %d", i)
+	}
+	inspectMatchmakingService(matchmakingService, func() {
+		assert.Empty(t, matchmakingService.playersInMatchmaking)
+		assert.Empty(t, matchmakingService.competitionIDsOfPlayers)
+		assert.Empty(t, matchmakingService.competitionsInMatchmaking)
+	})
+}
+
+func TestMatchmakingService_GetPlayerStatus(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Minute,
+		LevelMatchingTolerance: 3,
+		Clock:                  fakeClock,
+	})
+
+	_, err := matchmakingService.GetPlayerStatus("test_user_1")
+	assert.ErrorIs(t, err, ErrPlayerNotInMatchmaking)
+
+	joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	status, err := matchmakingService.GetPlayerStatus("test_user_1")
+	assert.NoError(t, err)
+	assert.Equal(t, PlayerStatus{
+		PlayerID:      "test_user_1",
+		CompetitionID: 1,
+		State:         State_WaitingForPlayers,
+		QueuedAt:      fakeClock.Now(),
+	}, status)
+
+	// a player placed in a started competition has left matchmaking
+	joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	_, err = matchmakingService.GetPlayerStatus("test_user_1")
+	assert.ErrorIs(t, err, ErrPlayerNotInMatchmaking)
+}
+
+func TestMatchmakingService_ListenerNotReadingIsDetached(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Minute,
+		LevelMatchingTolerance: 3,
+	})
+
+	// the player never reads its notifications, so the buffer fills up
+	stalledPlayer := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	inspectMatchmakingService(matchmakingService, func() {
+		for range notificationChannelBufferSize {
+			matchmakingService.sendNotificationToPlayer("test_user_1", MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers})
+		}
+	})
+
+	// the loop keeps serving the other players and the stalled player is removed from matchmaking
+	otherPlayer := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-otherPlayer).State)
+	assert.Eventually(t, func() bool {
+		_, err := matchmakingService.GetPlayerStatus("test_user_1")
+		return errors.Is(err, ErrPlayerNotInMatchmaking)
+	}, time.Second, 10*time.Millisecond)
+
+	// the buffered notifications are delivered before the channel is closed
+	for range notificationChannelBufferSize {
+		assert.Equal(t, State_WaitingForPlayers, (<-stalledPlayer).State)
+	}
+	_, open := <-stalledPlayer
+	assert.False(t, open, "notification channel of the stalled listener should be closed")
+}
@@ -0,0 +1,133 @@
+package matchmaking
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
+)
+
+func (m *matchmakingService) validateParty(members []model.PlayerData) error {
+	if len(members) == 0 {
+		return ErrEmptyParty
+	}
+	if len(members) > m.config.CompetitionConfig.GetMaxPlayerCount() {
+		return ErrPartyTooLarge
+	}
+
+	memberIDs := make(map[string]bool, len(members))
+	for _, member := range members {
+		if memberIDs[member.ID] {
+			return fmt.Errorf("%w: %s", ErrDuplicatePartyMember, member.ID)
+		}
+		memberIDs[member.ID] = true
+
+		if _, exists := m.playersInMatchmaking[member.ID]; exists {
+			return fmt.Errorf("%w: %s", ErrPlayerAlreadyInMatchmaking, member.ID)
+		}
+	}
+	return nil
+}
+
+// handlePartyJoin sends a party's join request to the matchmaking loop and waits until it has been handled
+func (m *matchmakingService) handlePartyJoin(members []model.PlayerData) (map[string]<-chan MatchMakingNotification, error) {
+	party := make([]model.PlayerData, len(members))
+	for i, member := range members {
+		party[i] = m.withPlayerRating(member)
+	}
+
+	reply := make(chan partyJoinResult)
+	if !m.sendCommand(partyJoinCommand{members: party, reply: reply}) {
+		return nil, ErrMatchmakingShutDown
+	}
+	result := <-reply
+	return result.notificationChans, result.err
+}
+
+// handlePartyJoinRequest registers the members of a joining party and places them in a competition together
+// @param party the members of the party
+// @return the notification channels of the members by player id, or an error if the party cannot join
+func (m *matchmakingService) handlePartyJoinRequest(party []model.PlayerData) (map[string]<-chan MatchMakingNotification, error) {
+	if err := m.validateParty(party); err != nil {
+		return nil, err
+	}
+
+	m.nextPartyID++
+	partyID := fmt.Sprintf("party_%d", m.nextPartyID)
+	queuedAt := m.clock.Now()
+	notificationChans := make(map[string]<-chan MatchMakingNotification, len(party))
+
+	for _, member := range party {
+		notificationChan := make(chan MatchMakingNotification, notificationChannelBufferSize)
+		m.playersInMatchmaking[member.ID] = playerInMatchmaking{
+			PlayerData:        member,
+			notificationChans: []chan MatchMakingNotification{notificationChan},
+			queuedAt:          queuedAt,
+			partyID:           partyID,
+		}
+		notificationChans[member.ID] = notificationChan
+		m.publishEvent(PlayerQueuedEvent{
+			Time:     queuedAt,
+			PlayerID: member.ID,
+			Level:    member.Level,
+			PartyID:  partyID,
+		})
+	}
+
+	slog.Info("Party joined matchmaking", "party_id", partyID, "members", len(party))
+
+	m.processMatchmakingStateMutation(stateChangeNotification{
+		origin: matchmakingStateChangeOrigin_PartyAdd,
+		party:  party,
+	})
+	return notificationChans, nil
+}
+
+// getPartyMatchingData combines the levels, ratings and pings of party members into the data the party is matched with
+// @param members the members of the party
+// @return player data with the party's level, rating and pings
+func (m *matchmakingService) getPartyMatchingData(members []model.PlayerData) model.PlayerData {
+	ratings := make([]rating.Rating, len(members))
+	for i, member := range members {
+		ratings[i] = rating.OfPlayer(member)
+	}
+	partyRating := rating.Aggregate(ratings)
+
+	return model.PlayerData{
+		ID:              m.playersInMatchmaking[members[0].ID].partyID,
+		Level:           m.aggregatePartyLevel(members),
+		Rating:          partyRating.Value,
+		RatingDeviation: partyRating.Deviation,
+		Pings:           combinePartyPings(members),
+	}
+}
+
+func (m *matchmakingService) aggregatePartyLevel(members []model.PlayerData) int {
+	levelSum := 0
+	maxLevel := members[0].Level
+	for _, member := range members {
+		levelSum += member.Level
+		maxLevel = max(maxLevel, member.Level)
+	}
+	averageLevel := float64(levelSum) / float64(len(members))
+
+	switch m.config.Party.LevelAggregation {
+	case PartyLevelAggregation_Max:
+		return maxLevel
+	case PartyLevelAggregation_Weighted:
+		maxLevelWeight := min(max(m.config.Party.MaxLevelWeight, 0), 1)
+		return int(math.Round((1-maxLevelWeight)*averageLevel + maxLevelWeight*float64(maxLevel)))
+	default:
+		return int(math.Round(averageLevel))
+	}
+}
+
+// handleAddingPartyToCompetition places all members of a party in the same competition
+// @param members the members of the party waiting for a competition
+// @return the competition that the party was added to, or an error if the party could not be added to any competition
+func (m *matchmakingService) handleAddingPartyToCompetition(members []model.PlayerData) (competition.Competition, error) {
+	return m.handleAddingPlayersToCompetition(m.getPartyMatchingData(members), members)
+}
@@ -0,0 +1,166 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchmakingService_PartyIsPlacedTogether(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 4,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+	})
+
+	solo := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-solo)
+
+	party, err := matchmakingService.HandlePartyJoin([]model.PlayerData{
+		{ID: "test_user_2", Level: 4},
+		{ID: "test_user_3", Level: 6},
+		{ID: "test_user_4", Level: 5},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, party, 3)
+
+	// every member receives the same updates
+	for _, notifications := range party {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-notifications)
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-notifications)
+	}
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-solo)
+}
+
+func TestMatchmakingService_PartyOnlyJoinsCompetitionWithRoom(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-second).State)
+
+	party, err := matchmakingService.HandlePartyJoin([]model.PlayerData{
+		{ID: "test_user_3", Level: 5},
+		{ID: "test_user_4", Level: 5},
+	})
+	assert.NoError(t, err)
+
+	for _, notifications := range party {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-notifications)
+	}
+}
+
+func TestMatchmakingService_InvalidParties(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+	})
+
+	_, err := matchmakingService.HandlePartyJoin(nil)
+	assert.ErrorIs(t, err, ErrEmptyParty)
+
+	_, err = matchmakingService.HandlePartyJoin([]model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 5},
+		{ID: "test_user_3", Level: 5},
+	})
+	assert.ErrorIs(t, err, ErrPartyTooLarge)
+
+	_, err = matchmakingService.HandlePartyJoin([]model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_1", Level: 5},
+	})
+	assert.ErrorIs(t, err, ErrDuplicatePartyMember)
+
+	solo := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-solo).State)
+	_, err = matchmakingService.HandlePartyJoin([]model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 5},
+	})
+	assert.ErrorIs(t, err, ErrPlayerAlreadyInMatchmaking)
+}
+
+func TestMatchmakingService_PartyLevelAggregation(t *testing.T) {
+	members := []model.PlayerData{
+		{ID: "test_user_1", Level: 2},
+		{ID: "test_user_2", Level: 4},
+		{ID: "test_user_3", Level: 12},
+	}
+
+	testCases := []struct {
+		partyConfig   PartyConfig
+		expectedLevel int
+	}{
+		{PartyConfig{}, 6},
+		{PartyConfig{LevelAggregation: PartyLevelAggregation_Average}, 6},
+		{PartyConfig{LevelAggregation: PartyLevelAggregation_Max}, 12},
+		{PartyConfig{LevelAggregation: PartyLevelAggregation_Weighted, MaxLevelWeight: 0.5}, 9},
+		{PartyConfig{LevelAggregation: PartyLevelAggregation_Weighted, MaxLevelWeight: 0.25}, 8},
+	}
+
+	for _, testCase := range testCases {
+		matchmakingService := newMatchmakingService(MatchmakingConfig{
+			CompetitionConfig: competition.CompetitionConfig{
+				MaxPlayerCount: 10,
+				MinPlayerCount: 2,
+			},
+			MatchmakingTimeout:     3 * time.Second,
+			LevelMatchingTolerance: 3,
+			Party:                  testCase.partyConfig,
+		})
+		assert.Equal(t, testCase.expectedLevel, matchmakingService.aggregatePartyLevel(members), testCase.partyConfig)
+	}
+}
+
+func TestMatchmakingService_PartyIsRequeuedTogether(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 5,
+			MinPlayerCount: 3,
+		},
+		MatchmakingTimeout:     100 * time.Millisecond,
+		LevelMatchingTolerance: 3,
+		RequeuePolicy:          RequeuePolicy{MaxRetries: 1},
+		Clock:                  fakeClock,
+	})
+
+	party, err := matchmakingService.HandlePartyJoin([]model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 6},
+	})
+	assert.NoError(t, err)
+
+	for _, notifications := range party {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-notifications)
+	}
+	fakeClock.Advance(100 * time.Millisecond)
+	for _, notifications := range party {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Requeued}, <-notifications)
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-notifications)
+	}
+	fakeClock.Advance(100 * time.Millisecond)
+	for _, notifications := range party {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_Aborted}, <-notifications)
+	}
+}
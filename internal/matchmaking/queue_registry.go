@@ -0,0 +1,59 @@
+package matchmaking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+)
+
+type queueRegistry struct {
+	queues map[string]*matchmakingService
+}
+
+func newQueueRegistry(queueConfigs map[string]MatchmakingConfig) *queueRegistry {
+	competitionIDs := &competitionIDSequence{}
+	queues := make(map[string]*matchmakingService, len(queueConfigs))
+	for name, config := range queueConfigs {
+		queues[name] = newMatchmakingServiceWithCompetitionIDs(config, competitionIDs)
+		slog.Info("Registered matchmaking queue", "queue", name)
+	}
+	return &queueRegistry{queues: queues}
+}
+
+func (r *queueRegistry) getQueue(name string) (MatchmakingService, error) {
+	if name == "" {
+		name = DefaultQueueName
+	}
+	queue, exists := r.queues[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQueue, name)
+	}
+	return queue, nil
+}
+
+func (r *queueRegistry) getQueueNames() []string {
+	return slices.Sorted(maps.Keys(r.queues))
+}
+
+func (r *queueRegistry) shutdown(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make([]error, 0, len(r.queues))
+	var errsMutex sync.Mutex
+	for name, queue := range r.queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := queue.shutdown(ctx); err != nil {
+				errsMutex.Lock()
+				errs = append(errs, fmt.Errorf("queue %s: %w", name, err))
+				errsMutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,48 @@
+package matchmaking
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultQueueName is the name of the queue used when a join request does not name a queue
+const DefaultQueueName = "default"
+
+// ErrUnknownQueue is returned when a queue is requested by a name that is not registered
+var ErrUnknownQueue = errors.New("unknown matchmaking queue")
+
+// QueueRegistry holds named matchmaking queues that run side by side, for example ranked, casual and event modes
+// Every queue has its own configuration and its own competitions. Competition ids are unique across all queues
+type QueueRegistry interface {
+	// GetQueue returns the matchmaking service of a queue
+	// @param name the name of the queue. DefaultQueueName is used if empty
+	// @return the matchmaking service of the queue, or ErrUnknownQueue if no queue has the name
+	GetQueue(name string) (MatchmakingService, error)
+
+	// GetQueueNames returns the names of the registered queues in alphabetical order
+	GetQueueNames() []string
+
+	// Shutdown shuts down every queue at the same time
+	// @param ctx the deadline for the shutdown
+	// @return the errors of the queues that did not shut down before the deadline
+	Shutdown(ctx context.Context) error
+}
+
+// NewQueueRegistry creates a matchmaking service for every configured queue
+// @param queueConfigs the configuration of each queue by queue name
+// @return a new queue registry
+func NewQueueRegistry(queueConfigs map[string]MatchmakingConfig) QueueRegistry {
+	return newQueueRegistry(queueConfigs)
+}
+
+func (r *queueRegistry) GetQueue(name string) (MatchmakingService, error) {
+	return r.getQueue(name)
+}
+
+func (r *queueRegistry) GetQueueNames() []string {
+	return r.getQueueNames()
+}
+
+func (r *queueRegistry) Shutdown(ctx context.Context) error {
+	return r.shutdown(ctx)
+}
@@ -0,0 +1,64 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueRegistry_QueuesAreIndependent(t *testing.T) {
+	registry := NewQueueRegistry(map[string]MatchmakingConfig{
+		"ranked": {
+			CompetitionConfig: competition.CompetitionConfig{
+				MaxPlayerCount: 2,
+				MinPlayerCount: 2,
+			},
+			MatchmakingTimeout:     3 * time.Second,
+			LevelMatchingTolerance: 3,
+		},
+		"casual": {
+			CompetitionConfig: competition.CompetitionConfig{
+				MaxPlayerCount: 3,
+				MinPlayerCount: 2,
+			},
+			MatchmakingTimeout:     3 * time.Second,
+			LevelMatchingTolerance: 10,
+		},
+	})
+	assert.Equal(t, []string{"casual", "ranked"}, registry.GetQueueNames())
+
+	ranked, err := registry.GetQueue("ranked")
+	assert.NoError(t, err)
+	casual, err := registry.GetQueue("casual")
+	assert.NoError(t, err)
+
+	rankedPlayer := joinPlayer(t, ranked, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-rankedPlayer)
+
+	// competitions are not shared between queues and competition ids are unique across queues
+	casualPlayer := joinPlayer(t, casual, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-casualPlayer)
+
+	secondCasualPlayer := joinPlayer(t, casual, model.PlayerData{ID: "test_user_3", Level: 14})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-secondCasualPlayer)
+
+	secondRankedPlayer := joinPlayer(t, ranked, model.PlayerData{ID: "test_user_4", Level: 6})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-secondRankedPlayer)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-secondRankedPlayer)
+}
+
+func TestQueueRegistry_UnknownQueue(t *testing.T) {
+	registry := NewQueueRegistry(map[string]MatchmakingConfig{
+		DefaultQueueName: {},
+	})
+
+	_, err := registry.GetQueue("event")
+	assert.ErrorIs(t, err, ErrUnknownQueue)
+
+	defaultQueue, err := registry.GetQueue("")
+	assert.NoError(t, err)
+	assert.NotNil(t, defaultQueue)
+}
@@ -0,0 +1,198 @@
+package matchmaking
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+)
+
+// readyCheck is a competition whose players must confirm they are ready before it starts
+// The competition does not accept players during the ready check
+type readyCheck struct {
+	competitionData
+
+	// confirmedPlayerIDs are the players that have confirmed they are ready
+	confirmedPlayerIDs map[string]struct{}
+	// numberOfPlayers is the number of players the competition had when the ready check started
+	numberOfPlayers int
+	// readyCheckCancel is closed when the ready check is completed before the window expires
+	readyCheckCancel chan struct{}
+}
+
+func (m *matchmakingService) isReadyCheckEnabled() bool {
+	return m.config.ReadyCheck.Window > 0
+}
+
+func (m *matchmakingService) confirmReady(playerID string) {
+	m.sendCommand(readyConfirmCommand{playerID: playerID})
+}
+
+// startReadyCheck takes a competition that is ready to start out of matchmaking and asks its players to confirm
+// The competition starts when every player has confirmed before the ready check window expires
+// Players that confirmed an earlier ready check of a backfilled competition keep their confirmation
+// @param competition the competition to start the ready check for
+func (m *matchmakingService) startReadyCheck(competition competition.Competition) {
+	competitionData := m.competitionsInMatchmaking[competition.GetID()]
+	m.closeTimeoutCancelChannelForCompetition(competition)
+	m.unregisterCompetitionFromMatchmakingStage(competition)
+	m.transitionCompetition(competition, competitionState_ReadyCheck)
+
+	check := &readyCheck{
+		competitionData:    competitionData,
+		confirmedPlayerIDs: make(map[string]struct{}),
+		numberOfPlayers:    competition.GetNumberOfJoinedPlayers(),
+		readyCheckCancel:   make(chan struct{}),
+	}
+	m.readyChecks[competition.GetID()] = check
+
+	slog.Info("Starting ready check", "id", competition.GetID(), "window", m.config.ReadyCheck.Window)
+
+	for _, player := range competition.GetPlayers() {
+		if _, ready := competitionData.readyPlayerIDs[player.ID]; ready {
+			check.confirmedPlayerIDs[player.ID] = struct{}{}
+			continue
+		}
+		m.sendNotificationToPlayer(player.ID, MatchMakingNotification{
+			CompetitionID: competition.GetID(),
+			State:         State_ReadyCheck,
+		})
+	}
+
+	window := m.clock.After(m.config.ReadyCheck.Window)
+	m.startGoroutine(func() {
+		m.startReadyCheckTimer(check, window)
+	})
+	m.completeReadyCheckIfConfirmed(check)
+}
+
+func (m *matchmakingService) startReadyCheckTimer(check *readyCheck, window <-chan time.Time) {
+	select {
+	case <-window:
+		m.sendCommand(stateChangeNotification{
+			origin:      matchmakingStateChangeOrigin_ReadyCheckTimeout,
+			competition: check.Competition,
+			readyCheck:  check,
+		})
+	case <-check.readyCheckCancel:
+		return
+	}
+}
+
+// handleReadyConfirmation records that a player in a ready check is ready
+// The competition starts once every remaining player has confirmed
+// @param playerID the id of the player confirming
+func (m *matchmakingService) handleReadyConfirmation(playerID string) {
+	competitionID, placed := m.competitionIDsOfPlayers[playerID]
+	check, inReadyCheck := m.readyChecks[competitionID]
+	if !placed || !inReadyCheck {
+		slog.Info("Player is not in a ready check. Ignoring ready confirmation", "player_id", playerID)
+		return
+	}
+
+	check.confirmedPlayerIDs[playerID] = struct{}{}
+	slog.Info("Player confirmed ready check", "id", competitionID, "player_id", playerID)
+
+	m.completeReadyCheckIfConfirmed(check)
+}
+
+// completeReadyCheckIfConfirmed completes a ready check once every player still in the competition has confirmed
+// The competition is started if no player was lost during the ready check, otherwise it is backfilled
+func (m *matchmakingService) completeReadyCheckIfConfirmed(check *readyCheck) {
+	if len(check.confirmedPlayerIDs) < check.GetNumberOfJoinedPlayers() {
+		return
+	}
+	if check.GetNumberOfJoinedPlayers() < check.numberOfPlayers {
+		m.backfillCompetition(check)
+		return
+	}
+
+	close(check.readyCheckCancel)
+	delete(m.readyChecks, check.GetID())
+	slog.Info("All players confirmed ready check. Starting competition", "id", check.GetID())
+	m.commitCompetition(check.competitionData)
+}
+
+// handleReadyCheckTimeout removes the players that did not confirm in time
+// The competition is backfilled with the players that confirmed, or dropped if nobody confirmed
+// @param check the ready check whose window expired
+func (m *matchmakingService) handleReadyCheckTimeout(check *readyCheck) {
+	// The ready check may have been completed while the timeout was in flight
+	if m.readyChecks[check.GetID()] != check {
+		return
+	}
+
+	for _, player := range m.getPlayersInOrderOfQueueTime(check.Competition) {
+		if _, confirmed := check.confirmedPlayerIDs[player.ID]; confirmed {
+			continue
+		}
+		if err := check.RemovePlayer(player.ID); err != nil {
+			slog.Error("Failed to remove player from ready check", "id", check.GetID(), "player_id", player.ID, "error", err)
+		}
+		slog.Info("Player did not confirm ready check", "id", check.GetID(), "player_id", player.ID)
+		m.sendNotificationToPlayer(player.ID, MatchMakingNotification{
+			CompetitionID: check.GetID(),
+			State:         State_ReadyCheckMissed,
+		})
+		m.unregisterPlayerFromMatchmakingStage(player.ID)
+	}
+
+	if check.GetNumberOfJoinedPlayers() == 0 {
+		m.dropReadyCheck(check)
+		return
+	}
+	m.backfillCompetition(check)
+}
+
+// removePlayerFromReadyCheck removes a player leaving matchmaking from the competition of a ready check
+// @param check the ready check the player is in
+// @param playerID the id of the player leaving
+func (m *matchmakingService) removePlayerFromReadyCheck(check *readyCheck, playerID string) {
+	if err := check.RemovePlayer(playerID); err != nil {
+		slog.Error("Failed to remove player from ready check", "id", check.GetID(), "player_id", playerID, "error", err)
+	}
+	delete(check.confirmedPlayerIDs, playerID)
+
+	if check.GetNumberOfJoinedPlayers() == 0 {
+		m.dropReadyCheck(check)
+		return
+	}
+	m.completeReadyCheckIfConfirmed(check)
+}
+
+func (m *matchmakingService) dropReadyCheck(check *readyCheck) {
+	close(check.readyCheckCancel)
+	delete(m.readyChecks, check.GetID())
+	m.transitionCompetition(check.Competition, competitionState_Aborted)
+	m.publishCompetitionAborted(check.Competition)
+	slog.Info("No players left in ready check. Aborting competition", "id", check.GetID())
+}
+
+// backfillCompetition puts the competition of a ready check back into matchmaking to replace the lost players
+// The competition keeps its id, creation time and level range, and the players keep their queue time,
+// so the players that confirmed do not lose their priority. They also keep their confirmation for the next ready check.
+// The competition waits for a new matchmaking timeout and is aborted as usual if it does not reach the minimum player count
+// @param check the ready check of the competition
+func (m *matchmakingService) backfillCompetition(check *readyCheck) {
+	close(check.readyCheckCancel)
+	delete(m.readyChecks, check.GetID())
+
+	slog.Info("Backfilling competition after ready check", "id", check.GetID(), "players", check.GetNumberOfJoinedPlayers())
+	m.transitionCompetition(check.Competition, competitionState_Matchmaking)
+
+	timeoutCancel := make(chan struct{})
+	competitionData := check.competitionData
+	competitionData.timeoutCancel = timeoutCancel
+	competitionData.readyPlayerIDs = check.confirmedPlayerIDs
+	m.competitionsInMatchmaking[check.GetID()] = competitionData
+	m.competitionLevelIndex.add(check.Competition)
+
+	for _, player := range check.GetPlayers() {
+		m.sendNotificationToPlayer(player.ID, MatchMakingNotification{
+			CompetitionID: check.GetID(),
+			State:         State_WaitingForPlayers,
+		})
+	}
+
+	m.startCompetitionTimers(check.Competition, timeoutCancel)
+}
@@ -0,0 +1,239 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// joinReadyCheckTestPlayers joins the players one by one and consumes their waiting notification
+func joinReadyCheckTestPlayers(t *testing.T, matchmakingService *matchmakingService, players []model.PlayerData) map[string]<-chan MatchMakingNotification {
+	notificationChans := make(map[string]<-chan MatchMakingNotification, len(players))
+	for _, playerData := range players {
+		notificationChans[playerData.ID] = joinPlayer(t, matchmakingService, playerData)
+		assert.Equal(t, State_WaitingForPlayers, (<-notificationChans[playerData.ID]).State)
+	}
+	return notificationChans
+}
+
+func TestMatchmakingService_CompetitionStartsWhenAllPlayersAreReady(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Second,
+		LevelMatchingTolerance: 3,
+		ReadyCheck:             ReadyCheckConfig{Window: time.Second},
+		Clock:                  clock.NewFakeClock(time.Now()),
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-first)
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-second)
+
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_ReadyCheck}, <-first)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_ReadyCheck}, <-second)
+
+	matchmakingService.ConfirmReady("test_user_1")
+	matchmakingService.ConfirmReady("test_user_2")
+
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-first)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-second)
+}
+
+func TestMatchmakingService_CompetitionInReadyCheckDoesNotAcceptPlayers(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Second,
+		LevelMatchingTolerance: 3,
+		ReadyCheck:             ReadyCheckConfig{Window: time.Second},
+		Clock:                  clock.NewFakeClock(time.Now()),
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-second).State)
+	assert.Equal(t, State_ReadyCheck, (<-first).State)
+
+	third := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-third)
+}
+
+func TestMatchmakingService_PlayersMissingReadyCheckAreRemovedAndCompetitionIsBackfilled(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Second,
+		LevelMatchingTolerance: 3,
+		ReadyCheck:             ReadyCheckConfig{Window: 300 * time.Millisecond},
+		Clock:                  fakeClock,
+	})
+
+	players := joinReadyCheckTestPlayers(t, matchmakingService, []model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 5},
+		{ID: "test_user_3", Level: 5},
+	})
+	for _, notifications := range players {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_ReadyCheck}, <-notifications)
+	}
+
+	matchmakingService.ConfirmReady("test_user_1")
+	matchmakingService.ConfirmReady("test_user_2")
+	fakeClock.Advance(300 * time.Millisecond)
+
+	missed, ok := <-players["test_user_3"]
+	assert.True(t, ok)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_ReadyCheckMissed}, missed)
+	_, ok = <-players["test_user_3"]
+	assert.False(t, ok, "notification channel of the removed player should be closed")
+
+	// the players who confirmed wait for a replacement in the same competition
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-players["test_user_1"])
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-players["test_user_2"])
+
+	replacement := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_4", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-replacement)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_ReadyCheck}, <-replacement)
+}
+
+func TestMatchmakingService_PlayersKeepTheirConfirmationWhenCompetitionIsBackfilled(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Second,
+		LevelMatchingTolerance: 3,
+		ReadyCheck:             ReadyCheckConfig{Window: 300 * time.Millisecond},
+		Clock:                  fakeClock,
+	})
+
+	players := joinReadyCheckTestPlayers(t, matchmakingService, []model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 5},
+		{ID: "test_user_3", Level: 5},
+	})
+	for _, notifications := range players {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_ReadyCheck}, <-notifications)
+	}
+
+	matchmakingService.ConfirmReady("test_user_1")
+	matchmakingService.ConfirmReady("test_user_2")
+	matchmakingService.LeaveMatchmaking("test_user_3")
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-players["test_user_1"])
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-players["test_user_2"])
+
+	// only the replacement is asked to confirm
+	replacement := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_4", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-replacement)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_ReadyCheck}, <-replacement)
+	matchmakingService.ConfirmReady("test_user_4")
+
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-players["test_user_1"])
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-players["test_user_2"])
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-replacement)
+}
+
+func TestMatchmakingService_BackfilledCompetitionStartsOnTimeoutIfEveryPlayerConfirmed(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Second,
+		LevelMatchingTolerance: 3,
+		ReadyCheck:             ReadyCheckConfig{Window: 300 * time.Millisecond},
+		Clock:                  fakeClock,
+	})
+
+	players := joinReadyCheckTestPlayers(t, matchmakingService, []model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 5},
+		{ID: "test_user_3", Level: 5},
+	})
+	for _, notifications := range players {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_ReadyCheck}, <-notifications)
+	}
+
+	matchmakingService.ConfirmReady("test_user_1")
+	matchmakingService.ConfirmReady("test_user_2")
+	matchmakingService.LeaveMatchmaking("test_user_3")
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-players["test_user_1"])
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-players["test_user_2"])
+
+	// the competition has the minimum number of confirmed players when the timeout expires
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-players["test_user_1"])
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-players["test_user_2"])
+}
+
+func TestMatchmakingService_PlayerLeavingReadyCheck(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Second,
+		LevelMatchingTolerance: 3,
+		ReadyCheck:             ReadyCheckConfig{Window: time.Second},
+		Clock:                  clock.NewFakeClock(time.Now()),
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-second).State)
+	assert.Equal(t, State_ReadyCheck, (<-first).State)
+	assert.Equal(t, State_ReadyCheck, (<-second).State)
+
+	matchmakingService.ConfirmReady("test_user_1")
+	matchmakingService.LeaveMatchmaking("test_user_2")
+
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Cancelled}, <-second)
+	// the remaining player has confirmed, so the competition is backfilled without waiting for the window
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-first)
+}
+
+func TestMatchmakingService_ReadyCheckWithoutConfirmationsDropsCompetition(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Second,
+		LevelMatchingTolerance: 3,
+		ReadyCheck:             ReadyCheckConfig{Window: 200 * time.Millisecond},
+		Clock:                  fakeClock,
+	})
+
+	players := joinReadyCheckTestPlayers(t, matchmakingService, []model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 5},
+	})
+	fakeClock.Advance(200 * time.Millisecond)
+	for _, notifications := range players {
+		assert.Equal(t, State_ReadyCheck, (<-notifications).State)
+		assert.Equal(t, State_ReadyCheckMissed, (<-notifications).State)
+	}
+
+	// the dropped competition no longer accepts players
+	next := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-next)
+}
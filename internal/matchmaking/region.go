@@ -0,0 +1,85 @@
+package matchmaking
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+)
+
+func (m *matchmakingService) isRegionMatchingEnabled() bool {
+	return m.config.RegionMatching.MaxPing > 0
+}
+
+// getPingThreshold returns the highest accepted ping after the given wait time
+// @param waitTime the time waited in matchmaking
+// @return the ping threshold in milliseconds
+func (m *matchmakingService) getPingThreshold(waitTime time.Duration) int {
+	regionMatching := m.config.RegionMatching
+	if regionMatching.PingRelaxationStep <= 0 || regionMatching.PingRelaxationInterval <= 0 {
+		return regionMatching.MaxPing
+	}
+	relaxation := min(int(waitTime/regionMatching.PingRelaxationInterval)*regionMatching.PingRelaxationStep, regionMatching.MaxPingRelaxation)
+	return regionMatching.MaxPing + max(relaxation, 0)
+}
+
+// isPlayerPingMatching checks if the player's ping to the region of a competition is under the threshold
+// The threshold is relaxed by the longer wait of the player and the competition
+// A competition that is not bound to a region was created for a player without pings and only accepts
+// players without pings, so players that measured their pings are never placed outside their regions
+// @param playerData the player to check
+// @param queuedAt the time the player joined matchmaking
+// @param competitionData the competition to check
+// @return true if the player can be placed in the competition, false otherwise
+func (m *matchmakingService) isPlayerPingMatching(playerData model.PlayerData, queuedAt time.Time, competitionData competitionData) bool {
+	region := competitionData.GetRegion()
+	if region == "" {
+		return len(playerData.Pings) == 0
+	}
+	ping, measured := playerData.Pings[region]
+	if !measured {
+		return false
+	}
+
+	waitedSince := queuedAt
+	if competitionData.createdAt.Before(waitedSince) {
+		waitedSince = competitionData.createdAt
+	}
+	return ping <= m.getPingThreshold(m.clock.Now().Sub(waitedSince))
+}
+
+// selectRegion returns the region the player has the lowest ping to
+// Ties are broken by the region name, so the selection is deterministic
+// @param playerData the player to select the region for
+// @return the region, or empty if the player has no measured pings
+func selectRegion(playerData model.PlayerData) string {
+	regions := slices.Sorted(maps.Keys(playerData.Pings))
+	if len(regions) == 0 {
+		return ""
+	}
+	return slices.MinFunc(regions, func(a, b string) int {
+		return cmp.Compare(playerData.Pings[a], playerData.Pings[b])
+	})
+}
+
+// combinePartyPings returns the pings a party is matched with
+// A region is reachable by the party only if every member has a ping to it, and the ping of the party
+// to a region is the highest ping of its members
+// @param members the members of the party
+// @return the pings of the party by region
+func combinePartyPings(members []model.PlayerData) map[string]int {
+	partyPings := maps.Clone(members[0].Pings)
+	for _, member := range members[1:] {
+		for region, ping := range partyPings {
+			memberPing, measured := member.Pings[region]
+			if !measured {
+				delete(partyPings, region)
+				continue
+			}
+			partyPings[region] = max(ping, memberPing)
+		}
+	}
+	return partyPings
+}
@@ -0,0 +1,165 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectRegion(t *testing.T) {
+	assert.Equal(t, "us-east", selectRegion(model.PlayerData{Pings: map[string]int{"eu-west": 80, "us-east": 20, "ap-south": 200}}))
+	assert.Equal(t, "ap-south", selectRegion(model.PlayerData{Pings: map[string]int{"eu-west": 40, "ap-south": 40}}), "ties are broken by region name")
+	assert.Empty(t, selectRegion(model.PlayerData{}))
+}
+
+func TestCombinePartyPings(t *testing.T) {
+	partyPings := combinePartyPings([]model.PlayerData{
+		{ID: "test_user_1", Pings: map[string]int{"eu-west": 30, "us-east": 90, "ap-south": 150}},
+		{ID: "test_user_2", Pings: map[string]int{"eu-west": 50, "us-east": 70}},
+	})
+	assert.Equal(t, map[string]int{"eu-west": 50, "us-east": 90}, partyPings)
+}
+
+func TestGetPingThreshold(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		RegionMatching: RegionMatchingConfig{
+			MaxPing:                50,
+			PingRelaxationStep:     20,
+			PingRelaxationInterval: time.Second,
+			MaxPingRelaxation:      50,
+		},
+	})
+	assert.Equal(t, 50, matchmakingService.getPingThreshold(500*time.Millisecond))
+	assert.Equal(t, 90, matchmakingService.getPingThreshold(2*time.Second))
+	assert.Equal(t, 100, matchmakingService.getPingThreshold(time.Minute), "relaxation is capped")
+}
+
+func TestMatchmakingService_PlayersAreOnlyMatchedInReachableRegions(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		RegionMatching:         RegionMatchingConfig{MaxPing: 60},
+	})
+
+	european := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5, Pings: map[string]int{"eu-west": 20, "us-east": 100}})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-european)
+
+	american := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5, Pings: map[string]int{"eu-west": 110, "us-east": 15}})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-american)
+
+	unmeasured := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5, Pings: map[string]int{"us-east": 40}})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-unmeasured)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_Started, Region: "us-east"}, <-unmeasured)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_Started, Region: "us-east"}, <-american)
+}
+
+func TestMatchmakingService_PlayersWithoutPingsAreOnlyMatchedWithEachOther(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		RegionMatching:         RegionMatchingConfig{MaxPing: 60},
+	})
+
+	unmeasured := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-unmeasured)
+
+	european := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5, Pings: map[string]int{"eu-west": 20}})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-european)
+
+	alsoUnmeasured := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_3", Level: 5})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-alsoUnmeasured)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-alsoUnmeasured)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-unmeasured)
+}
+
+func TestMatchmakingService_PingThresholdRelaxesWithWaitTime(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		RegionMatching: RegionMatchingConfig{
+			MaxPing:                30,
+			PingRelaxationStep:     50,
+			PingRelaxationInterval: 500 * time.Millisecond,
+			MaxPingRelaxation:      50,
+		},
+		Clock: fakeClock,
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5, Pings: map[string]int{"eu-west": 10}})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-first)
+
+	// the competition has waited long enough for the threshold to be relaxed to 80 milliseconds
+	fakeClock.Advance(500 * time.Millisecond)
+
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5, Pings: map[string]int{"eu-west": 70}})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-second)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started, Region: "eu-west"}, <-second)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started, Region: "eu-west"}, <-first)
+}
+
+func TestMatchmakingService_PartyIsMatchedWithItsWorstPing(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		RegionMatching:         RegionMatchingConfig{MaxPing: 60},
+	})
+
+	solo := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5, Pings: map[string]int{"eu-west": 10}})
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-solo)
+
+	party, err := matchmakingService.HandlePartyJoin([]model.PlayerData{
+		{ID: "test_user_2", Level: 5, Pings: map[string]int{"eu-west": 20, "us-east": 50}},
+		{ID: "test_user_3", Level: 5, Pings: map[string]int{"eu-west": 90, "us-east": 40}},
+	})
+	assert.NoError(t, err)
+
+	// the second member is too far from eu-west, so the party opens a competition in us-east
+	for _, notifications := range party {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_WaitingForPlayers}, <-notifications)
+	}
+}
+
+func TestCompetitionSelector_LowestPing(t *testing.T) {
+	config := competition.CompetitionConfig{MaxPlayerCount: 10, MinPlayerCount: 2}
+	levelRange := competition.CompetitionLevelRange{Min: 1, Max: 7}
+	candidates := []competition.Competition{
+		competition.NewCompetitionInRegion(1, config, levelRange, "eu-west"),
+		competition.NewCompetitionInRegion(2, config, levelRange, "us-east"),
+		competition.NewCompetitionInRegion(3, config, levelRange, "ap-south"),
+	}
+
+	selected, found := NewScoringCompetitionSelector(LowestPingScorer).SelectCompetition(model.PlayerData{
+		ID:    "test_user_1",
+		Level: 4,
+		Pings: map[string]int{"eu-west": 60, "us-east": 35},
+	}, candidates)
+	assert.True(t, found)
+	assert.Equal(t, 2, selected.GetID())
+}
@@ -0,0 +1,152 @@
+package matchmaking
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/results"
+)
+
+// competitionInProgress is a started competition waiting for its result
+type competitionInProgress struct {
+	competition.Competition
+	startedAt time.Time
+	// reportWindowCancel is closed when the result is reported before the report window passes
+	reportWindowCancel chan struct{}
+}
+
+func (m *matchmakingService) getResultReportWindow() time.Duration {
+	if m.config.Results.ReportWindow > 0 {
+		return m.config.Results.ReportWindow
+	}
+	return DefaultResultReportWindow
+}
+
+// registerCompetitionInProgress keeps a started competition until its result is reported or its report window passes
+func (m *matchmakingService) registerCompetitionInProgress(startedCompetition competition.Competition) {
+	reportWindowCancel := make(chan struct{})
+	m.competitionsInProgress[startedCompetition.GetID()] = competitionInProgress{
+		Competition:        startedCompetition,
+		startedAt:          m.clock.Now(),
+		reportWindowCancel: reportWindowCancel,
+	}
+
+	window := m.clock.After(m.getResultReportWindow())
+	m.startGoroutine(func() {
+		m.startResultReportWindowForCompetition(startedCompetition, window, reportWindowCancel)
+	})
+}
+
+func (m *matchmakingService) startResultReportWindowForCompetition(competition competition.Competition, window <-chan time.Time, reportWindowCancel <-chan struct{}) {
+	select {
+	case <-window:
+		m.sendCommand(stateChangeNotification{
+			origin:      matchmakingStateChangeOrigin_ResultReportTimeout,
+			competition: competition,
+		})
+	case <-reportWindowCancel:
+		return
+	}
+}
+
+// handleResultReportTimeout aborts and forgets a competition whose result was not reported within the report window,
+// so that competitions whose game servers never report do not pile up
+// @param competition the competition whose report window passed
+func (m *matchmakingService) handleResultReportTimeout(competition competition.Competition) {
+	inProgress, exists := m.competitionsInProgress[competition.GetID()]
+	// the result may have been reported while the timeout was in flight
+	if !exists {
+		return
+	}
+	delete(m.competitionsInProgress, competition.GetID())
+	close(inProgress.reportWindowCancel)
+	m.stopBackfill(inProgress.Competition)
+	m.transitionCompetition(inProgress.Competition, competitionState_Aborted)
+	slog.Warn("No result reported within the report window. Aborting competition", "id", competition.GetID(), "started_at", inProgress.startedAt)
+}
+
+// stopResultReportWindows stops the report window timers of the competitions in progress when the service shuts down
+func (m *matchmakingService) stopResultReportWindows() {
+	for _, inProgress := range m.competitionsInProgress {
+		close(inProgress.reportWindowCancel)
+	}
+}
+
+func (m *matchmakingService) reportResult(competitionID int, playerResults []results.PlayerResult) error {
+	reply := make(chan error)
+	accepted := m.sendCommand(resultReportCommand{
+		competitionID: competitionID,
+		playerResults: playerResults,
+		reply:         reply,
+	})
+	if !accepted {
+		return ErrMatchmakingShutDown
+	}
+	return <-reply
+}
+
+// handleResultReport checks the reported result of a competition in progress against its roster, stores it
+// and publishes it to the result listeners. The competition is finished and forgotten by matchmaking
+// @param competitionID the id of the competition
+// @param playerResults the results of the players
+// @return an error if the competition is not in progress or the result does not match its roster
+func (m *matchmakingService) handleResultReport(competitionID int, playerResults []results.PlayerResult) error {
+	competitionInProgress, inProgress := m.competitionsInProgress[competitionID]
+	if !inProgress {
+		return fmt.Errorf("%w: %d", ErrUnknownCompetition, competitionID)
+	}
+	// the report window may have passed while its timeout is still in flight
+	if m.clock.Now().Sub(competitionInProgress.startedAt) >= m.getResultReportWindow() {
+		m.handleResultReportTimeout(competitionInProgress.Competition)
+		return fmt.Errorf("%w: %d", ErrUnknownCompetition, competitionID)
+	}
+	if err := validateResultRoster(competitionInProgress.Competition, playerResults); err != nil {
+		return err
+	}
+
+	result := results.CompetitionResult{
+		CompetitionID: competitionID,
+		Players:       playerResults,
+		ReportedAt:    m.clock.Now(),
+	}
+	if err := m.resultStore.SaveResult(result); err != nil {
+		return err
+	}
+
+	m.transitionCompetition(competitionInProgress, competitionState_Finished)
+	delete(m.competitionsInProgress, competitionID)
+	close(competitionInProgress.reportWindowCancel)
+	m.stopBackfill(competitionInProgress)
+
+	slog.Info("Competition finished", "id", competitionID, "results", playerResults)
+
+	for _, listener := range m.config.Results.Listeners {
+		listener.OnCompetitionResult(result)
+	}
+	return nil
+}
+
+// validateResultRoster checks that a result has exactly one valid entry for every player of the competition
+func validateResultRoster(reportedCompetition competition.Competition, playerResults []results.PlayerResult) error {
+	roster := reportedCompetition.GetPlayers()
+	reportedPlayerIDs := make(map[string]struct{}, len(playerResults))
+	for _, playerResult := range playerResults {
+		if playerResult.Placement < 1 {
+			return fmt.Errorf("%w: player %s has placement %d", ErrInvalidPlacement, playerResult.PlayerID, playerResult.Placement)
+		}
+		if _, reported := reportedPlayerIDs[playerResult.PlayerID]; reported {
+			return fmt.Errorf("%w: %s", ErrDuplicatePlayerResult, playerResult.PlayerID)
+		}
+		if _, joined := roster[playerResult.PlayerID]; !joined {
+			return fmt.Errorf("%w: player %s is not in competition %d", ErrResultRosterMismatch, playerResult.PlayerID, reportedCompetition.GetID())
+		}
+		reportedPlayerIDs[playerResult.PlayerID] = struct{}{}
+	}
+
+	if len(reportedPlayerIDs) != len(roster) {
+		return fmt.Errorf("%w: %d of %d players of competition %d reported", ErrResultRosterMismatch, len(reportedPlayerIDs), len(roster), reportedCompetition.GetID())
+	}
+	return nil
+}
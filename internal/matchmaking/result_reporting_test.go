@@ -0,0 +1,131 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/results"
+	"github.com/stretchr/testify/assert"
+)
+
+// startResultTestCompetition starts competition 1 with two players
+// @param matchmakingClock the clock of the service. The real clock is used if nil
+func startResultTestCompetition(t *testing.T, resultsConfig ResultsConfig, matchmakingClock clock.Clock) *matchmakingService {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     3 * time.Second,
+		LevelMatchingTolerance: 3,
+		Results:                resultsConfig,
+		Clock:                  matchmakingClock,
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-first).State)
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+	assert.Equal(t, State_WaitingForPlayers, (<-second).State)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-second)
+	return matchmakingService
+}
+
+func TestMatchmakingService_ReportResult(t *testing.T) {
+	store := results.NewResultStore()
+	published := make(chan results.CompetitionResult, 1)
+	matchmakingService := startResultTestCompetition(t, ResultsConfig{
+		Store: store,
+		Listeners: []results.ResultListener{results.ResultListenerFunc(func(result results.CompetitionResult) {
+			published <- result
+		})},
+	}, nil)
+
+	playerResults := []results.PlayerResult{
+		{PlayerID: "test_user_2", Placement: 1, Score: 21},
+		{PlayerID: "test_user_1", Placement: 2, Score: 15},
+	}
+	assert.NoError(t, matchmakingService.ReportResult(1, playerResults))
+
+	stored, found := store.GetResult(1)
+	assert.True(t, found)
+	assert.Equal(t, playerResults, stored.Players)
+	assert.False(t, stored.ReportedAt.IsZero())
+	assert.Equal(t, stored, <-published)
+
+	// a finished competition takes no further results
+	assert.ErrorIs(t, matchmakingService.ReportResult(1, playerResults), ErrUnknownCompetition)
+}
+
+func TestMatchmakingService_ReportResultIsCheckedAgainstRoster(t *testing.T) {
+	matchmakingService := startResultTestCompetition(t, ResultsConfig{}, nil)
+
+	assert.ErrorIs(t, matchmakingService.ReportResult(2, nil), ErrUnknownCompetition)
+	assert.ErrorIs(t, matchmakingService.ReportResult(1, []results.PlayerResult{
+		{PlayerID: "test_user_1", Placement: 1},
+	}), ErrResultRosterMismatch)
+	assert.ErrorIs(t, matchmakingService.ReportResult(1, []results.PlayerResult{
+		{PlayerID: "test_user_1", Placement: 1},
+		{PlayerID: "test_user_3", Placement: 2},
+	}), ErrResultRosterMismatch)
+	assert.ErrorIs(t, matchmakingService.ReportResult(1, []results.PlayerResult{
+		{PlayerID: "test_user_1", Placement: 1},
+		{PlayerID: "test_user_1", Placement: 2},
+	}), ErrDuplicatePlayerResult)
+	assert.ErrorIs(t, matchmakingService.ReportResult(1, []results.PlayerResult{
+		{PlayerID: "test_user_1", Placement: 1},
+		{PlayerID: "test_user_2", Placement: 0},
+	}), ErrInvalidPlacement)
+
+	// rejected reports leave the competition in progress
+	assert.NoError(t, matchmakingService.ReportResult(1, []results.PlayerResult{
+		{PlayerID: "test_user_1", Placement: 1},
+		{PlayerID: "test_user_2", Placement: 1},
+	}))
+}
+
+func TestMatchmakingService_ResultIsRejectedAfterReportWindow(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := startResultTestCompetition(t, ResultsConfig{ReportWindow: time.Hour}, fakeClock)
+	playerResults := []results.PlayerResult{
+		{PlayerID: "test_user_1", Placement: 1},
+		{PlayerID: "test_user_2", Placement: 2},
+	}
+
+	// the competition has been registered once the loop handles the next command
+	inspectMatchmakingService(matchmakingService, func() {})
+	fakeClock.Advance(time.Hour)
+	assert.ErrorIs(t, matchmakingService.ReportResult(1, playerResults), ErrUnknownCompetition)
+	inspectMatchmakingService(matchmakingService, func() {
+		assert.Empty(t, matchmakingService.competitionsInProgress)
+	})
+}
+
+func TestMatchmakingService_CompetitionIsAbortedWhenReportWindowPasses(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	matchmakingService := startResultTestCompetition(t, ResultsConfig{ReportWindow: time.Hour}, fakeClock)
+	var startedCompetition competition.Competition
+	inspectMatchmakingService(matchmakingService, func() {
+		startedCompetition = matchmakingService.competitionsInProgress[1].Competition
+	})
+
+	fakeClock.Advance(59 * time.Minute)
+	inspectMatchmakingService(matchmakingService, func() {
+		assert.Len(t, matchmakingService.competitionsInProgress, 1)
+	})
+
+	// the competition is forgotten without any other activity of the service
+	fakeClock.Advance(time.Minute)
+	assert.Eventually(t, func() bool {
+		numberOfCompetitions := -1
+		inspectMatchmakingService(matchmakingService, func() {
+			numberOfCompetitions = len(matchmakingService.competitionsInProgress)
+		})
+		return numberOfCompetitions == 0
+	}, time.Second, 10*time.Millisecond)
+	inspectMatchmakingService(matchmakingService, func() {
+		assert.Equal(t, competitionState_Aborted, startedCompetition.GetState())
+	})
+}
@@ -0,0 +1,109 @@
+package matchmaking
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// shutdown stops the matchmaking loop after draining the service and waits for the goroutines of the service
+// @param ctx the deadline for the shutdown
+// @return the error of the context if the goroutines did not finish before its deadline
+func (m *matchmakingService) shutdown(ctx context.Context) error {
+	select {
+	case m.commands <- shutdownCommand{}:
+	case <-m.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	goroutinesDone := make(chan struct{})
+	go func() {
+		m.goroutines.Wait()
+		close(goroutinesDone)
+	}()
+
+	select {
+	case <-goroutinesDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleShutdown tells every player in matchmaking that the server is shutting down and settles the open
+// competitions according to the shutdown policy. Every notification channel is closed afterwards
+// and the matchmaking loop stops after this command
+func (m *matchmakingService) handleShutdown() {
+	slog.Info("Shutting down matchmaking", "policy", m.getShutdownPolicy(), "players", len(m.playersInMatchmaking))
+	m.shutDown = true
+
+	for _, playerID := range slices.Sorted(maps.Keys(m.playersInMatchmaking)) {
+		m.sendNotificationToPlayer(playerID, MatchMakingNotification{
+			CompetitionID: m.competitionIDsOfPlayers[playerID],
+			State:         State_ServerShuttingDown,
+		})
+	}
+
+	for _, competitionID := range slices.Sorted(maps.Keys(m.readyChecks)) {
+		m.shutDownReadyCheck(m.readyChecks[competitionID])
+	}
+	for _, competitionID := range slices.Sorted(maps.Keys(m.competitionsInMatchmaking)) {
+		m.shutDownCompetition(m.competitionsInMatchmaking[competitionID])
+	}
+	for _, competitionID := range slices.Sorted(maps.Keys(m.backfillCompetitions)) {
+		m.stopBackfill(m.backfillCompetitions[competitionID].Competition)
+	}
+	m.stopResultReportWindows()
+
+	// players are always placed in a competition, this only guards against leaking a notification channel
+	for playerID := range m.playersInMatchmaking {
+		m.unregisterPlayerFromMatchmakingStage(playerID)
+	}
+	m.closeSubscriptions()
+}
+
+// shutDownCompetition starts a competition waiting for players if the shutdown policy allows it
+// and the competition has reached the minimum player count, otherwise the competition is aborted
+func (m *matchmakingService) shutDownCompetition(competitionData competitionData) {
+	if m.canStartOnShutdown(competitionData) {
+		slog.Info("Starting competition on shutdown", "id", competitionData.GetID())
+		m.startCompetition(competitionData.Competition)
+		return
+	}
+	slog.Info("Aborting competition on shutdown", "id", competitionData.GetID())
+	m.abortCompetition(competitionData.Competition)
+}
+
+// shutDownReadyCheck starts the competition of a ready check if the shutdown policy allows it
+// and the competition has reached the minimum player count, otherwise the competition is aborted
+// Players that have not confirmed yet are started with the others, as there is no time left to wait for them
+func (m *matchmakingService) shutDownReadyCheck(check *readyCheck) {
+	close(check.readyCheckCancel)
+	delete(m.readyChecks, check.GetID())
+
+	if m.canStartOnShutdown(check.competitionData) {
+		slog.Info("Starting competition in ready check on shutdown", "id", check.GetID())
+		m.commitCompetition(check.competitionData)
+		return
+	}
+
+	slog.Info("Aborting competition in ready check on shutdown", "id", check.GetID())
+	m.transitionCompetition(check.Competition, competitionState_Aborted)
+	m.publishCompetitionAborted(check.Competition)
+	m.notifyPlayers(check.Competition, State_Aborted)
+	m.unregisterPlayersFromMatchmakingStage(check.Competition)
+}
+
+func (m *matchmakingService) canStartOnShutdown(competitionData competitionData) bool {
+	return m.getShutdownPolicy() == ShutdownPolicy_Start &&
+		competitionData.GetNumberOfJoinedPlayers() >= m.config.CompetitionConfig.MinPlayerCount
+}
+
+func (m *matchmakingService) getShutdownPolicy() ShutdownPolicy {
+	if m.config.Shutdown.Policy == "" {
+		return ShutdownPolicy_Abort
+	}
+	return m.config.Shutdown.Policy
+}
@@ -0,0 +1,143 @@
+package matchmaking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchmakingService_ShutdownAbortsOpenCompetitions(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Minute,
+		LevelMatchingTolerance: 3,
+		LevelRangeWidening: LevelRangeWideningConfig{
+			Step:        1,
+			Interval:    time.Second,
+			MaxWidening: 5,
+		},
+		RequeuePolicy: RequeuePolicy{MaxRetries: 3},
+		Clock:         clock.NewFakeClock(time.Now()),
+	})
+
+	first := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_1", Level: 5})
+	second := joinPlayer(t, matchmakingService, model.PlayerData{ID: "test_user_2", Level: 5})
+
+	assert.NoError(t, matchmakingService.Shutdown(context.Background()))
+
+	for _, notifications := range []<-chan MatchMakingNotification{first, second} {
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_WaitingForPlayers}, <-notifications)
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_ServerShuttingDown}, <-notifications)
+		// players are not requeued on shutdown
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Aborted}, <-notifications)
+		_, ok := <-notifications
+		assert.False(t, ok, "notification channel should be closed on shutdown")
+	}
+}
+
+func TestMatchmakingService_ShutdownStartsCompetitionsWithMinPlayerCount(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Minute,
+		LevelMatchingTolerance: 3,
+		LevelRangeWidening: LevelRangeWideningConfig{
+			Step:        1,
+			Interval:    time.Second,
+			MaxWidening: 5,
+		},
+		RequeuePolicy: RequeuePolicy{MaxRetries: 3},
+		Shutdown:      ShutdownConfig{Policy: ShutdownPolicy_Start},
+		Clock:         clock.NewFakeClock(time.Now()),
+	})
+
+	players := createTesUsers([]model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 5},
+		{ID: "test_user_3", Level: 20},
+	})
+	joinPlayersToMatchmaking(t, matchmakingService, players)
+
+	assert.NoError(t, matchmakingService.Shutdown(context.Background()))
+
+	for _, player := range players[:2] {
+		assert.Equal(t, State_WaitingForPlayers, (<-player.personalNotificationChannel).State)
+		assert.Equal(t, State_ServerShuttingDown, (<-player.personalNotificationChannel).State)
+		assert.Equal(t, MatchMakingNotification{CompetitionID: 1, State: State_Started}, <-player.personalNotificationChannel)
+	}
+	lonely := players[2].personalNotificationChannel
+	assert.Equal(t, State_WaitingForPlayers, (<-lonely).State)
+	assert.Equal(t, State_ServerShuttingDown, (<-lonely).State)
+	assert.Equal(t, MatchMakingNotification{CompetitionID: 2, State: State_Aborted}, <-lonely)
+}
+
+func TestMatchmakingService_RequestsAfterShutdownAreRejected(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Minute,
+		LevelMatchingTolerance: 3,
+		LevelRangeWidening: LevelRangeWideningConfig{
+			Step:        1,
+			Interval:    time.Second,
+			MaxWidening: 5,
+		},
+		RequeuePolicy: RequeuePolicy{MaxRetries: 3},
+		Clock:         clock.NewFakeClock(time.Now()),
+	})
+	assert.NoError(t, matchmakingService.Shutdown(context.Background()))
+
+	_, err := matchmakingService.HandlePlayerJoin(model.PlayerData{ID: "test_user_1", Level: 5})
+	assert.ErrorIs(t, err, ErrMatchmakingShutDown)
+	_, err = matchmakingService.HandlePartyJoin([]model.PlayerData{{ID: "test_user_2", Level: 5}})
+	assert.ErrorIs(t, err, ErrMatchmakingShutDown)
+	assert.ErrorIs(t, matchmakingService.ReportResult(1, nil), ErrMatchmakingShutDown)
+
+	// requests without a reply are ignored and a second shutdown returns right away
+	matchmakingService.LeaveMatchmaking("test_user_1")
+	assert.NoError(t, matchmakingService.Shutdown(context.Background()))
+}
+
+func TestMatchmakingService_ShutdownRespectsDeadline(t *testing.T) {
+	matchmakingService := newMatchmakingService(MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 3,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Minute,
+		LevelMatchingTolerance: 3,
+		LevelRangeWidening: LevelRangeWideningConfig{
+			Step:        1,
+			Interval:    time.Second,
+			MaxWidening: 5,
+		},
+		RequeuePolicy: RequeuePolicy{MaxRetries: 3},
+		Clock:         clock.NewFakeClock(time.Now()),
+	})
+
+	// the matchmaking loop is kept busy, so the shutdown cannot finish
+	busy := make(chan struct{})
+	release := make(chan struct{})
+	go inspectMatchmakingService(matchmakingService, func() {
+		close(busy)
+		<-release
+	})
+	defer close(release)
+	<-busy
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, matchmakingService.Shutdown(ctx), context.DeadlineExceeded)
+}
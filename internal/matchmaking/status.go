@@ -0,0 +1,36 @@
+package matchmaking
+
+import (
+	"fmt"
+)
+
+// getPlayerStatus asks the matchmaking loop for the status of a player
+func (m *matchmakingService) getPlayerStatus(playerID string) (PlayerStatus, error) {
+	reply := make(chan playerStatusResult)
+	if !m.sendCommand(playerStatusCommand{playerID: playerID, reply: reply}) {
+		return PlayerStatus{}, ErrMatchmakingShutDown
+	}
+	result := <-reply
+	return result.status, result.err
+}
+
+// handlePlayerStatusRequest returns the status of a player from the last notification sent to the player
+// @param playerID the id of the player
+// @return the status, or ErrPlayerNotInMatchmaking if the player is not in matchmaking
+func (m *matchmakingService) handlePlayerStatusRequest(playerID string) (PlayerStatus, error) {
+	player, exists := m.playersInMatchmaking[playerID]
+	if !exists {
+		return PlayerStatus{}, fmt.Errorf("%w: %s", ErrPlayerNotInMatchmaking, playerID)
+	}
+
+	status := PlayerStatus{
+		PlayerID: playerID,
+		QueuedAt: player.queuedAt,
+		PartyID:  player.partyID,
+	}
+	if player.lastNotification != nil {
+		status.CompetitionID = player.lastNotification.CompetitionID
+		status.State = player.lastNotification.State
+	}
+	return status, nil
+}
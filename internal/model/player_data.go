@@ -4,4 +4,13 @@ package model
 type PlayerData struct {
 	ID    string
 	Level int
+
+	// Rating is the skill rating of the player. Used only when rating matching is enabled
+	Rating float64
+	// RatingDeviation is the uncertainty of the player's rating
+	RatingDeviation float64
+
+	// Pings are the measured round trip times in milliseconds from the player to each region
+	// Used only when region matching is enabled
+	Pings map[string]int
 }
@@ -0,0 +1,49 @@
+package rating
+
+import (
+	"math"
+)
+
+// DefaultEloKFactor is the default maximum rating change of a player in a single competition
+const DefaultEloKFactor = 32.0
+
+// eloCalculator calculates ratings with the Elo rating system
+// The rating change is averaged over all opponents, so the maximum change does not depend on the competition size
+// Elo does not track uncertainty, so the deviation and volatility are kept as they are
+type eloCalculator struct {
+	kFactor float64
+}
+
+// NewEloCalculator creates a new Elo rating calculator
+// @param kFactor the maximum rating change of a player in a single competition
+// @return a new Elo rating calculator
+func NewEloCalculator(kFactor float64) Calculator {
+	return &eloCalculator{kFactor: kFactor}
+}
+
+func (c *eloCalculator) CalculateRatings(results []PlayerResult) map[string]Rating {
+	newRatings := make(map[string]Rating, len(results))
+	for _, player := range results {
+		newRatings[player.PlayerID] = c.calculateRating(player, results)
+	}
+	return newRatings
+}
+
+func (c *eloCalculator) calculateRating(player PlayerResult, results []PlayerResult) Rating {
+	newRating := player.Rating
+	if len(results) < 2 {
+		return newRating
+	}
+
+	scoreDifference := 0.0
+	for _, opponent := range results {
+		if opponent.PlayerID == player.PlayerID {
+			continue
+		}
+		expectedScore := 1 / (1 + math.Pow(10, (opponent.Rating.Value-player.Rating.Value)/400))
+		scoreDifference += getPairwiseScore(player, opponent) - expectedScore
+	}
+
+	newRating.Value += c.kFactor * scoreDifference / float64(len(results)-1)
+	return newRating
+}
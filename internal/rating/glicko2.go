@@ -0,0 +1,123 @@
+package rating
+
+import (
+	"math"
+)
+
+const (
+	// glicko2Scale converts ratings between the Glicko and the Glicko-2 scale
+	glicko2Scale = 173.7178
+
+	// glicko2ConvergenceTolerance is the precision of the volatility iteration
+	glicko2ConvergenceTolerance = 0.000001
+
+	// DefaultGlicko2Tau is the default system constant constraining the change in volatility over time
+	DefaultGlicko2Tau = 0.5
+)
+
+// glicko2Calculator calculates ratings with the Glicko-2 rating system
+// Every competition is treated as a single rating period
+// See http://www.glicko.net/glicko/glicko2.pdf
+type glicko2Calculator struct {
+	tau float64
+}
+
+// NewGlicko2Calculator creates a new Glicko-2 rating calculator
+// @param tau the system constant constraining the change in volatility over time, typically between 0.3 and 1.2
+// @return a new Glicko-2 rating calculator
+func NewGlicko2Calculator(tau float64) Calculator {
+	return &glicko2Calculator{tau: tau}
+}
+
+func (c *glicko2Calculator) CalculateRatings(results []PlayerResult) map[string]Rating {
+	newRatings := make(map[string]Rating, len(results))
+	for _, player := range results {
+		newRatings[player.PlayerID] = c.calculateRating(player, results)
+	}
+	return newRatings
+}
+
+func (c *glicko2Calculator) calculateRating(player PlayerResult, results []PlayerResult) Rating {
+	mu := (player.Rating.Value - DefaultRating) / glicko2Scale
+	phi := player.Rating.Deviation / glicko2Scale
+	sigma := player.Rating.Volatility
+
+	inverseVariance := 0.0
+	scoreSum := 0.0
+	for _, opponent := range results {
+		if opponent.PlayerID == player.PlayerID {
+			continue
+		}
+		opponentMu := (opponent.Rating.Value - DefaultRating) / glicko2Scale
+		opponentG := glicko2G(opponent.Rating.Deviation / glicko2Scale)
+		expectedScore := 1 / (1 + math.Exp(-opponentG*(mu-opponentMu)))
+
+		inverseVariance += opponentG * opponentG * expectedScore * (1 - expectedScore)
+		scoreSum += opponentG * (getPairwiseScore(player, opponent) - expectedScore)
+	}
+
+	// A player without opponents only becomes more uncertain
+	if inverseVariance == 0 {
+		return Rating{
+			Value:      player.Rating.Value,
+			Deviation:  math.Sqrt(phi*phi+sigma*sigma) * glicko2Scale,
+			Volatility: sigma,
+		}
+	}
+
+	variance := 1 / inverseVariance
+	delta := variance * scoreSum
+	newSigma := c.calculateVolatility(phi, sigma, variance, delta)
+
+	preRatingPhi := math.Sqrt(phi*phi + newSigma*newSigma)
+	newPhi := 1 / math.Sqrt(1/(preRatingPhi*preRatingPhi)+1/variance)
+	newMu := mu + newPhi*newPhi*scoreSum
+
+	return Rating{
+		Value:      newMu*glicko2Scale + DefaultRating,
+		Deviation:  newPhi * glicko2Scale,
+		Volatility: newSigma,
+	}
+}
+
+// calculateVolatility finds the new volatility with the Illinois algorithm as described in step 5 of the Glicko-2 paper
+func (c *glicko2Calculator) calculateVolatility(phi float64, sigma float64, variance float64, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		expX := math.Exp(x)
+		denominator := phi*phi + variance + expX
+		return expX*(delta*delta-phi*phi-variance-expX)/(2*denominator*denominator) - (x-a)/(c.tau*c.tau)
+	}
+
+	lower := a
+	var upper float64
+	if delta*delta > phi*phi+variance {
+		upper = math.Log(delta*delta - phi*phi - variance)
+	} else {
+		k := 1.0
+		for f(a-k*c.tau) < 0 {
+			k++
+		}
+		upper = a - k*c.tau
+	}
+
+	fLower := f(lower)
+	fUpper := f(upper)
+	for math.Abs(upper-lower) > glicko2ConvergenceTolerance {
+		next := lower + (lower-upper)*fLower/(fUpper-fLower)
+		fNext := f(next)
+		if fNext*fUpper <= 0 {
+			lower = upper
+			fLower = fUpper
+		} else {
+			fLower /= 2
+		}
+		upper = next
+		fUpper = fNext
+	}
+	return math.Exp(lower / 2)
+}
+
+func glicko2G(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
@@ -0,0 +1,68 @@
+package rating
+
+import (
+	"log/slog"
+	"sync"
+)
+
+type ratingService struct {
+	calculator Calculator
+
+	mutex   sync.RWMutex
+	ratings map[string]Rating
+}
+
+func newRatingService(calculator Calculator) *ratingService {
+	return &ratingService{
+		calculator: calculator,
+		ratings:    make(map[string]Rating),
+	}
+}
+
+func (r *ratingService) getRating(playerID string) Rating {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if rating, exists := r.ratings[playerID]; exists {
+		return rating
+	}
+	return NewDefaultRating()
+}
+
+func (r *ratingService) updateRatings(placements map[string]int) map[string]Rating {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	results := make([]PlayerResult, 0, len(placements))
+	for playerID, placement := range placements {
+		rating, exists := r.ratings[playerID]
+		if !exists {
+			rating = NewDefaultRating()
+		}
+		results = append(results, PlayerResult{
+			PlayerID:  playerID,
+			Placement: placement,
+			Rating:    rating,
+		})
+	}
+
+	newRatings := r.calculator.CalculateRatings(results)
+	for playerID, rating := range newRatings {
+		r.ratings[playerID] = rating
+		slog.Info("Updated player rating", "player_id", playerID, "rating", rating.Value, "deviation", rating.Deviation)
+	}
+	return newRatings
+}
+
+// getPairwiseScore returns the score of a player against an opponent based on their placements
+// 1 is a win, 0.5 is a draw and 0 is a loss
+func getPairwiseScore(player PlayerResult, opponent PlayerResult) float64 {
+	switch {
+	case player.Placement < opponent.Placement:
+		return 1
+	case player.Placement == opponent.Placement:
+		return 0.5
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,141 @@
+package rating
+
+import (
+	"math"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+)
+
+const (
+	// DefaultRating is the rating of a player without rated competitions
+	DefaultRating = 1500.0
+
+	// DefaultDeviation is the rating deviation of a player without rated competitions
+	DefaultDeviation = 350.0
+
+	// DefaultVolatility is the Glicko-2 volatility of a player without rated competitions
+	DefaultVolatility = 0.06
+)
+
+// Rating is a player's skill rating together with its uncertainty
+type Rating struct {
+	// Value is the estimated skill of the player
+	Value float64
+	// Deviation is the uncertainty of Value. The lower the deviation, the more reliable the rating
+	Deviation float64
+	// Volatility is the expected fluctuation of the rating. Only used by Glicko-2
+	Volatility float64
+}
+
+// PlayerResult is the result of a single player in a competition
+type PlayerResult struct {
+	PlayerID string
+	// Placement is the final placement of the player, 1 being the best. Players with the same placement are tied
+	Placement int
+	// Rating is the rating of the player before the competition
+	Rating Rating
+}
+
+// Calculator calculates new ratings from the results of a competition
+// Every player is compared with every other player of the competition: a better placement is a win,
+// the same placement is a draw and a worse placement is a loss
+type Calculator interface {
+	// CalculateRatings calculates the new ratings of the players of a competition
+	// @param results the results of all players in the competition
+	// @return the new ratings by player id
+	CalculateRatings(results []PlayerResult) map[string]Rating
+}
+
+// RatingService keeps the ratings of players and updates them from competition results
+type RatingService interface {
+	// GetRating returns the rating of a player
+	// @param playerID the id of the player
+	// @return the rating of the player, or the default rating if the player has no rating yet
+	GetRating(playerID string) Rating
+
+	// UpdateRatings updates the ratings of the players of a competition from their placements
+	// @param placements the final placement of each player by player id, 1 being the best
+	// @return the new ratings by player id
+	UpdateRatings(placements map[string]int) map[string]Rating
+}
+
+// MatchingConfig defines how close two ratings must be to be matched together
+type MatchingConfig struct {
+	// Tolerance is the rating difference that is always accepted
+	Tolerance float64
+	// DeviationWeight scales how much the combined rating deviation widens the accepted difference
+	DeviationWeight float64
+}
+
+// NewDefaultRating returns the rating of a player without rated competitions
+func NewDefaultRating() Rating {
+	return Rating{
+		Value:      DefaultRating,
+		Deviation:  DefaultDeviation,
+		Volatility: DefaultVolatility,
+	}
+}
+
+// OfPlayer returns the rating carried in the player's data
+// Missing values are replaced with the defaults of a player without rated competitions
+// @param playerData the data of the player
+// @return the rating of the player
+func OfPlayer(playerData model.PlayerData) Rating {
+	rating := NewDefaultRating()
+	if playerData.Rating != 0 {
+		rating.Value = playerData.Rating
+	}
+	if playerData.RatingDeviation != 0 {
+		rating.Deviation = playerData.RatingDeviation
+	}
+	return rating
+}
+
+// NewRatingService creates a new in-memory rating service
+// @param calculator the calculator used to update the ratings
+// @return a new rating service
+func NewRatingService(calculator Calculator) RatingService {
+	return newRatingService(calculator)
+}
+
+// IsMatching checks if two ratings are close enough to be matched together
+// The accepted difference grows with the uncertainty of the ratings, so players with an unreliable
+// rating are matched more loosely
+// @param a the first rating
+// @param b the second rating
+// @param config the matching configuration
+// @return true if the ratings match, false otherwise
+func IsMatching(a Rating, b Rating, config MatchingConfig) bool {
+	combinedDeviation := math.Sqrt(a.Deviation*a.Deviation + b.Deviation*b.Deviation)
+	return math.Abs(a.Value-b.Value) <= config.Tolerance+config.DeviationWeight*combinedDeviation
+}
+
+// Aggregate combines the ratings of a group of players into a single rating
+// The value is the mean of the values and the deviation is the root mean square of the deviations
+// @param ratings the ratings to combine
+// @return the combined rating, or the default rating if there are no ratings
+func Aggregate(ratings []Rating) Rating {
+	if len(ratings) == 0 {
+		return NewDefaultRating()
+	}
+
+	aggregate := Rating{}
+	for _, rating := range ratings {
+		aggregate.Value += rating.Value
+		aggregate.Deviation += rating.Deviation * rating.Deviation
+		aggregate.Volatility += rating.Volatility
+	}
+	count := float64(len(ratings))
+	aggregate.Value /= count
+	aggregate.Deviation = math.Sqrt(aggregate.Deviation / count)
+	aggregate.Volatility /= count
+	return aggregate
+}
+
+func (r *ratingService) GetRating(playerID string) Rating {
+	return r.getRating(playerID)
+}
+
+func (r *ratingService) UpdateRatings(placements map[string]int) map[string]Rating {
+	return r.updateRatings(placements)
+}
@@ -0,0 +1,98 @@
+package rating
+
+import (
+	"testing"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGlicko2Calculator tests the Glicko-2 calculator with the example of the Glicko-2 paper
+func TestGlicko2Calculator(t *testing.T) {
+	results := []PlayerResult{
+		{PlayerID: "player", Placement: 2, Rating: Rating{Value: 1500, Deviation: 200, Volatility: 0.06}},
+		{PlayerID: "weaker_opponent", Placement: 3, Rating: Rating{Value: 1400, Deviation: 30, Volatility: 0.06}},
+		{PlayerID: "stronger_opponent", Placement: 1, Rating: Rating{Value: 1550, Deviation: 100, Volatility: 0.06}},
+		{PlayerID: "strongest_opponent", Placement: 1, Rating: Rating{Value: 1700, Deviation: 300, Volatility: 0.06}},
+	}
+
+	newRatings := NewGlicko2Calculator(0.5).CalculateRatings(results)
+
+	assert.InDelta(t, 1464.06, newRatings["player"].Value, 0.01)
+	assert.InDelta(t, 151.52, newRatings["player"].Deviation, 0.01)
+	assert.InDelta(t, 0.05999, newRatings["player"].Volatility, 0.00001)
+	assert.Len(t, newRatings, 4)
+}
+
+func TestGlicko2Calculator_SinglePlayerBecomesMoreUncertain(t *testing.T) {
+	newRatings := NewGlicko2Calculator(0.5).CalculateRatings([]PlayerResult{
+		{PlayerID: "player", Placement: 1, Rating: Rating{Value: 1500, Deviation: 50, Volatility: 0.06}},
+	})
+
+	assert.Equal(t, 1500.0, newRatings["player"].Value)
+	assert.Greater(t, newRatings["player"].Deviation, 50.0)
+}
+
+func TestEloCalculator(t *testing.T) {
+	results := []PlayerResult{
+		{PlayerID: "winner", Placement: 1, Rating: Rating{Value: 1500, Deviation: 100}},
+		{PlayerID: "loser", Placement: 2, Rating: Rating{Value: 1500, Deviation: 100}},
+	}
+
+	newRatings := NewEloCalculator(32).CalculateRatings(results)
+
+	assert.InDelta(t, 1516, newRatings["winner"].Value, 0.001)
+	assert.InDelta(t, 1484, newRatings["loser"].Value, 0.001)
+	assert.Equal(t, 100.0, newRatings["winner"].Deviation)
+}
+
+func TestEloCalculator_Draw(t *testing.T) {
+	results := []PlayerResult{
+		{PlayerID: "stronger", Placement: 1, Rating: Rating{Value: 1600}},
+		{PlayerID: "weaker", Placement: 1, Rating: Rating{Value: 1400}},
+	}
+
+	newRatings := NewEloCalculator(32).CalculateRatings(results)
+
+	assert.Less(t, newRatings["stronger"].Value, 1600.0)
+	assert.Greater(t, newRatings["weaker"].Value, 1400.0)
+}
+
+func TestRatingService_UpdateRatings(t *testing.T) {
+	ratingService := NewRatingService(NewEloCalculator(32))
+
+	assert.Equal(t, NewDefaultRating(), ratingService.GetRating("winner"))
+
+	ratingService.UpdateRatings(map[string]int{"winner": 1, "loser": 2})
+
+	assert.Greater(t, ratingService.GetRating("winner").Value, DefaultRating)
+	assert.Less(t, ratingService.GetRating("loser").Value, DefaultRating)
+}
+
+func TestIsMatching(t *testing.T) {
+	config := MatchingConfig{Tolerance: 100, DeviationWeight: 1}
+
+	assert.True(t, IsMatching(Rating{Value: 1500}, Rating{Value: 1600}, config))
+	assert.False(t, IsMatching(Rating{Value: 1500}, Rating{Value: 1700}, config))
+	// uncertain ratings are matched more loosely
+	assert.True(t, IsMatching(Rating{Value: 1500, Deviation: 60}, Rating{Value: 1700, Deviation: 80}, config))
+}
+
+func TestAggregate(t *testing.T) {
+	aggregate := Aggregate([]Rating{
+		{Value: 1400, Deviation: 30},
+		{Value: 1600, Deviation: 40},
+	})
+
+	assert.Equal(t, 1500.0, aggregate.Value)
+	assert.InDelta(t, 35.355, aggregate.Deviation, 0.001)
+	assert.Equal(t, NewDefaultRating(), Aggregate(nil))
+}
+
+func TestOfPlayer(t *testing.T) {
+	assert.Equal(t, NewDefaultRating(), OfPlayer(model.PlayerData{ID: "player"}))
+
+	playerRating := OfPlayer(model.PlayerData{ID: "player", Rating: 1800, RatingDeviation: 50})
+	assert.Equal(t, 1800.0, playerRating.Value)
+	assert.Equal(t, 50.0, playerRating.Deviation)
+}
@@ -0,0 +1,39 @@
+package results
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+type resultStore struct {
+	mutex   sync.RWMutex
+	results map[int]CompetitionResult
+}
+
+func newResultStore() *resultStore {
+	return &resultStore{
+		results: make(map[int]CompetitionResult),
+	}
+}
+
+func (s *resultStore) saveResult(result CompetitionResult) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.results[result.CompetitionID]; exists {
+		return fmt.Errorf("%w: competition %d", ErrResultAlreadyStored, result.CompetitionID)
+	}
+	result.Players = slices.Clone(result.Players)
+	s.results[result.CompetitionID] = result
+	return nil
+}
+
+func (s *resultStore) getResult(competitionID int) (CompetitionResult, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result, exists := s.results[competitionID]
+	result.Players = slices.Clone(result.Players)
+	return result, exists
+}
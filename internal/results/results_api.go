@@ -0,0 +1,85 @@
+package results
+
+import (
+	"errors"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
+)
+
+// PlayerResult is the final result of a player in a competition
+type PlayerResult struct {
+	PlayerID string
+	// Placement is the final placement of the player, 1 being the best. Players with the same placement are tied
+	Placement int
+	// Score is the score of the player. Its meaning depends on the game
+	Score float64
+}
+
+// CompetitionResult is the reported result of a finished competition
+type CompetitionResult struct {
+	CompetitionID int
+	Players       []PlayerResult
+	ReportedAt    time.Time
+}
+
+// GetPlacements returns the placement of every player by player id
+func (r CompetitionResult) GetPlacements() map[string]int {
+	placements := make(map[string]int, len(r.Players))
+	for _, player := range r.Players {
+		placements[player.PlayerID] = player.Placement
+	}
+	return placements
+}
+
+// ErrResultAlreadyStored is returned when a result is stored for a competition that already has a result
+var ErrResultAlreadyStored = errors.New("competition result is already stored")
+
+// ResultStore stores the results of finished competitions
+type ResultStore interface {
+	// SaveResult stores the result of a competition
+	// @param result the result to store
+	// @return ErrResultAlreadyStored if the competition already has a result
+	SaveResult(result CompetitionResult) error
+
+	// GetResult returns the stored result of a competition
+	// @param competitionID the id of the competition
+	// @return the result and true, or false if the competition has no result
+	GetResult(competitionID int) (CompetitionResult, bool)
+}
+
+// ResultListener is notified about every stored competition result
+// Listeners are called from the matchmaking loop, so they must not block or call back into the matchmaking service
+type ResultListener interface {
+	OnCompetitionResult(result CompetitionResult)
+}
+
+// ResultListenerFunc adapts a function to a ResultListener
+type ResultListenerFunc func(result CompetitionResult)
+
+func (f ResultListenerFunc) OnCompetitionResult(result CompetitionResult) {
+	f(result)
+}
+
+// NewResultStore creates a new in-memory result store
+// @return a new result store
+func NewResultStore() ResultStore {
+	return newResultStore()
+}
+
+// NewRatingUpdater creates a result listener that updates the ratings of the players from their placements
+// @param ratingService the rating service to update
+// @return a new result listener
+func NewRatingUpdater(ratingService rating.RatingService) ResultListener {
+	return ResultListenerFunc(func(result CompetitionResult) {
+		ratingService.UpdateRatings(result.GetPlacements())
+	})
+}
+
+func (s *resultStore) SaveResult(result CompetitionResult) error {
+	return s.saveResult(result)
+}
+
+func (s *resultStore) GetResult(competitionID int) (CompetitionResult, bool) {
+	return s.getResult(competitionID)
+}
@@ -0,0 +1,43 @@
+package results
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/rating"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestResult(competitionID int) CompetitionResult {
+	return CompetitionResult{
+		CompetitionID: competitionID,
+		Players: []PlayerResult{
+			{PlayerID: "test_user_1", Placement: 1, Score: 30},
+			{PlayerID: "test_user_2", Placement: 2, Score: 12.5},
+		},
+		ReportedAt: time.Now(),
+	}
+}
+
+func TestResultStore(t *testing.T) {
+	store := NewResultStore()
+
+	_, found := store.GetResult(1)
+	assert.False(t, found)
+
+	assert.NoError(t, store.SaveResult(createTestResult(1)))
+	stored, found := store.GetResult(1)
+	assert.True(t, found)
+	assert.Equal(t, createTestResult(1).Players, stored.Players)
+
+	assert.ErrorIs(t, store.SaveResult(createTestResult(1)), ErrResultAlreadyStored)
+}
+
+func TestRatingUpdater(t *testing.T) {
+	ratingService := rating.NewRatingService(rating.NewEloCalculator(rating.DefaultEloKFactor))
+
+	NewRatingUpdater(ratingService).OnCompetitionResult(createTestResult(1))
+
+	assert.Greater(t, ratingService.GetRating("test_user_1").Value, rating.DefaultRating)
+	assert.Less(t, ratingService.GetRating("test_user_2").Value, rating.DefaultRating)
+}
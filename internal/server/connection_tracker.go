@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// connectionTracker keeps the open connections of a server and the goroutines serving them,
+// so that the shutdown of the server can wait for them or close the connections
+type connectionTracker struct {
+	// mutex guards the open connections and the start of the shutdown
+	mutex       sync.Mutex
+	connections map[io.Closer]struct{}
+	// shuttingDown is closed when the shutdown starts. Idle connections are closed after this
+	shuttingDown chan struct{}
+	// goroutines are the connection handlers and their helper goroutines, which the shutdown waits for
+	goroutines sync.WaitGroup
+}
+
+func newConnectionTracker() *connectionTracker {
+	return &connectionTracker{
+		connections:  make(map[io.Closer]struct{}),
+		shuttingDown: make(chan struct{}),
+	}
+}
+
+// track records an accepted connection and counts its handler, so that the shutdown can close the connection
+// and wait for the handler. The handler must call untrack when it returns
+// @return false if the server is shutting down and the connection must not be served
+func (t *connectionTracker) track(conn io.Closer) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.isShuttingDown() {
+		return false
+	}
+	t.connections[conn] = struct{}{}
+	// the handler is counted while holding the mutex, so the shutdown never starts waiting before it is counted
+	t.goroutines.Add(1)
+	return true
+}
+
+// untrack forgets a connection whose handler returns
+func (t *connectionTracker) untrack(conn io.Closer) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.connections, conn)
+	t.goroutines.Done()
+}
+
+// startGoroutine runs a function of a connection handler in a goroutine that the shutdown waits for
+func (t *connectionTracker) startGoroutine(run func()) {
+	t.goroutines.Add(1)
+	go func() {
+		defer t.goroutines.Done()
+		run()
+	}()
+}
+
+func (t *connectionTracker) isShuttingDown() bool {
+	select {
+	case <-t.shuttingDown:
+		return true
+	default:
+		return false
+	}
+}
+
+// startShutdown stops accepting new connections
+// @param stopListening is called while no connection can be tracked, to stop the listener of the server. Can be nil
+// @return the number of open connections, and false if the shutdown has already started
+func (t *connectionTracker) startShutdown(stopListening func()) (int, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.isShuttingDown() {
+		return 0, false
+	}
+	close(t.shuttingDown)
+	if stopListening != nil {
+		stopListening()
+	}
+	return len(t.connections), true
+}
+
+// wait waits for the connection handlers and their goroutines to finish
+// The remaining connections are closed when the deadline passes
+// @param ctx the deadline for the shutdown
+// @return the error of the context if the handlers did not finish before its deadline
+func (t *connectionTracker) wait(ctx context.Context) error {
+	goroutinesDone := make(chan struct{})
+	go func() {
+		t.goroutines.Wait()
+		close(goroutinesDone)
+	}()
+
+	select {
+	case <-goroutinesDone:
+		return nil
+	case <-ctx.Done():
+		t.closeConnections()
+		return ctx.Err()
+	}
+}
+
+func (t *connectionTracker) closeConnections() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for conn := range t.connections {
+		conn.Close()
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// errMissingGameServerToken is returned for a report without a game server token
+var errMissingGameServerToken = errors.New("report has no game server token")
+
+// errInvalidGameServerToken is returned for a report with a token that is not the game server token
+var errInvalidGameServerToken = errors.New("invalid game server token")
+
+// errGameServerReportsDisabled is returned for a report received by a server without a game server token
+var errGameServerReportsDisabled = errors.New("game server reports are not accepted by this server")
+
+// gameServerAuth authenticates the game servers, which report the results and dropped players of competitions
+// Players must not be able to report, since results update the ratings and dropped players are replaced
+type gameServerAuth struct {
+	// token is the secret shared with the game servers. Reports are refused if empty
+	token string
+}
+
+// authenticate checks the token sent with a report
+// @param token the token sent by the reporter
+// @return a protocol error with the unauthenticated code if the token is not the game server token
+func (a gameServerAuth) authenticate(token string) error {
+	switch {
+	case a.token == "":
+		return newProtocolError(errorCode_Unauthenticated, errGameServerReportsDisabled)
+	case token == "":
+		return newProtocolError(errorCode_Unauthenticated, errMissingGameServerToken)
+	case subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1:
+		return newProtocolError(errorCode_Unauthenticated, errInvalidGameServerToken)
+	default:
+		return nil
+	}
+}
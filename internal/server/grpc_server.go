@@ -0,0 +1,396 @@
+package server
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/SntrKslnn/matchmaking-service --go-grpc_out=../.. --go-grpc_opt=module=github.com/SntrKslnn/matchmaking-service matchmaking/v1/matchmaking.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/server/matchmakingpb"
+	"github.com/SntrKslnn/matchmaking-service/internal/validation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcErrorDomain is the domain of the error details attached to the errors of the gRPC server
+const grpcErrorDomain = "matchmaking-service"
+
+// grpcSendTimeout is how long sending a notification may wait for the flow control of a client that
+// does not read its stream
+const grpcSendTimeout = 10 * time.Second
+
+// errSendTimeout is returned when a notification could not be sent before the send timeout
+var errSendTimeout = errors.New("timed out sending notification")
+
+// grpcBearerPrefix precedes the game server token in the authorization metadata of a call
+const grpcBearerPrefix = "Bearer "
+
+type MatchmakingGrpcServer interface {
+	// Start starts the gRPC server and serves calls until the server is shut down
+	// @return an error if the server cannot listen on its port
+	Start() error
+
+	// Shutdown stops accepting calls and shuts down the matchmaking queues, which tells the waiting players
+	// that the server is shutting down and starts or aborts their competitions. The join streams end
+	// once their players have received the outcome, or when the deadline passes
+	// @param ctx the deadline for the shutdown
+	// @return the error of the context if the shutdown did not finish before its deadline
+	Shutdown(ctx context.Context) error
+}
+
+// grpcServer serves the matchmaking API defined in proto/matchmaking/v1/matchmaking.proto
+// Failed calls carry an ErrorInfo detail whose reason is the error code of the TCP protocol
+type grpcServer struct {
+	matchmakingpb.UnimplementedMatchmakingServiceServer
+
+	port      int
+	queues    matchmaking.QueueRegistry
+	validator validation.JoinValidator
+	// gameServerAuth authenticates the game servers reporting dropped players
+	gameServerAuth gameServerAuth
+
+	server      *grpc.Server
+	connections *connectionTracker
+
+	// mutex guards the stream players
+	mutex sync.Mutex
+	// streamPlayers are the players joined over the join streams served by this server
+	streamPlayers map[grpcStreamPlayer]*joinedPlayers
+}
+
+// grpcStreamPlayer identifies a player joined over a join stream by its queue and id
+type grpcStreamPlayer struct {
+	queueName string
+	playerID  string
+}
+
+// NewGRPCServer creates a gRPC server that lets players join the queues of the registry
+// @param port the port to listen on
+// @param queues the matchmaking queues players can join
+// @param validator the validator checking the players of join requests before they join a queue
+// @param gameServerToken the secret game servers send with their reports. Reports are refused if empty
+// @return a new gRPC server
+func NewGRPCServer(port int, queues matchmaking.QueueRegistry, validator validation.JoinValidator, gameServerToken string) MatchmakingGrpcServer {
+	s := &grpcServer{
+		port:           port,
+		queues:         queues,
+		validator:      validator,
+		gameServerAuth: gameServerAuth{token: gameServerToken},
+		server:         grpc.NewServer(),
+		connections:    newConnectionTracker(),
+		streamPlayers:  make(map[grpcStreamPlayer]*joinedPlayers),
+	}
+	matchmakingpb.RegisterMatchmakingServiceServer(s.server, s)
+	return s
+}
+
+func (s *grpcServer) Start() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err != nil {
+		return fmt.Errorf("failed to start gRPC server: %w", err)
+	}
+
+	slog.Info("gRPC Server listening.", "port", s.port)
+	err = s.server.Serve(listener)
+	if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("gRPC server failed: %w", err)
+	}
+	slog.Info("gRPC Server stopped listening.", "port", s.port)
+	return nil
+}
+
+// Join joins the player or the party of the request and streams the notifications until the notification channel
+// is closed. If the client cancels the stream before that, the server stops listening to the players, which removes
+// them from matchmaking unless another connection listens to them
+func (s *grpcServer) Join(request *matchmakingpb.JoinRequest, stream grpc.ServerStreamingServer[matchmakingpb.Notification]) error {
+	players, notifications, err := joinPlayers(s.queues, s.validator, toJoinRequest(request), s.connections.startGoroutine)
+	if err != nil {
+		slog.Warn("Rejected gRPC join request", "error", err)
+		return toGrpcError(err)
+	}
+	s.trackStreamPlayers(players)
+	defer s.untrackStreamPlayers(players)
+
+	for {
+		select {
+		case notification, ok := <-notifications:
+			if !ok {
+				return nil
+			}
+			if err := s.sendNotification(stream, notification); err != nil {
+				slog.Info("Error sending to gRPC stream", "player_ids", players.playerIDs, "error", err)
+				return s.leaveOnCancel(players, notifications)
+			}
+		case <-stream.Context().Done():
+			slog.Info("gRPC stream cancelled while in matchmaking", "player_ids", players.playerIDs)
+			return s.leaveOnCancel(players, notifications)
+		}
+	}
+}
+
+// trackStreamPlayers records the players of a join stream, so that the calls for a player can be limited to
+// the players whose stream this server serves
+func (s *grpcServer) trackStreamPlayers(players *joinedPlayers) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, playerID := range players.playerIDs {
+		s.streamPlayers[grpcStreamPlayer{queueName: players.queueName, playerID: playerID}] = players
+	}
+}
+
+// untrackStreamPlayers forgets the players of an ended join stream, unless a later stream of the same players
+// has taken them over
+func (s *grpcServer) untrackStreamPlayers(players *joinedPlayers) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, playerID := range players.playerIDs {
+		key := grpcStreamPlayer{queueName: players.queueName, playerID: playerID}
+		if s.streamPlayers[key] == players {
+			delete(s.streamPlayers, key)
+		}
+	}
+}
+
+// getStreamPlayers returns the players of the join stream of a player
+// @param queueName the name of the queue the player waits in. DefaultQueueName is used if empty
+// @return the players of the stream, or an error if the queue is unknown or the player has no join stream on this server
+func (s *grpcServer) getStreamPlayers(queueName string, playerID string) (*joinedPlayers, error) {
+	if _, err := s.queues.GetQueue(queueName); err != nil {
+		return nil, err
+	}
+	if queueName == "" {
+		queueName = matchmaking.DefaultQueueName
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	players, joined := s.streamPlayers[grpcStreamPlayer{queueName: queueName, playerID: playerID}]
+	if !joined {
+		return nil, newProtocolError(errorCode_NotInMatchmaking, fmt.Errorf("player %s has not joined over a gRPC join stream", playerID))
+	}
+	return players, nil
+}
+
+// sendNotification sends a notification to a join stream
+// The send runs in its own goroutine because it cannot be given a deadline. Returning from the handler after a
+// timeout ends the stream, which makes the pending send fail
+// @return errSendTimeout if the client did not take the notification before the send timeout
+func (s *grpcServer) sendNotification(stream grpc.ServerStreamingServer[matchmakingpb.Notification], notification matchmaking.MatchMakingNotification) error {
+	sent := make(chan error, 1)
+	s.connections.startGoroutine(func() {
+		sent <- stream.Send(toGrpcNotification(notification))
+	})
+
+	timer := time.NewTimer(grpcSendTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-sent:
+		return err
+	case <-timer.C:
+		return errSendTimeout
+	}
+}
+
+// leaveOnCancel stops listening to the players of a cancelled stream and drains their notifications
+// until the matchmaking service closes the notification channel
+func (s *grpcServer) leaveOnCancel(players *joinedPlayers, notifications <-chan matchmaking.MatchMakingNotification) error {
+	for _, playerID := range players.playerIDs {
+		players.queue.DetachListener(playerID, players.notificationChans[playerID])
+	}
+	drainNotifications(notifications)
+	return status.Error(codes.Canceled, "join stream cancelled")
+}
+
+// Leave removes a player joined over a join stream of this server from matchmaking
+// Players that joined over another server are not found, so gRPC clients cannot remove the players of other connections
+func (s *grpcServer) Leave(ctx context.Context, request *matchmakingpb.LeaveRequest) (*matchmakingpb.LeaveResponse, error) {
+	players, err := s.getStreamPlayers(request.GetQueue(), request.GetPlayerId())
+	if err != nil {
+		return nil, toGrpcError(err)
+	}
+	if _, err := players.queue.GetPlayerStatus(request.GetPlayerId()); err != nil {
+		return nil, toGrpcError(err)
+	}
+	players.queue.LeaveMatchmaking(request.GetPlayerId())
+	return &matchmakingpb.LeaveResponse{}, nil
+}
+
+func (s *grpcServer) GetStatus(ctx context.Context, request *matchmakingpb.GetStatusRequest) (*matchmakingpb.GetStatusResponse, error) {
+	queue, err := s.queues.GetQueue(request.GetQueue())
+	if err != nil {
+		return nil, toGrpcError(err)
+	}
+	playerStatus, err := queue.GetPlayerStatus(request.GetPlayerId())
+	if err != nil {
+		return nil, toGrpcError(err)
+	}
+	return &matchmakingpb.GetStatusResponse{
+		PlayerId:      playerStatus.PlayerID,
+		CompetitionId: int64(playerStatus.CompetitionID),
+		State:         toGrpcState(playerStatus.State),
+		QueuedAt:      timestamppb.New(playerStatus.QueuedAt),
+		PartyId:       playerStatus.PartyID,
+	}, nil
+}
+
+// ConfirmReady confirms the ready check for every player of the join stream of the player, like the ready
+// command of the TCP protocol confirms it for every player of the connection
+func (s *grpcServer) ConfirmReady(ctx context.Context, request *matchmakingpb.ConfirmReadyRequest) (*matchmakingpb.ConfirmReadyResponse, error) {
+	players, err := s.getStreamPlayers(request.GetQueue(), request.GetPlayerId())
+	if err != nil {
+		return nil, toGrpcError(err)
+	}
+	for _, playerID := range players.playerIDs {
+		players.queue.ConfirmReady(playerID)
+	}
+	return &matchmakingpb.ConfirmReadyResponse{}, nil
+}
+
+func (s *grpcServer) ReportPlayerDropped(ctx context.Context, request *matchmakingpb.ReportPlayerDroppedRequest) (*matchmakingpb.ReportPlayerDroppedResponse, error) {
+	err := reportPlayerDropped(s.queues, s.gameServerAuth, droppedPlayerReport{
+		CompetitionID: int(request.GetCompetitionId()),
+		PlayerID:      request.GetPlayerId(),
+		Queue:         request.GetQueue(),
+		Token:         getGameServerToken(ctx),
+	})
+	if err != nil {
+		return nil, toGrpcError(err)
+	}
+	return &matchmakingpb.ReportPlayerDroppedResponse{}, nil
+}
+
+func (s *grpcServer) Shutdown(ctx context.Context) error {
+	if _, started := s.connections.startShutdown(nil); !started {
+		return nil
+	}
+	slog.Info("Shutting down gRPC server")
+
+	// the gRPC server stops accepting calls right away, and waits for the join streams to end
+	// while the queues notify the players
+	serverStopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(serverStopped)
+	}()
+	queuesErr := s.queues.Shutdown(ctx)
+
+	select {
+	case <-serverStopped:
+	case <-ctx.Done():
+		slog.Warn("Shutdown deadline passed. Closing remaining gRPC streams")
+		s.server.Stop()
+		return errors.Join(queuesErr, ctx.Err())
+	}
+	// the streams have ended, so no goroutine is started anymore
+	if err := s.connections.wait(ctx); err != nil {
+		return errors.Join(queuesErr, err)
+	}
+	slog.Info("gRPC server shut down")
+	return queuesErr
+}
+
+func toJoinRequest(request *matchmakingpb.JoinRequest) playerJoinRequest {
+	joinRequest := playerJoinRequest{
+		PlayerData:     toPlayerData(request.GetPlayer()),
+		Queue:          request.GetQueue(),
+		IdempotencyKey: request.GetIdempotencyKey(),
+	}
+	for _, member := range request.GetMembers() {
+		joinRequest.Members = append(joinRequest.Members, toPlayerData(member))
+	}
+	return joinRequest
+}
+
+func toPlayerData(player *matchmakingpb.Player) model.PlayerData {
+	playerData := model.PlayerData{
+		ID:              player.GetId(),
+		Level:           int(player.GetLevel()),
+		Rating:          player.GetRating(),
+		RatingDeviation: player.GetRatingDeviation(),
+	}
+	if len(player.GetPings()) > 0 {
+		playerData.Pings = make(map[string]int, len(player.GetPings()))
+		for region, ping := range player.GetPings() {
+			playerData.Pings[region] = int(ping)
+		}
+	}
+	return playerData
+}
+
+func toGrpcNotification(notification matchmaking.MatchMakingNotification) *matchmakingpb.Notification {
+	return &matchmakingpb.Notification{
+		CompetitionId: int64(notification.CompetitionID),
+		State:         toGrpcState(notification.State),
+		Team:          int32(notification.Team),
+		Region:        notification.Region,
+	}
+}
+
+var grpcStates = map[matchmaking.MatchmakingState]matchmakingpb.MatchmakingState{
+	matchmaking.State_WaitingForPlayers:  matchmakingpb.MatchmakingState_MATCHMAKING_STATE_WAITING_FOR_PLAYERS,
+	matchmaking.State_Started:            matchmakingpb.MatchmakingState_MATCHMAKING_STATE_STARTED,
+	matchmaking.State_Aborted:            matchmakingpb.MatchmakingState_MATCHMAKING_STATE_ABORTED,
+	matchmaking.State_Cancelled:          matchmakingpb.MatchmakingState_MATCHMAKING_STATE_CANCELLED,
+	matchmaking.State_Requeued:           matchmakingpb.MatchmakingState_MATCHMAKING_STATE_REQUEUED,
+	matchmaking.State_ReadyCheck:         matchmakingpb.MatchmakingState_MATCHMAKING_STATE_READY_CHECK,
+	matchmaking.State_ReadyCheckMissed:   matchmakingpb.MatchmakingState_MATCHMAKING_STATE_READY_CHECK_MISSED,
+	matchmaking.State_Backfill:           matchmakingpb.MatchmakingState_MATCHMAKING_STATE_BACKFILL,
+	matchmaking.State_SessionReplaced:    matchmakingpb.MatchmakingState_MATCHMAKING_STATE_SESSION_REPLACED,
+	matchmaking.State_ServerShuttingDown: matchmakingpb.MatchmakingState_MATCHMAKING_STATE_SERVER_SHUTTING_DOWN,
+}
+
+// toGrpcState returns the enum value of a state. States without an enum value are unspecified
+func toGrpcState(state matchmaking.MatchmakingState) matchmakingpb.MatchmakingState {
+	return grpcStates[state]
+}
+
+// getGameServerToken returns the game server token sent as a bearer token in the authorization metadata of a call
+// @return the token, or an empty string if the call has none
+func getGameServerToken(ctx context.Context) string {
+	for _, authorization := range metadata.ValueFromIncomingContext(ctx, "authorization") {
+		if token, ok := strings.CutPrefix(authorization, grpcBearerPrefix); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+// toGrpcError turns an error into a gRPC status with the status code matching its error code
+// The error code itself is attached as the reason of an ErrorInfo detail
+func toGrpcError(err error) error {
+	response := toErrorResponse(err)
+	grpcStatus := status.New(getGrpcCode(response.Code), response.Message)
+	detailed, detailErr := grpcStatus.WithDetails(&errdetails.ErrorInfo{Reason: string(response.Code), Domain: grpcErrorDomain})
+	if detailErr != nil {
+		return grpcStatus.Err()
+	}
+	return detailed.Err()
+}
+
+func getGrpcCode(code errorCode) codes.Code {
+	switch code {
+	case errorCode_Unauthenticated:
+		return codes.Unauthenticated
+	case errorCode_NotInMatchmaking, errorCode_UnknownQueue:
+		return codes.NotFound
+	case errorCode_AlreadyInMatchmaking, errorCode_AlreadyJoined:
+		return codes.AlreadyExists
+	case errorCode_ShuttingDown:
+		return codes.Unavailable
+	case errorCode_Internal:
+		return codes.Internal
+	default:
+		return codes.InvalidArgument
+	}
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
+	"github.com/SntrKslnn/matchmaking-service/internal/server/matchmakingpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startTestGrpcServer serves a gRPC server on an in-memory listener
+// @return a client connected to the server
+func startTestGrpcServer(t *testing.T, queues matchmaking.QueueRegistry) matchmakingpb.MatchmakingServiceClient {
+	server := NewGRPCServer(0, queues, newTestValidator(t), testGameServerToken).(*grpcServer)
+	listener := bufconn.Listen(1024 * 1024)
+	go server.server.Serve(listener)
+	t.Cleanup(server.server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to connect to gRPC server: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return matchmakingpb.NewMatchmakingServiceClient(conn)
+}
+
+func newTestGrpcContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), testReadTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// assertGrpcError checks the status code of an error and the error code in its ErrorInfo detail
+func assertGrpcError(t *testing.T, err error, code codes.Code, reason errorCode) {
+	t.Helper()
+	grpcStatus, ok := status.FromError(err)
+	if !assert.True(t, ok, "error should be a gRPC status: %v", err) {
+		return
+	}
+	assert.Equal(t, code, grpcStatus.Code())
+	if assert.Len(t, grpcStatus.Details(), 1) {
+		errorInfo, ok := grpcStatus.Details()[0].(*errdetails.ErrorInfo)
+		if assert.True(t, ok) {
+			assert.Equal(t, string(reason), errorInfo.GetReason())
+			assert.Equal(t, grpcErrorDomain, errorInfo.GetDomain())
+		}
+	}
+}
+
+func receiveGrpcState(t *testing.T, stream grpc.ServerStreamingClient[matchmakingpb.Notification]) matchmakingpb.MatchmakingState {
+	t.Helper()
+	notification, err := stream.Recv()
+	assert.NoError(t, err)
+	return notification.GetState()
+}
+
+func TestGrpcServer_JoinStreamsNotifications(t *testing.T) {
+	client := startTestGrpcServer(t, newTestQueues(t))
+	ctx := newTestGrpcContext(t)
+
+	first, err := client.Join(ctx, &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_1", Level: 5}})
+	assert.NoError(t, err)
+	notification, err := first.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), notification.GetCompetitionId())
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_WAITING_FOR_PLAYERS, notification.GetState())
+
+	second, err := client.Join(ctx, &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_2", Level: 5}})
+	assert.NoError(t, err)
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_WAITING_FOR_PLAYERS, receiveGrpcState(t, second))
+
+	// the stream ends after the competition started
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_STARTED, receiveGrpcState(t, first))
+	_, err = first.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestGrpcServer_LeaveAndGetStatus(t *testing.T) {
+	client := startTestGrpcServer(t, newTestQueues(t))
+	ctx := newTestGrpcContext(t)
+
+	stream, err := client.Join(ctx, &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_1", Level: 5}, Queue: "ready"})
+	assert.NoError(t, err)
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_WAITING_FOR_PLAYERS, receiveGrpcState(t, stream))
+
+	playerStatus, err := client.GetStatus(ctx, &matchmakingpb.GetStatusRequest{PlayerId: "player_1", Queue: "ready"})
+	assert.NoError(t, err)
+	assert.Equal(t, "player_1", playerStatus.GetPlayerId())
+	assert.Equal(t, int64(1), playerStatus.GetCompetitionId())
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_WAITING_FOR_PLAYERS, playerStatus.GetState())
+	assert.NotNil(t, playerStatus.GetQueuedAt())
+
+	// the player waits in the ready queue, not in the default queue
+	_, err = client.GetStatus(ctx, &matchmakingpb.GetStatusRequest{PlayerId: "player_1"})
+	assertGrpcError(t, err, codes.NotFound, errorCode_NotInMatchmaking)
+
+	_, err = client.Leave(ctx, &matchmakingpb.LeaveRequest{PlayerId: "player_1", Queue: "ready"})
+	assert.NoError(t, err)
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_CANCELLED, receiveGrpcState(t, stream))
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = client.Leave(ctx, &matchmakingpb.LeaveRequest{PlayerId: "player_1", Queue: "ready"})
+	assertGrpcError(t, err, codes.NotFound, errorCode_NotInMatchmaking)
+	_, err = client.GetStatus(ctx, &matchmakingpb.GetStatusRequest{PlayerId: "player_1", Queue: "unknown"})
+	assertGrpcError(t, err, codes.NotFound, errorCode_UnknownQueue)
+}
+
+func TestGrpcServer_ConfirmReady(t *testing.T) {
+	queues := newTestQueues(t)
+	client := startTestGrpcServer(t, queues)
+	ctx := newTestGrpcContext(t)
+
+	stream, err := client.Join(ctx, &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_1", Level: 5}, Queue: "ready"})
+	assert.NoError(t, err)
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_WAITING_FOR_PLAYERS, receiveGrpcState(t, stream))
+	tcpClient := connectTestClient(t, queues)
+	tcpClient.send(`{"ID":"player_2","Level":5,"Queue":"ready"}`)
+	assert.Equal(t, matchmaking.State_WaitingForPlayers, tcpClient.receiveNotification().State)
+
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_READY_CHECK, receiveGrpcState(t, stream))
+	assert.Equal(t, matchmaking.State_ReadyCheck, tcpClient.receiveNotification().State)
+
+	// only the players of the join streams of this server can be confirmed
+	_, err = client.ConfirmReady(ctx, &matchmakingpb.ConfirmReadyRequest{PlayerId: "player_2", Queue: "ready"})
+	assertGrpcError(t, err, codes.NotFound, errorCode_NotInMatchmaking)
+	_, err = client.ConfirmReady(ctx, &matchmakingpb.ConfirmReadyRequest{PlayerId: "player_1"})
+	assertGrpcError(t, err, codes.NotFound, errorCode_NotInMatchmaking)
+	_, err = client.ConfirmReady(ctx, &matchmakingpb.ConfirmReadyRequest{PlayerId: "player_1", Queue: "unknown"})
+	assertGrpcError(t, err, codes.NotFound, errorCode_UnknownQueue)
+
+	_, err = client.ConfirmReady(ctx, &matchmakingpb.ConfirmReadyRequest{PlayerId: "player_1", Queue: "ready"})
+	assert.NoError(t, err)
+	tcpClient.send(`{"Ready":true}`)
+
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_STARTED, receiveGrpcState(t, stream))
+	assert.Equal(t, matchmaking.State_Started, tcpClient.receiveNotification().State)
+
+	// the player is forgotten once its stream ended
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+	_, err = client.ConfirmReady(ctx, &matchmakingpb.ConfirmReadyRequest{PlayerId: "player_1", Queue: "ready"})
+	assertGrpcError(t, err, codes.NotFound, errorCode_NotInMatchmaking)
+}
+
+func TestGrpcServer_LeaveOfPlayerOfAnotherServerIsRejected(t *testing.T) {
+	queues := newTestQueues(t)
+	client := startTestGrpcServer(t, queues)
+	ctx := newTestGrpcContext(t)
+	tcpClient := connectTestClient(t, queues)
+	tcpClient.send(`{"ID":"player_1","Level":5}`)
+	assert.Equal(t, matchmaking.State_WaitingForPlayers, tcpClient.receiveNotification().State)
+
+	_, err := client.Leave(ctx, &matchmakingpb.LeaveRequest{PlayerId: "player_1"})
+	assertGrpcError(t, err, codes.NotFound, errorCode_NotInMatchmaking)
+
+	queue, _ := queues.GetQueue(matchmaking.DefaultQueueName)
+	_, err = queue.GetPlayerStatus("player_1")
+	assert.NoError(t, err, "player of another server should stay in matchmaking")
+}
+
+func TestGrpcServer_CancellingStreamLeavesMatchmaking(t *testing.T) {
+	queues := newTestQueues(t)
+	client := startTestGrpcServer(t, queues)
+
+	ctx, cancel := context.WithCancel(newTestGrpcContext(t))
+	stream, err := client.Join(ctx, &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_1", Level: 5}})
+	assert.NoError(t, err)
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_WAITING_FOR_PLAYERS, receiveGrpcState(t, stream))
+
+	cancel()
+	queue, _ := queues.GetQueue(matchmaking.DefaultQueueName)
+	assert.Eventually(t, func() bool {
+		_, err := queue.GetPlayerStatus("player_1")
+		return errors.Is(err, matchmaking.ErrPlayerNotInMatchmaking)
+	}, testReadTimeout, 10*time.Millisecond)
+}
+
+func TestGrpcServer_ReportPlayerDropped(t *testing.T) {
+	client := startTestGrpcServer(t, newTestQueues(t))
+	ctx := newTestGrpcContext(t)
+
+	first, err := client.Join(ctx, &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_1", Level: 5}, Queue: "backfill"})
+	assert.NoError(t, err)
+	receiveGrpcState(t, first)
+	second, err := client.Join(ctx, &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_2", Level: 5}, Queue: "backfill"})
+	assert.NoError(t, err)
+	receiveGrpcState(t, second)
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_STARTED, receiveGrpcState(t, first))
+
+	// a player cannot report another player as dropped
+	request := &matchmakingpb.ReportPlayerDroppedRequest{CompetitionId: 1, PlayerId: "player_2", Queue: "backfill"}
+	_, err = client.ReportPlayerDropped(ctx, request)
+	assertGrpcError(t, err, codes.Unauthenticated, errorCode_Unauthenticated)
+	_, err = client.ReportPlayerDropped(metadata.AppendToOutgoingContext(ctx, "authorization", grpcBearerPrefix+"guess"), request)
+	assertGrpcError(t, err, codes.Unauthenticated, errorCode_Unauthenticated)
+
+	gameServerCtx := metadata.AppendToOutgoingContext(ctx, "authorization", grpcBearerPrefix+testGameServerToken)
+	_, err = client.ReportPlayerDropped(gameServerCtx, request)
+	assert.NoError(t, err)
+
+	// the slot of the dropped player is filled by the next player
+	stream, err := client.Join(ctx, &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_3", Level: 5}, Queue: "backfill"})
+	assert.NoError(t, err)
+	assert.Equal(t, matchmakingpb.MatchmakingState_MATCHMAKING_STATE_BACKFILL, receiveGrpcState(t, stream))
+
+	_, err = client.ReportPlayerDropped(gameServerCtx, &matchmakingpb.ReportPlayerDroppedRequest{CompetitionId: 1})
+	assertGrpcError(t, err, codes.InvalidArgument, errorCode_InvalidPayload)
+	_, err = client.ReportPlayerDropped(gameServerCtx, &matchmakingpb.ReportPlayerDroppedRequest{CompetitionId: 1, PlayerId: "player_1", Queue: "unknown"})
+	assertGrpcError(t, err, codes.NotFound, errorCode_UnknownQueue)
+}
+
+func TestGrpcServer_JoinErrors(t *testing.T) {
+	queues := newTestQueues(t)
+	client := startTestGrpcServer(t, queues)
+	ctx := newTestGrpcContext(t)
+
+	stream, err := client.Join(ctx, &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_1", Level: 5}})
+	assert.NoError(t, err)
+	receiveGrpcState(t, stream)
+
+	tests := []struct {
+		name    string
+		request *matchmakingpb.JoinRequest
+		code    codes.Code
+		reason  errorCode
+	}{
+		{"missing player id", &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Level: 5}}, codes.InvalidArgument, errorCode_MissingPlayerID},
+		{"invalid player id", &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player 2", Level: 5}}, codes.InvalidArgument, errorCode_InvalidPlayerID},
+		{"level out of range", &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_2", Level: 5000}}, codes.InvalidArgument, errorCode_LevelOutOfRange},
+		{"invalid ping", &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_2", Level: 5, Pings: map[string]int32{"eu": -1}}}, codes.InvalidArgument, errorCode_InvalidPing},
+		{"invalid party", &matchmakingpb.JoinRequest{Members: []*matchmakingpb.Player{{Id: "player_2", Level: 5}, {Id: "player_2", Level: 5}}}, codes.InvalidArgument, errorCode_InvalidParty},
+		{"unknown queue", &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_2", Level: 5}, Queue: "unknown"}, codes.NotFound, errorCode_UnknownQueue},
+		{"already in matchmaking", &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_1", Level: 5}}, codes.AlreadyExists, errorCode_AlreadyInMatchmaking},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream, err := client.Join(ctx, test.request)
+			assert.NoError(t, err)
+			_, err = stream.Recv()
+			assertGrpcError(t, err, test.code, test.reason)
+		})
+	}
+}
+
+func TestGrpcServer_JoinAfterShutdownIsUnavailable(t *testing.T) {
+	queues := newTestQueues(t)
+	client := startTestGrpcServer(t, queues)
+	ctx := newTestGrpcContext(t)
+	assert.NoError(t, queues.Shutdown(ctx))
+
+	stream, err := client.Join(ctx, &matchmakingpb.JoinRequest{Player: &matchmakingpb.Player{Id: "player_1", Level: 5}})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assertGrpcError(t, err, codes.Unavailable, errorCode_ShuttingDown)
+}
+
+func TestToGrpcError_InternalError(t *testing.T) {
+	assertGrpcError(t, toGrpcError(errors.New("failure")), codes.Internal, errorCode_Internal)
+}
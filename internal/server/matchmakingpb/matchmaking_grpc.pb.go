@@ -0,0 +1,305 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: matchmaking/v1/matchmaking.proto
+
+package matchmakingpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MatchmakingService_Join_FullMethodName                = "/matchmaking.v1.MatchmakingService/Join"
+	MatchmakingService_Leave_FullMethodName               = "/matchmaking.v1.MatchmakingService/Leave"
+	MatchmakingService_GetStatus_FullMethodName           = "/matchmaking.v1.MatchmakingService/GetStatus"
+	MatchmakingService_ConfirmReady_FullMethodName        = "/matchmaking.v1.MatchmakingService/ConfirmReady"
+	MatchmakingService_ReportPlayerDropped_FullMethodName = "/matchmaking.v1.MatchmakingService/ReportPlayerDropped"
+)
+
+// MatchmakingServiceClient is the client API for MatchmakingService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MatchmakingService lets players join the matchmaking queues of the server
+// Players joined over gRPC are matched together with the players of the other servers
+type MatchmakingServiceClient interface {
+	// Join joins a player or a party and streams the notifications of the player, or of the first member of the party
+	// The stream ends when the competition starts or is aborted, or the player leaves matchmaking
+	// Cancelling the stream removes the players from matchmaking
+	Join(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Notification], error)
+	// Leave removes a player from matchmaking before the competition starts
+	// Only players joined over a join stream of this server can be removed
+	Leave(ctx context.Context, in *LeaveRequest, opts ...grpc.CallOption) (*LeaveResponse, error)
+	// GetStatus returns the competition and the state of a player in matchmaking
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error)
+	// ConfirmReady confirms the ready check for the players of the join stream of a player
+	// Only players joined over a join stream of this server can be confirmed
+	ConfirmReady(ctx context.Context, in *ConfirmReadyRequest, opts ...grpc.CallOption) (*ConfirmReadyResponse, error)
+	// ReportPlayerDropped reports a player that dropped out of a started competition
+	// The open slot is filled with a new player if the competition is in its backfill window
+	// Only game servers may report, sending the game server token in the "authorization: Bearer <token>" metadata
+	ReportPlayerDropped(ctx context.Context, in *ReportPlayerDroppedRequest, opts ...grpc.CallOption) (*ReportPlayerDroppedResponse, error)
+}
+
+type matchmakingServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMatchmakingServiceClient(cc grpc.ClientConnInterface) MatchmakingServiceClient {
+	return &matchmakingServiceClient{cc}
+}
+
+func (c *matchmakingServiceClient) Join(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Notification], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MatchmakingService_ServiceDesc.Streams[0], MatchmakingService_Join_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[JoinRequest, Notification]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MatchmakingService_JoinClient = grpc.ServerStreamingClient[Notification]
+
+func (c *matchmakingServiceClient) Leave(ctx context.Context, in *LeaveRequest, opts ...grpc.CallOption) (*LeaveResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LeaveResponse)
+	err := c.cc.Invoke(ctx, MatchmakingService_Leave_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *matchmakingServiceClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatusResponse)
+	err := c.cc.Invoke(ctx, MatchmakingService_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *matchmakingServiceClient) ConfirmReady(ctx context.Context, in *ConfirmReadyRequest, opts ...grpc.CallOption) (*ConfirmReadyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConfirmReadyResponse)
+	err := c.cc.Invoke(ctx, MatchmakingService_ConfirmReady_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *matchmakingServiceClient) ReportPlayerDropped(ctx context.Context, in *ReportPlayerDroppedRequest, opts ...grpc.CallOption) (*ReportPlayerDroppedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportPlayerDroppedResponse)
+	err := c.cc.Invoke(ctx, MatchmakingService_ReportPlayerDropped_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MatchmakingServiceServer is the server API for MatchmakingService service.
+// All implementations must embed UnimplementedMatchmakingServiceServer
+// for forward compatibility.
+//
+// MatchmakingService lets players join the matchmaking queues of the server
+// Players joined over gRPC are matched together with the players of the other servers
+type MatchmakingServiceServer interface {
+	// Join joins a player or a party and streams the notifications of the player, or of the first member of the party
+	// The stream ends when the competition starts or is aborted, or the player leaves matchmaking
+	// Cancelling the stream removes the players from matchmaking
+	Join(*JoinRequest, grpc.ServerStreamingServer[Notification]) error
+	// Leave removes a player from matchmaking before the competition starts
+	// Only players joined over a join stream of this server can be removed
+	Leave(context.Context, *LeaveRequest) (*LeaveResponse, error)
+	// GetStatus returns the competition and the state of a player in matchmaking
+	GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error)
+	// ConfirmReady confirms the ready check for the players of the join stream of a player
+	// Only players joined over a join stream of this server can be confirmed
+	ConfirmReady(context.Context, *ConfirmReadyRequest) (*ConfirmReadyResponse, error)
+	// ReportPlayerDropped reports a player that dropped out of a started competition
+	// The open slot is filled with a new player if the competition is in its backfill window
+	// Only game servers may report, sending the game server token in the "authorization: Bearer <token>" metadata
+	ReportPlayerDropped(context.Context, *ReportPlayerDroppedRequest) (*ReportPlayerDroppedResponse, error)
+	mustEmbedUnimplementedMatchmakingServiceServer()
+}
+
+// UnimplementedMatchmakingServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMatchmakingServiceServer struct{}
+
+func (UnimplementedMatchmakingServiceServer) Join(*JoinRequest, grpc.ServerStreamingServer[Notification]) error {
+	return status.Errorf(codes.Unimplemented, "method Join not implemented")
+}
+func (UnimplementedMatchmakingServiceServer) Leave(context.Context, *LeaveRequest) (*LeaveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Leave not implemented")
+}
+func (UnimplementedMatchmakingServiceServer) GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedMatchmakingServiceServer) ConfirmReady(context.Context, *ConfirmReadyRequest) (*ConfirmReadyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmReady not implemented")
+}
+func (UnimplementedMatchmakingServiceServer) ReportPlayerDropped(context.Context, *ReportPlayerDroppedRequest) (*ReportPlayerDroppedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportPlayerDropped not implemented")
+}
+func (UnimplementedMatchmakingServiceServer) mustEmbedUnimplementedMatchmakingServiceServer() {}
+func (UnimplementedMatchmakingServiceServer) testEmbeddedByValue()                            {}
+
+// UnsafeMatchmakingServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MatchmakingServiceServer will
+// result in compilation errors.
+type UnsafeMatchmakingServiceServer interface {
+	mustEmbedUnimplementedMatchmakingServiceServer()
+}
+
+func RegisterMatchmakingServiceServer(s grpc.ServiceRegistrar, srv MatchmakingServiceServer) {
+	// If the following call pancis, it indicates UnimplementedMatchmakingServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MatchmakingService_ServiceDesc, srv)
+}
+
+func _MatchmakingService_Join_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(JoinRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MatchmakingServiceServer).Join(m, &grpc.GenericServerStream[JoinRequest, Notification]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MatchmakingService_JoinServer = grpc.ServerStreamingServer[Notification]
+
+func _MatchmakingService_Leave_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchmakingServiceServer).Leave(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchmakingService_Leave_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchmakingServiceServer).Leave(ctx, req.(*LeaveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MatchmakingService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchmakingServiceServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchmakingService_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchmakingServiceServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MatchmakingService_ConfirmReady_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmReadyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchmakingServiceServer).ConfirmReady(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchmakingService_ConfirmReady_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchmakingServiceServer).ConfirmReady(ctx, req.(*ConfirmReadyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MatchmakingService_ReportPlayerDropped_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportPlayerDroppedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatchmakingServiceServer).ReportPlayerDropped(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MatchmakingService_ReportPlayerDropped_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatchmakingServiceServer).ReportPlayerDropped(ctx, req.(*ReportPlayerDroppedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// MatchmakingService_ServiceDesc is the grpc.ServiceDesc for MatchmakingService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MatchmakingService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "matchmaking.v1.MatchmakingService",
+	HandlerType: (*MatchmakingServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Leave",
+			Handler:    _MatchmakingService_Leave_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _MatchmakingService_GetStatus_Handler,
+		},
+		{
+			MethodName: "ConfirmReady",
+			Handler:    _MatchmakingService_ConfirmReady_Handler,
+		},
+		{
+			MethodName: "ReportPlayerDropped",
+			Handler:    _MatchmakingService_ReportPlayerDropped_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Join",
+			Handler:       _MatchmakingService_Join_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "matchmaking/v1/matchmaking.proto",
+}
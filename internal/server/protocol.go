@@ -0,0 +1,249 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/results"
+	"github.com/SntrKslnn/matchmaking-service/internal/validation"
+)
+
+// protocolVersion is the version of the command envelope understood by the server
+const protocolVersion = 1
+
+// requestEnvelope is a versioned command sent by a client, for example
+// {"v":1,"type":"join","request_id":"1","payload":{"ID":"4","Level":4}}
+type requestEnvelope struct {
+	V         *int            `json:"v"`
+	Type      requestType     `json:"type"`
+	RequestID string          `json:"request_id"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// responseEnvelope is a versioned message sent to a client
+// Responses carry the request id of the request they answer. Notifications carry the request id of the join
+// that queued the player
+type responseEnvelope struct {
+	V         int          `json:"v"`
+	Type      responseType `json:"type"`
+	RequestID string       `json:"request_id,omitempty"`
+	Payload   any          `json:"payload,omitempty"`
+}
+
+type requestType string
+
+const (
+	requestType_Join          requestType = "join"
+	requestType_Leave         requestType = "leave"
+	requestType_Status        requestType = "status"
+	requestType_Ping          requestType = "ping"
+	requestType_Ready         requestType = "ready"
+	requestType_ReportResult  requestType = "report_result"
+	requestType_ReportDropped requestType = "report_dropped"
+)
+
+type responseType string
+
+const (
+	responseType_Joined         responseType = "joined"
+	responseType_Left           responseType = "left"
+	responseType_Status         responseType = "status"
+	responseType_Pong           responseType = "pong"
+	responseType_ReadyConfirmed responseType = "ready_confirmed"
+	responseType_ResultReported responseType = "result_reported"
+	responseType_DropReported   responseType = "drop_reported"
+	responseType_Notification   responseType = "notification"
+	responseType_Error          responseType = "error"
+)
+
+// playerJoinRequest is a request of a single player or a party to join matchmaking
+// It is the payload of a join command, and the whole request in the unversioned protocol
+type playerJoinRequest struct {
+	model.PlayerData
+
+	// Members are the players of a party joining together. If set, the player data is ignored
+	Members []model.PlayerData
+
+	// Queue is the name of the queue to join. The default queue is joined if empty
+	Queue string
+
+	// IdempotencyKey identifies the join of a single player across retries, so that a retried join
+	// does not queue the player twice. Not used for parties
+	IdempotencyKey string
+}
+
+// resultReport is the result of a competition in progress
+type resultReport struct {
+	CompetitionID int
+	Players       []results.PlayerResult
+
+	// Queue is the name of the queue the competition was matched in. The default queue is used if empty
+	Queue string
+
+	// Token is the game server token. Only game servers may report results
+	Token string
+}
+
+// droppedPlayerReport reports a player that dropped out of a started competition, so that its slot can be backfilled
+type droppedPlayerReport struct {
+	CompetitionID int
+	PlayerID      string
+
+	// Queue is the name of the queue the competition was matched in. The default queue is used if empty
+	Queue string
+
+	// Token is the game server token. Only game servers may report dropped players
+	Token string
+}
+
+// resultReportRequest is a request reporting the result of a competition in the unversioned protocol
+type resultReportRequest struct {
+	Result *struct {
+		CompetitionID int
+		Players       []results.PlayerResult
+	}
+
+	// Queue is the name of the queue the competition was matched in. The default queue is used if empty
+	Queue string
+
+	// Token is the game server token. Only game servers may report results
+	Token string
+}
+
+// resultReportResponse tells the reporter whether the result was accepted
+type resultReportResponse struct {
+	CompetitionID int
+	Accepted      bool
+	Error         string `json:",omitempty"`
+}
+
+// playerCommand is a request sent by a player that is waiting in matchmaking in the unversioned protocol
+type playerCommand struct {
+	// Ready confirms the ready check of the competition
+	Ready bool
+}
+
+// decodeJoinRequest decodes a join request, rejecting fields a join request does not have
+// @param data the JSON of the join request
+// @param invalidCode the error code returned if the data is not a JSON object
+// @return the join request, or a protocol error with the unknown_field code if the request has unknown fields
+func decodeJoinRequest(data []byte, invalidCode errorCode) (playerJoinRequest, error) {
+	joinRequest := playerJoinRequest{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&joinRequest)
+	if err == nil {
+		return joinRequest, nil
+	}
+	// the decoder reports unknown fields with an untyped error, so the request is decoded again without the check
+	// to tell an unknown field apart from invalid JSON
+	if json.Unmarshal(data, &playerJoinRequest{}) == nil {
+		return playerJoinRequest{}, newProtocolError(errorCode_UnknownField, fmt.Errorf("invalid join request: %w", err))
+	}
+	return playerJoinRequest{}, newProtocolError(invalidCode, fmt.Errorf("invalid join request: %w", err))
+}
+
+// joinedResponse is the payload of the response to a join command
+type joinedResponse struct {
+	PlayerIDs []string
+	Queue     string
+}
+
+// leftResponse is the payload of the response to a leave command
+type leftResponse struct {
+	PlayerIDs []string
+}
+
+// statusResponse is the payload of the response to a status command
+// Players is empty if the connection has not joined matchmaking
+type statusResponse struct {
+	Players []matchmaking.PlayerStatus
+}
+
+type errorCode string
+
+const (
+	errorCode_InvalidJSON          errorCode = "invalid_json"
+	errorCode_UnsupportedVersion   errorCode = "unsupported_version"
+	errorCode_UnknownType          errorCode = "unknown_type"
+	errorCode_InvalidPayload       errorCode = "invalid_payload"
+	errorCode_UnknownField         errorCode = "unknown_field"
+	errorCode_MissingPlayerID      errorCode = "missing_player_id"
+	errorCode_PlayerIDTooLong      errorCode = "player_id_too_long"
+	errorCode_InvalidPlayerID      errorCode = "invalid_player_id"
+	errorCode_LevelOutOfRange      errorCode = "level_out_of_range"
+	errorCode_InvalidPing          errorCode = "invalid_ping"
+	errorCode_UnknownQueue         errorCode = "unknown_queue"
+	errorCode_AlreadyJoined        errorCode = "already_joined"
+	errorCode_AlreadyInMatchmaking errorCode = "already_in_matchmaking"
+	errorCode_NotInMatchmaking     errorCode = "not_in_matchmaking"
+	errorCode_InvalidParty         errorCode = "invalid_party"
+	errorCode_ResultRejected       errorCode = "result_rejected"
+	errorCode_Unauthenticated      errorCode = "unauthenticated"
+	errorCode_ShuttingDown         errorCode = "shutting_down"
+	errorCode_Internal             errorCode = "internal_error"
+)
+
+// errorResponse is the payload of an error message
+type errorResponse struct {
+	Code    errorCode
+	Message string
+}
+
+// protocolError is an error that is reported to the client with an error code
+type protocolError struct {
+	code errorCode
+	err  error
+}
+
+func newProtocolError(code errorCode, err error) *protocolError {
+	return &protocolError{code: code, err: err}
+}
+
+func (e *protocolError) Error() string {
+	return e.err.Error()
+}
+
+func (e *protocolError) Unwrap() error {
+	return e.err
+}
+
+// toErrorResponse turns an error into the error payload sent to the client
+// Errors of the matchmaking service are mapped to their codes, other errors are internal errors
+func toErrorResponse(err error) errorResponse {
+	var protocolErr *protocolError
+	switch {
+	case errors.As(err, &protocolErr):
+		return errorResponse{Code: protocolErr.code, Message: err.Error()}
+	case errors.Is(err, validation.ErrMissingPlayerID):
+		return errorResponse{Code: errorCode_MissingPlayerID, Message: err.Error()}
+	case errors.Is(err, validation.ErrPlayerIDTooLong):
+		return errorResponse{Code: errorCode_PlayerIDTooLong, Message: err.Error()}
+	case errors.Is(err, validation.ErrInvalidPlayerID):
+		return errorResponse{Code: errorCode_InvalidPlayerID, Message: err.Error()}
+	case errors.Is(err, validation.ErrLevelOutOfRange):
+		return errorResponse{Code: errorCode_LevelOutOfRange, Message: err.Error()}
+	case errors.Is(err, validation.ErrInvalidPing):
+		return errorResponse{Code: errorCode_InvalidPing, Message: err.Error()}
+	case errors.Is(err, matchmaking.ErrUnknownQueue):
+		return errorResponse{Code: errorCode_UnknownQueue, Message: err.Error()}
+	case errors.Is(err, matchmaking.ErrPlayerAlreadyInMatchmaking):
+		return errorResponse{Code: errorCode_AlreadyInMatchmaking, Message: err.Error()}
+	case errors.Is(err, matchmaking.ErrPlayerNotInMatchmaking):
+		return errorResponse{Code: errorCode_NotInMatchmaking, Message: err.Error()}
+	case errors.Is(err, matchmaking.ErrEmptyParty), errors.Is(err, matchmaking.ErrPartyTooLarge), errors.Is(err, matchmaking.ErrDuplicatePartyMember):
+		return errorResponse{Code: errorCode_InvalidParty, Message: err.Error()}
+	case errors.Is(err, matchmaking.ErrUnknownCompetition), errors.Is(err, matchmaking.ErrResultRosterMismatch),
+		errors.Is(err, matchmaking.ErrDuplicatePlayerResult), errors.Is(err, matchmaking.ErrInvalidPlacement),
+		errors.Is(err, results.ErrResultAlreadyStored):
+		return errorResponse{Code: errorCode_ResultRejected, Message: err.Error()}
+	case errors.Is(err, matchmaking.ErrMatchmakingShutDown):
+		return errorResponse{Code: errorCode_ShuttingDown, Message: err.Error()}
+	default:
+		return errorResponse{Code: errorCode_Internal, Message: err.Error()}
+	}
+}
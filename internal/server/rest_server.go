@@ -0,0 +1,497 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
+	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
+	"github.com/SntrKslnn/matchmaking-service/internal/validation"
+)
+
+// OpenAPIPath is the path the OpenAPI description of the REST API is served at
+const OpenAPIPath = "/openapi.yaml"
+
+const (
+	// maxRestRequestSize is the size limit of a request body
+	maxRestRequestSize = 64 * 1024
+
+	// finishedPlayerRetention is the time the notifications of a player are kept after the player left matchmaking,
+	// so that a client connecting to the event stream late still receives the outcome
+	finishedPlayerRetention = time.Minute
+
+	// eventStreamKeepAliveInterval is the time between two comments sent on an idle event stream,
+	// so that proxies do not close it
+	eventStreamKeepAliveInterval = 15 * time.Second
+
+	// playerTokenSize is the number of random bytes of a player token
+	playerTokenSize = 16
+)
+
+//go:embed openapi.yaml
+var openAPIDescription []byte
+
+type MatchmakingRestServer interface {
+	// Start starts the HTTP server and serves requests until the server is shut down
+	// @return an error if the server cannot listen on its port
+	Start() error
+
+	// Shutdown stops accepting requests and shuts down the matchmaking queues, which tells the waiting players
+	// that the server is shutting down and starts or aborts their competitions. The event streams are closed
+	// once their players have received the outcome, or when the deadline passes
+	// @param ctx the deadline for the shutdown
+	// @return the error of the context if the shutdown did not finish before its deadline
+	Shutdown(ctx context.Context) error
+}
+
+// restServer lets clients join matchmaking with HTTP requests and receive the notifications as Server-Sent Events
+// A player joined over HTTP stays in matchmaking until it leaves with a DELETE request or its competition
+// starts or is aborted. Closing an event stream does not remove the player. Only players joined over HTTP
+// can be removed with a DELETE request, players of the other servers leave over their own connections
+// The join answers with a token for every player, which the requests for the player must carry
+type restServer struct {
+	port      int
+	queues    matchmaking.QueueRegistry
+	validator validation.JoinValidator
+	// clock times the retention of the notifications of finished players
+	clock clock.Clock
+
+	httpServer  *http.Server
+	connections *connectionTracker
+
+	// mutex guards the players and serializes joins, so that a player is never registered twice
+	mutex sync.Mutex
+	// players are the players joined over HTTP by player id
+	players map[string]*restPlayer
+}
+
+// restPlayer is a player joined over HTTP and the notifications it received
+type restPlayer struct {
+	id        string
+	queueName string
+	queue     matchmaking.MatchmakingService
+	// token authorizes the requests for the player
+	token string
+
+	// mutex guards the notifications, updated and finished
+	mutex         sync.Mutex
+	notifications []matchmaking.MatchMakingNotification
+	// updated is closed and replaced when a notification is received or the player leaves matchmaking
+	updated chan struct{}
+	// finished is true when the notification channel of the player was closed
+	finished bool
+}
+
+// NewRestServer creates an HTTP server that lets players join the queues of the registry
+// @param port the port to listen on
+// @param queues the matchmaking queues players can join
+// @param validator the validator checking the players of join requests before they join a queue
+// @return a new REST server
+func NewRestServer(port int, queues matchmaking.QueueRegistry, validator validation.JoinValidator) MatchmakingRestServer {
+	s := &restServer{
+		port:        port,
+		queues:      queues,
+		validator:   validator,
+		clock:       clock.NewRealClock(),
+		connections: newConnectionTracker(),
+		players:     make(map[string]*restPlayer),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/queue", s.handleJoin)
+	mux.HandleFunc("DELETE /v1/queue/{playerID}", s.handleLeave)
+	mux.HandleFunc("POST /v1/queue/{playerID}/ready", s.handleReady)
+	mux.HandleFunc("GET /v1/queue/{playerID}/events", s.handleEvents)
+	mux.HandleFunc("GET "+OpenAPIPath, s.handleOpenAPI)
+	s.httpServer = &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+	return s
+}
+
+func (s *restServer) Start() error {
+	slog.Info("HTTP Server listening.", "port", s.port)
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		slog.Info("HTTP Server stopped listening.", "port", s.port)
+		return nil
+	}
+	return fmt.Errorf("failed to start HTTP server: %w", err)
+}
+
+// restJoinedResponse is the response to a join over HTTP
+type restJoinedResponse struct {
+	joinedResponse
+	// Tokens are the player tokens by player id, which the requests for the players must carry
+	Tokens map[string]string
+}
+
+// handleJoin joins the player or the party of the request body, which is a join request of the TCP protocol
+// Every member of a party gets its own event stream and token
+func (s *restServer) handleJoin(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRestRequestSize))
+	if err != nil {
+		s.writeError(w, newProtocolError(errorCode_InvalidPayload, fmt.Errorf("cannot read request body: %w", err)))
+		return
+	}
+	joinRequest, err := decodeJoinRequest(data, errorCode_InvalidJSON)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	response, err := s.join(joinRequest)
+	if err != nil {
+		slog.Warn("Rejected HTTP join request", "error", err)
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusAccepted, response)
+}
+
+func (s *restServer) join(joinRequest playerJoinRequest) (restJoinedResponse, error) {
+	if err := validateJoinRequest(s.validator, joinRequest); err != nil {
+		return restJoinedResponse{}, err
+	}
+	queue, err := s.queues.GetQueue(joinRequest.Queue)
+	if err != nil {
+		return restJoinedResponse{}, err
+	}
+	queueName := joinRequest.Queue
+	if queueName == "" {
+		queueName = matchmaking.DefaultQueueName
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.connections.isShuttingDown() {
+		return restJoinedResponse{}, matchmaking.ErrMatchmakingShutDown
+	}
+
+	playerIDs := []string{joinRequest.ID}
+	if len(joinRequest.Members) > 0 {
+		playerIDs = make([]string, len(joinRequest.Members))
+		for i, member := range joinRequest.Members {
+			playerIDs[i] = member.ID
+		}
+	}
+	// a player joined over HTTP can only be in one queue, since the event stream of the player is found by its id
+	for _, playerID := range playerIDs {
+		if player, joined := s.players[playerID]; joined && player.queueName != queueName && !player.isFinished() {
+			return restJoinedResponse{}, fmt.Errorf("%w: %s is waiting in queue %s", matchmaking.ErrPlayerAlreadyInMatchmaking, playerID, player.queueName)
+		}
+	}
+	tokens := make(map[string]string, len(playerIDs))
+	for _, playerID := range playerIDs {
+		token, err := newPlayerToken()
+		if err != nil {
+			return restJoinedResponse{}, err
+		}
+		tokens[playerID] = token
+	}
+
+	if len(joinRequest.Members) == 0 {
+		notifications, err := queue.HandleIdempotentPlayerJoin(joinRequest.PlayerData, joinRequest.IdempotencyKey)
+		if err != nil {
+			return restJoinedResponse{}, fmt.Errorf("player cannot join matchmaking: %w", err)
+		}
+		s.registerPlayer(joinRequest.ID, tokens[joinRequest.ID], queueName, queue, notifications)
+	} else {
+		partyNotifications, err := queue.HandlePartyJoin(joinRequest.Members)
+		if err != nil {
+			return restJoinedResponse{}, fmt.Errorf("party cannot join matchmaking: %w", err)
+		}
+		for _, playerID := range playerIDs {
+			s.registerPlayer(playerID, tokens[playerID], queueName, queue, partyNotifications[playerID])
+		}
+	}
+	return restJoinedResponse{joinedResponse: joinedResponse{PlayerIDs: playerIDs, Queue: queueName}, Tokens: tokens}, nil
+}
+
+// newPlayerToken creates a random token authorizing the requests for a player joined over HTTP
+func newPlayerToken() (string, error) {
+	data := make([]byte, playerTokenSize)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("cannot create player token: %w", err)
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// registerPlayer records a player joined over HTTP and collects its notifications until the channel is closed
+// The player is forgotten after the retention time, or when the server shuts down
+// A player joining again replaces the previous registration and its token, whose channel is closed by the matchmaking service
+func (s *restServer) registerPlayer(playerID string, token string, queueName string, queue matchmaking.MatchmakingService, notifications <-chan matchmaking.MatchMakingNotification) {
+	player := &restPlayer{
+		id:        playerID,
+		queueName: queueName,
+		queue:     queue,
+		token:     token,
+		updated:   make(chan struct{}),
+	}
+	s.players[playerID] = player
+
+	s.connections.startGoroutine(func() {
+		for notification := range notifications {
+			player.addNotification(notification)
+		}
+		player.finish()
+
+		select {
+		case <-s.clock.After(finishedPlayerRetention):
+		case <-s.connections.shuttingDown:
+		}
+		s.removePlayer(player)
+	})
+}
+
+// removePlayer forgets a player unless it was replaced by a new join meanwhile
+func (s *restServer) removePlayer(player *restPlayer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.players[player.id] == player {
+		delete(s.players, player.id)
+	}
+}
+
+func (s *restServer) getPlayer(playerID string) (*restPlayer, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	player, joined := s.players[playerID]
+	return player, joined
+}
+
+// getAuthorizedPlayer returns the player of the path of a request that carries the token of the player
+// @return the player, or an error if the player has not joined over HTTP or the request has not its token
+func (s *restServer) getAuthorizedPlayer(r *http.Request) (*restPlayer, error) {
+	playerID := r.PathValue("playerID")
+	player, joined := s.getPlayer(playerID)
+	if !joined {
+		return nil, newProtocolError(errorCode_NotInMatchmaking, fmt.Errorf("player %s has not joined over HTTP", playerID))
+	}
+	if subtle.ConstantTimeCompare([]byte(getPlayerToken(r)), []byte(player.token)) != 1 {
+		return nil, newProtocolError(errorCode_Unauthenticated, fmt.Errorf("request has not the token of player %s", playerID))
+	}
+	return player, nil
+}
+
+// getPlayerToken returns the player token of a request, sent as a bearer token in the Authorization header or in
+// the token query parameter, since browsers cannot set headers on an event source
+func getPlayerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+// handleLeave removes a player joined over HTTP from matchmaking
+// Players that joined over another server are not found, so HTTP clients cannot remove the players of other connections
+func (s *restServer) handleLeave(w http.ResponseWriter, r *http.Request) {
+	playerID := r.PathValue("playerID")
+	player, err := s.getAuthorizedPlayer(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if player.isFinished() {
+		s.writeError(w, newProtocolError(errorCode_NotInMatchmaking, fmt.Errorf("player %s is not in matchmaking over HTTP", playerID)))
+		return
+	}
+
+	if _, err := player.queue.GetPlayerStatus(playerID); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	player.queue.LeaveMatchmaking(playerID)
+	s.writeJSON(w, http.StatusOK, leftResponse{PlayerIDs: []string{playerID}})
+}
+
+// handleReady confirms the ready check for a player joined over HTTP
+// The confirmation has no effect if the player is not in a ready check
+func (s *restServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	player, err := s.getAuthorizedPlayer(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if player.isFinished() {
+		s.writeError(w, newProtocolError(errorCode_NotInMatchmaking, fmt.Errorf("player %s is not in matchmaking over HTTP", player.id)))
+		return
+	}
+	player.queue.ConfirmReady(player.id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEvents streams the notifications of a player joined over HTTP as Server-Sent Events
+// The notifications received before the stream was opened are sent first, starting after the Last-Event-ID
+// header if the client reconnects. The stream ends when the player leaves matchmaking
+func (s *restServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	player, err := s.getAuthorizedPlayer(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, errors.New("streaming is not supported"))
+		return
+	}
+
+	// the id of an event is the number of notifications sent up to and including it
+	sent := 0
+	if lastEventID, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil && lastEventID > 0 {
+		sent = lastEventID
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		notifications, updated, finished := player.getNotifications(sent)
+		for _, notification := range notifications {
+			sent++
+			if err := s.writeEvent(w, sent, notification); err != nil {
+				slog.Info("Error writing to event stream", "player_id", player.id, "error", err)
+				return
+			}
+		}
+		flusher.Flush()
+		if finished {
+			return
+		}
+
+		select {
+		case <-updated:
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *restServer) writeEvent(w io.Writer, id int, notification matchmaking.MatchMakingNotification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: notification\ndata: %s\n\n", id, data)
+	return err
+}
+
+func (s *restServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPIDescription)
+}
+
+func (s *restServer) writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Error writing HTTP response", "error", err)
+	}
+}
+
+// writeError writes the error payload of the TCP protocol with the HTTP status matching its code
+func (s *restServer) writeError(w http.ResponseWriter, err error) {
+	response := toErrorResponse(err)
+	s.writeJSON(w, getHTTPStatus(response.Code), response)
+}
+
+func getHTTPStatus(code errorCode) int {
+	switch code {
+	case errorCode_Unauthenticated:
+		return http.StatusUnauthorized
+	case errorCode_NotInMatchmaking, errorCode_UnknownQueue:
+		return http.StatusNotFound
+	case errorCode_AlreadyInMatchmaking, errorCode_AlreadyJoined:
+		return http.StatusConflict
+	case errorCode_ShuttingDown:
+		return http.StatusServiceUnavailable
+	case errorCode_Internal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (s *restServer) Shutdown(ctx context.Context) error {
+	if _, started := s.connections.startShutdown(nil); !started {
+		return nil
+	}
+	// joins check for the shutdown while holding the mutex, so no join starts collecting notifications after this
+	s.mutex.Lock()
+	players := len(s.players)
+	s.mutex.Unlock()
+	slog.Info("Shutting down HTTP server", "players", players)
+
+	// the HTTP server stops listening right away, and waits for the event streams to end
+	// while the queues notify the players
+	httpServerDone := make(chan error, 1)
+	go func() {
+		httpServerDone <- s.httpServer.Shutdown(ctx)
+	}()
+	queuesErr := s.queues.Shutdown(ctx)
+	err := errors.Join(s.connections.wait(ctx), <-httpServerDone)
+	if err != nil {
+		slog.Warn("Shutdown deadline passed. Closed remaining event streams")
+		s.httpServer.Close()
+		return errors.Join(queuesErr, err)
+	}
+	slog.Info("HTTP server shut down")
+	return queuesErr
+}
+
+func (p *restPlayer) addNotification(notification matchmaking.MatchMakingNotification) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.notifications = append(p.notifications, notification)
+	close(p.updated)
+	p.updated = make(chan struct{})
+}
+
+func (p *restPlayer) finish() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.finished = true
+	close(p.updated)
+	p.updated = make(chan struct{})
+}
+
+func (p *restPlayer) isFinished() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.finished
+}
+
+// getNotifications returns the notifications after the given number of notifications
+// @return the new notifications, a channel that is closed on the next update, and true if no more
+// notifications will be received
+func (p *restPlayer) getNotifications(from int) ([]matchmaking.MatchMakingNotification, <-chan struct{}, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if from > len(p.notifications) {
+		from = len(p.notifications)
+	}
+	return append([]matchmaking.MatchMakingNotification{}, p.notifications[from:]...), p.updated, p.finished
+}
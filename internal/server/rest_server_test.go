@@ -0,0 +1,314 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/clock"
+	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestRestServer serves the handler of a REST server on a test HTTP server
+// @return the REST server and the URL of the test HTTP server
+func startTestRestServer(t *testing.T, queues matchmaking.QueueRegistry) (*restServer, string) {
+	server := NewRestServer(0, queues, newTestValidator(t)).(*restServer)
+	httpServer := httptest.NewServer(server.httpServer.Handler)
+	t.Cleanup(httpServer.Close)
+	return server, httpServer.URL
+}
+
+// sendTestRequest sends a request and returns the status and the body of the response
+func sendTestRequest(t *testing.T, method string, url string, body string) (int, string) {
+	t.Helper()
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if !assert.NoError(t, err) {
+		return 0, ""
+	}
+	response, err := http.DefaultClient.Do(request)
+	if !assert.NoError(t, err) {
+		return 0, ""
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	return response.StatusCode, string(data)
+}
+
+// joinTestPlayers joins players over HTTP
+// @return the player tokens by player id
+func joinTestPlayers(t *testing.T, url string, body string) map[string]string {
+	t.Helper()
+	status, responseBody := sendTestRequest(t, http.MethodPost, url+"/v1/queue", body)
+	assert.Equal(t, http.StatusAccepted, status)
+	joined := restJoinedResponse{}
+	assert.NoError(t, json.Unmarshal([]byte(responseBody), &joined))
+	return joined.Tokens
+}
+
+func decodeErrorCode(t *testing.T, body string) errorCode {
+	t.Helper()
+	response := errorResponse{}
+	assert.NoError(t, json.Unmarshal([]byte(body), &response))
+	return response.Code
+}
+
+// testEvent is a Server-Sent Event received by a test client
+type testEvent struct {
+	id           string
+	event        string
+	notification matchmaking.MatchMakingNotification
+}
+
+// testEventStream is an open event stream of a player
+type testEventStream struct {
+	t      *testing.T
+	reader *bufio.Reader
+}
+
+// openTestEventStream opens the event stream of a player
+// @param token the player token sent in the Authorization header
+// @param lastEventID the Last-Event-ID header sent to resume the stream. Not sent if empty
+// @return the event stream, or nil if the server did not answer with 200
+func openTestEventStream(t *testing.T, url string, playerID string, token string, lastEventID string) *testEventStream {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), testReadTimeout)
+	t.Cleanup(cancel)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/v1/queue/"+playerID+"/events", nil)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	t.Cleanup(func() {
+		response.Body.Close()
+	})
+	if !assert.Equal(t, http.StatusOK, response.StatusCode) {
+		return nil
+	}
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	return &testEventStream{t: t, reader: bufio.NewReader(response.Body)}
+}
+
+// receive returns the next event of the stream, skipping comments
+// @return io.EOF if the stream ended
+func (s *testEventStream) receive() (testEvent, error) {
+	event := testEvent{}
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return testEvent{}, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event.id != "":
+			return event, nil
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			assert.NoError(s.t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.notification))
+		}
+	}
+}
+
+func (s *testEventStream) expectEvent(id string, state matchmaking.MatchmakingState) {
+	s.t.Helper()
+	event, err := s.receive()
+	if assert.NoError(s.t, err) {
+		assert.Equal(s.t, id, event.id)
+		assert.Equal(s.t, "notification", event.event)
+		assert.Equal(s.t, state, event.notification.State)
+	}
+}
+
+func (s *testEventStream) expectEnd() {
+	s.t.Helper()
+	_, err := s.receive()
+	assert.ErrorIs(s.t, err, io.EOF)
+}
+
+func TestRestServer_JoinAndLeave(t *testing.T) {
+	_, url := startTestRestServer(t, newTestQueues(t))
+
+	status, body := sendTestRequest(t, http.MethodPost, url+"/v1/queue", `{"ID":"player_1","Level":5}`)
+	assert.Equal(t, http.StatusAccepted, status)
+	joined := restJoinedResponse{}
+	assert.NoError(t, json.Unmarshal([]byte(body), &joined))
+	assert.Equal(t, joinedResponse{PlayerIDs: []string{"player_1"}, Queue: matchmaking.DefaultQueueName}, joined.joinedResponse)
+	token := joined.Tokens["player_1"]
+	assert.NotEmpty(t, token)
+
+	events := openTestEventStream(t, url, "player_1", token, "")
+	if events == nil {
+		return
+	}
+	events.expectEvent("1", matchmaking.State_WaitingForPlayers)
+
+	status, body = sendTestRequest(t, http.MethodDelete, url+"/v1/queue/player_1?token="+token, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"PlayerIDs":["player_1"]}`, body)
+	events.expectEvent("2", matchmaking.State_Cancelled)
+	events.expectEnd()
+
+	// the player has already left
+	status, body = sendTestRequest(t, http.MethodDelete, url+"/v1/queue/player_1?token="+token, "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, errorCode_NotInMatchmaking, decodeErrorCode(t, body))
+}
+
+func TestRestServer_EventStreamResumesAfterLastEventID(t *testing.T) {
+	_, url := startTestRestServer(t, newTestQueues(t))
+
+	token := joinTestPlayers(t, url, `{"ID":"player_1","Level":5}`)["player_1"]
+	joinTestPlayers(t, url, `{"ID":"player_2","Level":5}`)
+
+	// the notifications received before the stream was opened are replayed
+	events := openTestEventStream(t, url, "player_1", token, "")
+	if events == nil {
+		return
+	}
+	events.expectEvent("1", matchmaking.State_WaitingForPlayers)
+	events.expectEvent("2", matchmaking.State_Started)
+	events.expectEnd()
+
+	resumed := openTestEventStream(t, url, "player_1", token, "1")
+	if resumed == nil {
+		return
+	}
+	resumed.expectEvent("2", matchmaking.State_Started)
+	resumed.expectEnd()
+}
+
+func TestRestServer_FinishedPlayerIsKeptForRetentionTime(t *testing.T) {
+	server, url := startTestRestServer(t, newTestQueues(t))
+	fakeClock := clock.NewFakeClock(time.Now())
+	server.clock = fakeClock
+
+	token := joinTestPlayers(t, url, `{"ID":"player_1","Level":5}`)["player_1"]
+	sendTestRequest(t, http.MethodDelete, url+"/v1/queue/player_1?token="+token, "")
+
+	// a client connecting late still receives the outcome
+	events := openTestEventStream(t, url, "player_1", token, "")
+	if events == nil {
+		return
+	}
+	events.expectEvent("1", matchmaking.State_WaitingForPlayers)
+	events.expectEvent("2", matchmaking.State_Cancelled)
+	events.expectEnd()
+
+	assert.Eventually(t, func() bool {
+		fakeClock.Advance(finishedPlayerRetention)
+		status, _ := sendTestRequest(t, http.MethodGet, url+"/v1/queue/player_1/events?token="+token, "")
+		return status == http.StatusNotFound
+	}, testReadTimeout, 10*time.Millisecond)
+}
+
+func TestRestServer_ErrorStatuses(t *testing.T) {
+	queues := newTestQueues(t)
+	_, url := startTestRestServer(t, queues)
+	token := joinTestPlayers(t, url, `{"ID":"player_1","Level":5}`)["player_1"]
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   errorCode
+	}{
+		{"invalid JSON", http.MethodPost, "/v1/queue", `not json`, http.StatusBadRequest, errorCode_InvalidJSON},
+		{"unknown field", http.MethodPost, "/v1/queue", `{"ID":"player_2","Level":5,"Colour":"red"}`, http.StatusBadRequest, errorCode_UnknownField},
+		{"invalid player", http.MethodPost, "/v1/queue", `{"ID":"player 2","Level":5}`, http.StatusBadRequest, errorCode_InvalidPlayerID},
+		{"unknown queue", http.MethodPost, "/v1/queue", `{"ID":"player_2","Level":5,"Queue":"unknown"}`, http.StatusNotFound, errorCode_UnknownQueue},
+		{"already in matchmaking", http.MethodPost, "/v1/queue", `{"ID":"player_1","Level":5}`, http.StatusConflict, errorCode_AlreadyInMatchmaking},
+		{"already in another queue", http.MethodPost, "/v1/queue", `{"ID":"player_1","Level":5,"Queue":"ready"}`, http.StatusConflict, errorCode_AlreadyInMatchmaking},
+		{"leave of unknown player", http.MethodDelete, "/v1/queue/player_2", ``, http.StatusNotFound, errorCode_NotInMatchmaking},
+		{"events of unknown player", http.MethodGet, "/v1/queue/player_2/events", ``, http.StatusNotFound, errorCode_NotInMatchmaking},
+		{"ready of unknown player", http.MethodPost, "/v1/queue/player_2/ready", ``, http.StatusNotFound, errorCode_NotInMatchmaking},
+		{"leave without token", http.MethodDelete, "/v1/queue/player_1", ``, http.StatusUnauthorized, errorCode_Unauthenticated},
+		{"events without token", http.MethodGet, "/v1/queue/player_1/events", ``, http.StatusUnauthorized, errorCode_Unauthenticated},
+		{"ready without token", http.MethodPost, "/v1/queue/player_1/ready", ``, http.StatusUnauthorized, errorCode_Unauthenticated},
+		{"events with token of another player", http.MethodGet, "/v1/queue/player_1/events?token=" + token + "0", ``, http.StatusUnauthorized, errorCode_Unauthenticated},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, body := sendTestRequest(t, test.method, url+test.path, test.body)
+			assert.Equal(t, test.status, status)
+			assert.Equal(t, test.code, decodeErrorCode(t, body))
+		})
+	}
+}
+
+func TestRestServer_ConfirmReady(t *testing.T) {
+	_, url := startTestRestServer(t, newTestQueues(t))
+
+	tokens := joinTestPlayers(t, url, `{"Members":[{"ID":"player_1","Level":5},{"ID":"player_2","Level":5}],"Queue":"ready"}`)
+	events := openTestEventStream(t, url, "player_1", tokens["player_1"], "")
+	if events == nil {
+		return
+	}
+	events.expectEvent("1", matchmaking.State_WaitingForPlayers)
+	events.expectEvent("2", matchmaking.State_ReadyCheck)
+
+	// a player cannot confirm for another player
+	status, body := sendTestRequest(t, http.MethodPost, url+"/v1/queue/player_2/ready?token="+tokens["player_1"], "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, errorCode_Unauthenticated, decodeErrorCode(t, body))
+
+	for _, playerID := range []string{"player_1", "player_2"} {
+		status, _ := sendTestRequest(t, http.MethodPost, url+"/v1/queue/"+playerID+"/ready?token="+tokens[playerID], "")
+		assert.Equal(t, http.StatusNoContent, status)
+	}
+	events.expectEvent("3", matchmaking.State_Started)
+	events.expectEnd()
+}
+
+func TestRestServer_LeaveOfPlayerOfAnotherServerIsRejected(t *testing.T) {
+	queues := newTestQueues(t)
+	_, url := startTestRestServer(t, queues)
+	queue, _ := queues.GetQueue(matchmaking.DefaultQueueName)
+	_, err := queue.HandlePlayerJoin(model.PlayerData{ID: "player_1", Level: 5})
+	assert.NoError(t, err)
+
+	status, body := sendTestRequest(t, http.MethodDelete, url+"/v1/queue/player_1", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, errorCode_NotInMatchmaking, decodeErrorCode(t, body))
+
+	_, err = queue.GetPlayerStatus("player_1")
+	assert.NoError(t, err, "player of another server should stay in matchmaking")
+}
+
+func TestRestServer_JoinDuringShutdownIsRejected(t *testing.T) {
+	server, url := startTestRestServer(t, newTestQueues(t))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, server.Shutdown(ctx))
+
+	status, body := sendTestRequest(t, http.MethodPost, url+"/v1/queue", `{"ID":"player_1","Level":5}`)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, errorCode_ShuttingDown, decodeErrorCode(t, body))
+}
+
+func TestRestServer_ServesOpenAPIDescription(t *testing.T) {
+	_, url := startTestRestServer(t, newTestQueues(t))
+
+	status, body := sendTestRequest(t, http.MethodGet, url+OpenAPIPath, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, strings.HasPrefix(body, "openapi: "))
+}
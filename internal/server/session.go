@@ -0,0 +1,477 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
+	"github.com/SntrKslnn/matchmaking-service/internal/validation"
+)
+
+// joinedPlayers are the players that joined matchmaking over a connection
+type joinedPlayers struct {
+	playerIDs []string
+	queueName string
+	queue     matchmaking.MatchmakingService
+
+	// notificationChans are the notification channels of the players by player id
+	notificationChans map[string]<-chan matchmaking.MatchMakingNotification
+}
+
+// session is the protocol state of a client connection. It does not depend on the transport, which only
+// delivers the requests of the client and writes the messages of the session
+// A client sends versioned commands in an envelope, or the unversioned requests of the first protocol version
+// The protocol of the last join decides whether notifications are sent in an envelope
+type session struct {
+	queues    matchmaking.QueueRegistry
+	validator validation.JoinValidator
+	// gameServerAuth authenticates the reports of competition results
+	gameServerAuth gameServerAuth
+
+	// write sends a message to the client
+	write func(message any)
+	// startGoroutine runs a helper goroutine of the session that the shutdown of the server waits for
+	startGoroutine func(run func())
+
+	// players are the players in matchmaking over this session. Nil if the session is not in matchmaking
+	players *joinedPlayers
+	// notifications are the notifications forwarded to the client. Nil if the session is not in matchmaking
+	notifications <-chan matchmaking.MatchMakingNotification
+	// joinRequestID is the request id of the versioned join command the players joined with
+	joinRequestID string
+	// versioned is true if the players joined with a versioned command
+	versioned bool
+	// sessionReplaced is true if another connection has taken over the players
+	sessionReplaced bool
+}
+
+func newSession(queues matchmaking.QueueRegistry, validator validation.JoinValidator, gameServerAuth gameServerAuth, write func(message any), startGoroutine func(run func())) *session {
+	return &session{
+		queues:         queues,
+		validator:      validator,
+		gameServerAuth: gameServerAuth,
+		write:          write,
+		startGoroutine: startGoroutine,
+	}
+}
+
+// run handles the requests of the client and forwards the notifications of its players
+// If the client disconnects while in matchmaking, the session stops listening to the players, which removes them
+// from matchmaking unless another connection listens to them. During a shutdown the notifications are forwarded
+// until the matchmaking service closes the notification channel
+// @param requests the requests received from the client
+// @param disconnected is closed when the client disconnects
+// @param shuttingDown is closed when the server shuts down
+// @return when the session is over and the connection must be closed
+func (s *session) run(requests <-chan string, disconnected <-chan struct{}, shuttingDown <-chan struct{}) {
+	clientGone, serverGone := false, false
+	for {
+		select {
+		case notification, ok := <-s.notifications:
+			if s.handleNotification(notification, ok, clientGone, serverGone) {
+				return
+			}
+		case data := <-requests:
+			// the notifications sent before the request are handled first, so that a client joining again
+			// right after its last notification finds the session out of matchmaking
+			if s.handlePendingNotifications(serverGone) {
+				return
+			}
+			if !s.handleRequest(data) {
+				return
+			}
+		case <-disconnected:
+			if s.players == nil {
+				return
+			}
+			slog.Info("Connection dropped while in matchmaking", "player_ids", s.players.playerIDs)
+			s.detachListeners()
+			// keep draining until the matchmaking service closes the notification channel
+			clientGone = true
+			disconnected = nil
+			requests = nil
+		case <-shuttingDown:
+			if s.players == nil {
+				return
+			}
+			serverGone = true
+			shuttingDown = nil
+		}
+	}
+}
+
+// handleNotification forwards a notification to the client, or leaves the matchmaking stage if the notification
+// channel is closed
+// @param ok false if the notification channel is closed
+// @return true if the session is over and the connection must be closed
+func (s *session) handleNotification(notification matchmaking.MatchMakingNotification, ok bool, clientGone bool, serverGone bool) bool {
+	if !ok {
+		s.leaveMatchmakingStage()
+		if s.sessionReplaced {
+			slog.Info("Session taken over by another connection. Closing connection")
+			return true
+		}
+		return clientGone || serverGone
+	}
+	s.sessionReplaced = notification.State == matchmaking.State_SessionReplaced
+	if !clientGone {
+		s.writeNotification(notification)
+	}
+	return false
+}
+
+// handlePendingNotifications handles the notifications that have been received but not handled yet
+// @return true if the session is over and the connection must be closed
+func (s *session) handlePendingNotifications(serverGone bool) bool {
+	for {
+		select {
+		case notification, ok := <-s.notifications:
+			if s.handleNotification(notification, ok, false, serverGone) {
+				return true
+			}
+			if !ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+}
+
+// handleRequest handles a request of the client
+// @return false if the connection must be closed
+func (s *session) handleRequest(data string) bool {
+	envelope := requestEnvelope{}
+	err := json.Unmarshal([]byte(data), &envelope)
+	if err == nil && envelope.V != nil {
+		s.handleVersionedRequest(envelope)
+		return true
+	}
+	if s.versioned {
+		if err == nil {
+			err = newProtocolError(errorCode_UnsupportedVersion, errors.New("request has no protocol version"))
+		} else {
+			err = newProtocolError(errorCode_InvalidJSON, fmt.Errorf("invalid JSON received: %w", err))
+		}
+		s.writeError("", err)
+		return true
+	}
+	return s.handleUnversionedRequest(data)
+}
+
+// handleVersionedRequest handles a command in an envelope and answers it with a response carrying its request id
+// The connection stays open after a failed command
+func (s *session) handleVersionedRequest(envelope requestEnvelope) {
+	if *envelope.V != protocolVersion {
+		s.writeError(envelope.RequestID, newProtocolError(errorCode_UnsupportedVersion, fmt.Errorf("protocol version %d is not supported", *envelope.V)))
+		return
+	}
+
+	var responseType responseType
+	var response any
+	var err error
+	switch envelope.Type {
+	case requestType_Join:
+		responseType, response, err = s.handleJoinCommand(envelope)
+	case requestType_Leave:
+		responseType, response, err = s.handleLeaveCommand()
+	case requestType_Status:
+		responseType, response = responseType_Status, s.getStatus()
+	case requestType_Ping:
+		responseType = responseType_Pong
+	case requestType_Ready:
+		responseType, err = responseType_ReadyConfirmed, s.confirmReady()
+	case requestType_ReportResult:
+		responseType, response, err = s.handleReportResultCommand(envelope)
+	case requestType_ReportDropped:
+		responseType, err = responseType_DropReported, s.handleReportDroppedCommand(envelope)
+	default:
+		err = newProtocolError(errorCode_UnknownType, fmt.Errorf("unknown request type %q", envelope.Type))
+	}
+
+	if err != nil {
+		slog.Warn("Request failed", "type", envelope.Type, "request_id", envelope.RequestID, "error", err)
+		s.writeError(envelope.RequestID, err)
+		return
+	}
+	s.write(responseEnvelope{V: protocolVersion, Type: responseType, RequestID: envelope.RequestID, Payload: response})
+}
+
+// handleJoinCommand joins the player or the party of a versioned join command
+// The joined response is written before the first notification of the players is forwarded
+func (s *session) handleJoinCommand(envelope requestEnvelope) (responseType, any, error) {
+	if s.players != nil {
+		return "", nil, newProtocolError(errorCode_AlreadyJoined, errors.New("connection is already in matchmaking"))
+	}
+	joinRequest, err := decodeJoinRequest(envelope.Payload, errorCode_InvalidPayload)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.join(joinRequest); err != nil {
+		return "", nil, err
+	}
+	s.versioned = true
+	s.joinRequestID = envelope.RequestID
+	return responseType_Joined, joinedResponse{PlayerIDs: s.players.playerIDs, Queue: s.players.queueName}, nil
+}
+
+// handleLeaveCommand removes the players of the session from matchmaking
+// The cancelled notifications of the players follow the left response
+func (s *session) handleLeaveCommand() (responseType, any, error) {
+	if s.players == nil {
+		return "", nil, newProtocolError(errorCode_NotInMatchmaking, errors.New("connection is not in matchmaking"))
+	}
+	for _, playerID := range s.players.playerIDs {
+		s.players.queue.LeaveMatchmaking(playerID)
+	}
+	return responseType_Left, leftResponse{PlayerIDs: s.players.playerIDs}, nil
+}
+
+// getStatus returns the status of the players of the session that are still in matchmaking
+func (s *session) getStatus() statusResponse {
+	status := statusResponse{Players: []matchmaking.PlayerStatus{}}
+	if s.players == nil {
+		return status
+	}
+	for _, playerID := range s.players.playerIDs {
+		playerStatus, err := s.players.queue.GetPlayerStatus(playerID)
+		if err != nil {
+			continue
+		}
+		status.Players = append(status.Players, playerStatus)
+	}
+	return status
+}
+
+// confirmReady confirms the ready check for every player of the session
+func (s *session) confirmReady() error {
+	if s.players == nil {
+		return newProtocolError(errorCode_NotInMatchmaking, errors.New("connection is not in matchmaking"))
+	}
+	for _, playerID := range s.players.playerIDs {
+		s.players.queue.ConfirmReady(playerID)
+	}
+	return nil
+}
+
+func (s *session) handleReportResultCommand(envelope requestEnvelope) (responseType, any, error) {
+	report := resultReport{}
+	if err := json.Unmarshal(envelope.Payload, &report); err != nil {
+		return "", nil, newProtocolError(errorCode_InvalidPayload, fmt.Errorf("invalid result payload: %w", err))
+	}
+	if err := s.reportResult(report); err != nil {
+		return "", nil, err
+	}
+	return responseType_ResultReported, resultReportResponse{CompetitionID: report.CompetitionID, Accepted: true}, nil
+}
+
+// reportResult hands a competition result to the queue of the competition
+// @return an error if the report does not carry the game server token or the queue rejects the result
+func (s *session) reportResult(report resultReport) error {
+	if err := s.gameServerAuth.authenticate(report.Token); err != nil {
+		slog.Warn("Rejected competition result", "id", report.CompetitionID, "error", err)
+		return err
+	}
+	queue, err := s.queues.GetQueue(report.Queue)
+	if err != nil {
+		return err
+	}
+	if err := queue.ReportResult(report.CompetitionID, report.Players); err != nil {
+		slog.Warn("Rejected competition result", "id", report.CompetitionID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// handleReportDroppedCommand reports a player that dropped out of a started competition
+func (s *session) handleReportDroppedCommand(envelope requestEnvelope) error {
+	report := droppedPlayerReport{}
+	if err := json.Unmarshal(envelope.Payload, &report); err != nil {
+		return newProtocolError(errorCode_InvalidPayload, fmt.Errorf("invalid dropped player payload: %w", err))
+	}
+	return reportPlayerDropped(s.queues, s.gameServerAuth, report)
+}
+
+// reportPlayerDropped hands a dropped player to the queue of the competition
+// The report is ignored by the queue if the competition is not in its backfill window
+// @param auth authenticates the game server sending the report
+// @return an error if the report does not carry the game server token, has no player id or names an unknown queue
+func reportPlayerDropped(queues matchmaking.QueueRegistry, auth gameServerAuth, report droppedPlayerReport) error {
+	if err := auth.authenticate(report.Token); err != nil {
+		slog.Warn("Rejected dropped player report", "id", report.CompetitionID, "player_id", report.PlayerID, "error", err)
+		return err
+	}
+	if report.PlayerID == "" {
+		return newProtocolError(errorCode_InvalidPayload, errors.New("dropped player report has no player id"))
+	}
+	queue, err := queues.GetQueue(report.Queue)
+	if err != nil {
+		return err
+	}
+	queue.ReportPlayerDropped(report.CompetitionID, report.PlayerID)
+	return nil
+}
+
+// handleUnversionedRequest handles a request of the first protocol version, which has no envelope
+// A failed join closes the connection
+// @return false if the connection must be closed
+func (s *session) handleUnversionedRequest(data string) bool {
+	if s.players != nil {
+		s.handlePlayerCommand(data)
+		return true
+	}
+	if s.handleResultReport(data) {
+		return true
+	}
+
+	joinRequest, err := decodeJoinRequest([]byte(data), errorCode_InvalidJSON)
+	if err != nil {
+		slog.Warn("Rejected player join request", "error", err)
+		s.writeError("", err)
+		return false
+	}
+	if err := s.join(joinRequest); err != nil {
+		slog.Error("Error handling player join request", "error", err)
+		s.writeError("", err)
+		return false
+	}
+	s.versioned = false
+	s.joinRequestID = ""
+	return true
+}
+
+// handleResultReport reports a competition result if the request is an unversioned result report
+// @return true if the request was a result report, false if it must be handled as a join request
+func (s *session) handleResultReport(data string) bool {
+	report := resultReportRequest{}
+	if err := json.Unmarshal([]byte(data), &report); err != nil || report.Result == nil {
+		return false
+	}
+
+	response := resultReportResponse{CompetitionID: report.Result.CompetitionID, Accepted: true}
+	err := s.reportResult(resultReport{CompetitionID: report.Result.CompetitionID, Players: report.Result.Players, Queue: report.Queue, Token: report.Token})
+	if err != nil {
+		response.Accepted = false
+		response.Error = err.Error()
+	}
+	s.write(response)
+	return true
+}
+
+// handlePlayerCommand handles an unversioned request received while the players of the session are in matchmaking
+// A ready confirmation confirms the ready check for every player of the session
+func (s *session) handlePlayerCommand(data string) {
+	command := playerCommand{}
+	if err := json.Unmarshal([]byte(data), &command); err != nil || !command.Ready {
+		slog.Warn("Ignoring request received while in matchmaking", "player_ids", s.players.playerIDs)
+		return
+	}
+	s.confirmReady()
+}
+
+func (s *session) join(joinRequest playerJoinRequest) error {
+	players, notifications, err := joinPlayers(s.queues, s.validator, joinRequest, s.startGoroutine)
+	if err != nil {
+		return err
+	}
+	s.enterMatchmakingStage(players, notifications)
+	return nil
+}
+
+// joinPlayers validates the player or the party of the request and joins it to the requested queue
+// All members of a party receive the same updates, so only the notifications of the first member are forwarded
+// and the notifications of the other members are drained
+// @param startGoroutine runs the goroutines draining the notifications of the other party members
+// @return the joined players and the notification channel to forward
+func joinPlayers(queues matchmaking.QueueRegistry, validator validation.JoinValidator, joinRequest playerJoinRequest, startGoroutine func(run func())) (*joinedPlayers, <-chan matchmaking.MatchMakingNotification, error) {
+	if err := validateJoinRequest(validator, joinRequest); err != nil {
+		return nil, nil, err
+	}
+	queue, err := queues.GetQueue(joinRequest.Queue)
+	if err != nil {
+		return nil, nil, err
+	}
+	queueName := joinRequest.Queue
+	if queueName == "" {
+		queueName = matchmaking.DefaultQueueName
+	}
+
+	if len(joinRequest.Members) == 0 {
+		notifications, err := queue.HandleIdempotentPlayerJoin(joinRequest.PlayerData, joinRequest.IdempotencyKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("player cannot join matchmaking: %w", err)
+		}
+		return &joinedPlayers{
+			playerIDs:         []string{joinRequest.ID},
+			queueName:         queueName,
+			queue:             queue,
+			notificationChans: map[string]<-chan matchmaking.MatchMakingNotification{joinRequest.ID: notifications},
+		}, notifications, nil
+	}
+
+	partyNotifications, err := queue.HandlePartyJoin(joinRequest.Members)
+	if err != nil {
+		return nil, nil, fmt.Errorf("party cannot join matchmaking: %w", err)
+	}
+
+	playerIDs := make([]string, len(joinRequest.Members))
+	for i, member := range joinRequest.Members {
+		playerIDs[i] = member.ID
+		if i > 0 {
+			startGoroutine(func() {
+				drainNotifications(partyNotifications[member.ID])
+			})
+		}
+	}
+	return &joinedPlayers{
+		playerIDs:         playerIDs,
+		queueName:         queueName,
+		queue:             queue,
+		notificationChans: partyNotifications,
+	}, partyNotifications[playerIDs[0]], nil
+}
+
+func validateJoinRequest(validator validation.JoinValidator, joinRequest playerJoinRequest) error {
+	if len(joinRequest.Members) == 0 {
+		return validator.ValidatePlayer(joinRequest.PlayerData)
+	}
+	return validator.ValidateParty(joinRequest.Members)
+}
+
+func (s *session) enterMatchmakingStage(players *joinedPlayers, notifications <-chan matchmaking.MatchMakingNotification) {
+	s.players = players
+	s.notifications = notifications
+	s.sessionReplaced = false
+}
+
+// leaveMatchmakingStage forgets the players once their notification channel is closed
+// The session can join matchmaking again after this
+func (s *session) leaveMatchmakingStage() {
+	s.players = nil
+	s.notifications = nil
+}
+
+func (s *session) detachListeners() {
+	for _, playerID := range s.players.playerIDs {
+		s.players.queue.DetachListener(playerID, s.players.notificationChans[playerID])
+	}
+}
+
+func (s *session) writeNotification(notification matchmaking.MatchMakingNotification) {
+	if !s.versioned {
+		s.write(notification)
+		return
+	}
+	s.write(responseEnvelope{V: protocolVersion, Type: responseType_Notification, RequestID: s.joinRequestID, Payload: notification})
+}
+
+func (s *session) writeError(requestID string, err error) {
+	s.write(responseEnvelope{V: protocolVersion, Type: responseType_Error, RequestID: requestID, Payload: toErrorResponse(err)})
+}
+
+func drainNotifications(notifications <-chan matchmaking.MatchMakingNotification) {
+	for range notifications {
+	}
+}
@@ -0,0 +1,419 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/competition"
+	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
+	"github.com/SntrKslnn/matchmaking-service/internal/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+// testReadTimeout is how long a test client waits for a message of the server
+const testReadTimeout = 2 * time.Second
+
+// testGameServerToken is the game server token of the test servers
+const testGameServerToken = "game_server_secret"
+
+// newTestQueues creates a default queue matching two players, a queue with a ready check and a queue
+// with a backfill window. The queues are shut down when the test ends
+func newTestQueues(t *testing.T) matchmaking.QueueRegistry {
+	queueConfig := matchmaking.MatchmakingConfig{
+		CompetitionConfig: competition.CompetitionConfig{
+			MaxPlayerCount: 2,
+			MinPlayerCount: 2,
+		},
+		MatchmakingTimeout:     time.Minute,
+		LevelMatchingTolerance: 3,
+	}
+	readyCheckQueueConfig := queueConfig
+	readyCheckQueueConfig.ReadyCheck = matchmaking.ReadyCheckConfig{Window: time.Minute}
+	backfillQueueConfig := queueConfig
+	backfillQueueConfig.Backfill = matchmaking.BackfillConfig{Window: time.Minute}
+
+	queues := matchmaking.NewQueueRegistry(map[string]matchmaking.MatchmakingConfig{
+		matchmaking.DefaultQueueName: queueConfig,
+		"ready":                      readyCheckQueueConfig,
+		"backfill":                   backfillQueueConfig,
+	})
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		queues.Shutdown(ctx)
+	})
+	return queues
+}
+
+func newTestValidator(t *testing.T) validation.JoinValidator {
+	validator, err := validation.NewJoinValidator(validation.ValidationConfig{
+		MinLevel:    validation.DefaultMinLevel,
+		MaxLevel:    validation.DefaultMaxLevel,
+		MaxIDLength: validation.DefaultMaxIDLength,
+		IDPattern:   validation.DefaultIDPattern,
+	})
+	assert.NoError(t, err)
+	return validator
+}
+
+// testEnvelope is a versioned message received by a test client, with the payload left undecoded
+type testEnvelope struct {
+	V         int             `json:"v"`
+	Type      responseType    `json:"type"`
+	RequestID string          `json:"request_id"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// testClient is the client end of a connection served by a TCP server session
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// connectTestClient serves one end of a pipe like an accepted TCP connection and returns a client for the other end
+func connectTestClient(t *testing.T, queues matchmaking.QueueRegistry) *testClient {
+	server := NewTCPServer(0, queues, newTestValidator(t), testGameServerToken).(*tcpServer)
+	serverConn, clientConn := net.Pipe()
+	assert.True(t, server.connections.track(serverConn))
+	go server.handleConnection(serverConn)
+	t.Cleanup(func() {
+		clientConn.Close()
+	})
+	return &testClient{t: t, conn: clientConn, reader: bufio.NewReader(clientConn)}
+}
+
+func (c *testClient) send(request string) {
+	c.t.Helper()
+	c.conn.SetWriteDeadline(time.Now().Add(testReadTimeout))
+	_, err := c.conn.Write([]byte(request + "\n"))
+	assert.NoError(c.t, err)
+}
+
+// receiveLine returns the next message of the server without decoding it
+func (c *testClient) receiveLine() string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(testReadTimeout))
+	line, err := c.reader.ReadString('\n')
+	assert.NoError(c.t, err)
+	return strings.TrimSpace(line)
+}
+
+func (c *testClient) receive() testEnvelope {
+	c.t.Helper()
+	envelope := testEnvelope{}
+	assert.NoError(c.t, json.Unmarshal([]byte(c.receiveLine()), &envelope))
+	return envelope
+}
+
+// receiveNotification returns the next unversioned notification
+func (c *testClient) receiveNotification() matchmaking.MatchMakingNotification {
+	c.t.Helper()
+	notification := matchmaking.MatchMakingNotification{}
+	assert.NoError(c.t, json.Unmarshal([]byte(c.receiveLine()), &notification))
+	return notification
+}
+
+// receiveError returns the error code of the next message, which must be an error
+func (c *testClient) receiveError(requestID string) errorCode {
+	c.t.Helper()
+	envelope := c.receive()
+	assert.Equal(c.t, responseType_Error, envelope.Type)
+	assert.Equal(c.t, requestID, envelope.RequestID)
+	return decodePayload[errorResponse](c.t, envelope).Code
+}
+
+func (c *testClient) expectClosed() {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(testReadTimeout))
+	_, err := c.reader.ReadString('\n')
+	assert.ErrorIs(c.t, err, io.EOF)
+}
+
+func decodePayload[T any](t *testing.T, envelope testEnvelope) T {
+	t.Helper()
+	var payload T
+	assert.NoError(t, json.Unmarshal(envelope.Payload, &payload))
+	return payload
+}
+
+func TestSession_VersionedCommandsCarryRequestIDs(t *testing.T) {
+	client := connectTestClient(t, newTestQueues(t))
+
+	client.send(`{"v":1,"type":"join","request_id":"join-1","payload":{"ID":"player_1","Level":5}}`)
+	joined := client.receive()
+	assert.Equal(t, testEnvelope{V: 1, Type: responseType_Joined, RequestID: "join-1", Payload: joined.Payload}, joined)
+	assert.Equal(t, joinedResponse{PlayerIDs: []string{"player_1"}, Queue: matchmaking.DefaultQueueName}, decodePayload[joinedResponse](t, joined))
+
+	// notifications carry the request id of the join
+	notification := client.receive()
+	assert.Equal(t, responseType_Notification, notification.Type)
+	assert.Equal(t, "join-1", notification.RequestID)
+	assert.Equal(t, matchmaking.MatchMakingNotification{CompetitionID: 1, State: matchmaking.State_WaitingForPlayers}, decodePayload[matchmaking.MatchMakingNotification](t, notification))
+
+	client.send(`{"v":1,"type":"ping","request_id":"ping-1"}`)
+	assert.Equal(t, testEnvelope{V: 1, Type: responseType_Pong, RequestID: "ping-1"}, client.receive())
+
+	client.send(`{"v":1,"type":"status","request_id":"status-1"}`)
+	status := client.receive()
+	assert.Equal(t, responseType_Status, status.Type)
+	assert.Equal(t, "status-1", status.RequestID)
+	players := decodePayload[statusResponse](t, status).Players
+	if assert.Len(t, players, 1) {
+		assert.Equal(t, "player_1", players[0].PlayerID)
+		assert.Equal(t, matchmaking.State_WaitingForPlayers, players[0].State)
+	}
+
+	client.send(`{"v":1,"type":"leave","request_id":"leave-1"}`)
+	left := client.receive()
+	assert.Equal(t, responseType_Left, left.Type)
+	assert.Equal(t, "leave-1", left.RequestID)
+	assert.Equal(t, leftResponse{PlayerIDs: []string{"player_1"}}, decodePayload[leftResponse](t, left))
+	cancelled := client.receive()
+	assert.Equal(t, "join-1", cancelled.RequestID)
+	assert.Equal(t, matchmaking.State_Cancelled, decodePayload[matchmaking.MatchMakingNotification](t, cancelled).State)
+
+	// the connection stays open and can join again
+	client.send(`{"v":1,"type":"status","request_id":"status-2"}`)
+	assert.Empty(t, decodePayload[statusResponse](t, client.receive()).Players)
+	client.send(`{"v":1,"type":"join","request_id":"join-2","payload":{"ID":"player_1","Level":5}}`)
+	assert.Equal(t, responseType_Joined, client.receive().Type)
+	assert.Equal(t, "join-2", client.receive().RequestID)
+}
+
+func TestSession_UnversionedRequests(t *testing.T) {
+	queues := newTestQueues(t)
+	first := connectTestClient(t, queues)
+	second := connectTestClient(t, queues)
+
+	first.send(`{"ID":"player_1","Level":5}`)
+	assert.Equal(t, matchmaking.MatchMakingNotification{CompetitionID: 1, State: matchmaking.State_WaitingForPlayers}, first.receiveNotification())
+
+	// requests other than a ready confirmation are ignored while in matchmaking
+	first.send(`{"ID":"player_3","Level":5}`)
+
+	second.send(`{"ID":"player_2","Level":5}`)
+	assert.Equal(t, matchmaking.MatchMakingNotification{CompetitionID: 1, State: matchmaking.State_WaitingForPlayers}, second.receiveNotification())
+	assert.Equal(t, matchmaking.State_Started, first.receiveNotification().State)
+	assert.Equal(t, matchmaking.State_Started, second.receiveNotification().State)
+
+	// a result is reported without an envelope and answered without one
+	reporter := connectTestClient(t, queues)
+	reporter.send(`{"Token":"` + testGameServerToken + `","Result":{"CompetitionID":1,"Players":[{"PlayerID":"player_1","Placement":1},{"PlayerID":"player_2","Placement":2}]}}`)
+	assert.Equal(t, `{"CompetitionID":1,"Accepted":true}`, reporter.receiveLine())
+	reporter.send(`{"Token":"` + testGameServerToken + `","Result":{"CompetitionID":1,"Players":[{"PlayerID":"player_1","Placement":1},{"PlayerID":"player_2","Placement":2}]}}`)
+	response := resultReportResponse{}
+	assert.NoError(t, json.Unmarshal([]byte(reporter.receiveLine()), &response))
+	assert.False(t, response.Accepted)
+	assert.NotEmpty(t, response.Error)
+}
+
+func TestSession_UnversionedJoinFailureClosesConnection(t *testing.T) {
+	client := connectTestClient(t, newTestQueues(t))
+
+	client.send(`{"ID":"player 1","Level":5}`)
+	assert.Equal(t, errorCode_InvalidPlayerID, client.receiveError(""))
+	client.expectClosed()
+}
+
+func TestSession_VersionedJoinSwitchesNotificationsToEnvelopes(t *testing.T) {
+	client := connectTestClient(t, newTestQueues(t))
+
+	client.send(`{"ID":"player_1","Level":5}`)
+	assert.Equal(t, matchmaking.State_WaitingForPlayers, client.receiveNotification().State)
+	client.send(`{"v":1,"type":"leave","request_id":"leave-1"}`)
+	assert.Equal(t, responseType_Left, client.receive().Type)
+	assert.Equal(t, matchmaking.State_Cancelled, client.receiveNotification().State)
+
+	// after a versioned join, a request without an envelope is rejected instead of handled as a join
+	client.send(`{"v":1,"type":"join","request_id":"join-1","payload":{"ID":"player_1","Level":5}}`)
+	assert.Equal(t, responseType_Joined, client.receive().Type)
+	assert.Equal(t, responseType_Notification, client.receive().Type)
+	client.send(`{"Ready":true}`)
+	assert.Equal(t, errorCode_UnsupportedVersion, client.receiveError(""))
+	client.send(`not json`)
+	assert.Equal(t, errorCode_InvalidJSON, client.receiveError(""))
+}
+
+func TestSession_ReadyCommand(t *testing.T) {
+	queues := newTestQueues(t)
+	first := connectTestClient(t, queues)
+	second := connectTestClient(t, queues)
+
+	first.send(`{"v":1,"type":"join","request_id":"1","payload":{"ID":"player_1","Level":5,"Queue":"ready"}}`)
+	assert.Equal(t, responseType_Joined, first.receive().Type)
+	assert.Equal(t, matchmaking.State_WaitingForPlayers, decodePayload[matchmaking.MatchMakingNotification](t, first.receive()).State)
+	second.send(`{"ID":"player_2","Level":5,"Queue":"ready"}`)
+	assert.Equal(t, matchmaking.State_WaitingForPlayers, second.receiveNotification().State)
+
+	assert.Equal(t, matchmaking.State_ReadyCheck, decodePayload[matchmaking.MatchMakingNotification](t, first.receive()).State)
+	assert.Equal(t, matchmaking.State_ReadyCheck, second.receiveNotification().State)
+
+	first.send(`{"v":1,"type":"ready","request_id":"2"}`)
+	assert.Equal(t, testEnvelope{V: 1, Type: responseType_ReadyConfirmed, RequestID: "2"}, first.receive())
+	second.send(`{"Ready":true}`)
+
+	assert.Equal(t, matchmaking.State_Started, decodePayload[matchmaking.MatchMakingNotification](t, first.receive()).State)
+	assert.Equal(t, matchmaking.State_Started, second.receiveNotification().State)
+}
+
+func TestSession_ReportResultCommand(t *testing.T) {
+	queues := newTestQueues(t)
+	first := connectTestClient(t, queues)
+	second := connectTestClient(t, queues)
+	first.send(`{"ID":"player_1","Level":5}`)
+	first.receiveNotification()
+	second.send(`{"ID":"player_2","Level":5}`)
+	second.receiveNotification()
+	assert.Equal(t, matchmaking.State_Started, first.receiveNotification().State)
+
+	reporter := connectTestClient(t, queues)
+	result := `{"Token":"` + testGameServerToken + `","CompetitionID":1,"Players":[{"PlayerID":"player_1","Placement":1},{"PlayerID":"player_2","Placement":2}]}`
+	reporter.send(`{"v":1,"type":"report_result","request_id":"1","payload":` + result + `}`)
+	reported := reporter.receive()
+	assert.Equal(t, responseType_ResultReported, reported.Type)
+	assert.Equal(t, "1", reported.RequestID)
+	assert.Equal(t, resultReportResponse{CompetitionID: 1, Accepted: true}, decodePayload[resultReportResponse](t, reported))
+
+	// the competition is finished after its result is reported
+	reporter.send(`{"v":1,"type":"report_result","request_id":"2","payload":` + result + `}`)
+	assert.Equal(t, errorCode_ResultRejected, reporter.receiveError("2"))
+}
+
+func TestSession_ResultIsOnlyAcceptedFromGameServers(t *testing.T) {
+	queues := newTestQueues(t)
+	first := connectTestClient(t, queues)
+	second := connectTestClient(t, queues)
+	first.send(`{"ID":"player_1","Level":5}`)
+	first.receiveNotification()
+	second.send(`{"ID":"player_2","Level":5}`)
+	second.receiveNotification()
+	assert.Equal(t, matchmaking.State_Started, first.receiveNotification().State)
+
+	// a player of the competition cannot report its result
+	reporter := connectTestClient(t, queues)
+	players := `"Players":[{"PlayerID":"player_1","Placement":1},{"PlayerID":"player_2","Placement":2}]`
+	reporter.send(`{"v":1,"type":"report_result","request_id":"1","payload":{"CompetitionID":1,` + players + `}}`)
+	assert.Equal(t, errorCode_Unauthenticated, reporter.receiveError("1"))
+	reporter.send(`{"Token":"guess","Result":{"CompetitionID":1,` + players + `}}`)
+	response := resultReportResponse{}
+	assert.NoError(t, json.Unmarshal([]byte(reporter.receiveLine()), &response))
+	assert.False(t, response.Accepted)
+
+	// the competition still takes the result of the game server
+	reporter.send(`{"v":1,"type":"report_result","request_id":"2","payload":{"Token":"` + testGameServerToken + `","CompetitionID":1,` + players + `}}`)
+	assert.Equal(t, responseType_ResultReported, reporter.receive().Type)
+}
+
+func TestSession_ReportDroppedCommand(t *testing.T) {
+	queues := newTestQueues(t)
+	first := connectTestClient(t, queues)
+	second := connectTestClient(t, queues)
+	first.send(`{"ID":"player_1","Level":5,"Queue":"backfill"}`)
+	first.receiveNotification()
+	second.send(`{"ID":"player_2","Level":5,"Queue":"backfill"}`)
+	second.receiveNotification()
+	assert.Equal(t, matchmaking.State_Started, first.receiveNotification().State)
+
+	reporter := connectTestClient(t, queues)
+	// a player cannot report another player as dropped
+	reporter.send(`{"v":1,"type":"report_dropped","request_id":"1","payload":{"CompetitionID":1,"PlayerID":"player_2","Queue":"backfill"}}`)
+	assert.Equal(t, errorCode_Unauthenticated, reporter.receiveError("1"))
+
+	reporter.send(`{"v":1,"type":"report_dropped","request_id":"2","payload":{"Token":"` + testGameServerToken + `","CompetitionID":1,"PlayerID":"player_2","Queue":"backfill"}}`)
+	assert.Equal(t, testEnvelope{V: 1, Type: responseType_DropReported, RequestID: "2"}, reporter.receive())
+
+	// the slot of the dropped player is filled by the next player
+	third := connectTestClient(t, queues)
+	third.send(`{"ID":"player_3","Level":5,"Queue":"backfill"}`)
+	assert.Equal(t, matchmaking.MatchMakingNotification{CompetitionID: 1, State: matchmaking.State_Backfill}, third.receiveNotification())
+}
+
+func TestSession_ErrorCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		code    errorCode
+	}{
+		{"unsupported version", `{"v":2,"type":"ping","request_id":"1"}`, errorCode_UnsupportedVersion},
+		{"unknown type", `{"v":1,"type":"dance","request_id":"1"}`, errorCode_UnknownType},
+		{"invalid join payload", `{"v":1,"type":"join","request_id":"1","payload":"player_1"}`, errorCode_InvalidPayload},
+		{"invalid result payload", `{"v":1,"type":"report_result","request_id":"1","payload":"1"}`, errorCode_InvalidPayload},
+		{"unknown field", `{"v":1,"type":"join","request_id":"1","payload":{"ID":"player_1","Level":5,"Colour":"red"}}`, errorCode_UnknownField},
+		{"missing player id", `{"v":1,"type":"join","request_id":"1","payload":{"Level":5}}`, errorCode_MissingPlayerID},
+		{"player id too long", `{"v":1,"type":"join","request_id":"1","payload":{"ID":"` + strings.Repeat("p", validation.DefaultMaxIDLength+1) + `","Level":5}}`, errorCode_PlayerIDTooLong},
+		{"invalid player id", `{"v":1,"type":"join","request_id":"1","payload":{"ID":"player 1","Level":5}}`, errorCode_InvalidPlayerID},
+		{"level out of range", `{"v":1,"type":"join","request_id":"1","payload":{"ID":"player_1","Level":5000}}`, errorCode_LevelOutOfRange},
+		{"invalid ping", `{"v":1,"type":"join","request_id":"1","payload":{"ID":"player_1","Level":5,"Pings":{"eu":-1}}}`, errorCode_InvalidPing},
+		{"unknown queue", `{"v":1,"type":"join","request_id":"1","payload":{"ID":"player_1","Level":5,"Queue":"unknown"}}`, errorCode_UnknownQueue},
+		{"invalid party", `{"v":1,"type":"join","request_id":"1","payload":{"Members":[{"ID":"player_1","Level":5},{"ID":"player_1","Level":5}]}}`, errorCode_InvalidParty},
+		{"leave without join", `{"v":1,"type":"leave","request_id":"1"}`, errorCode_NotInMatchmaking},
+		{"ready without join", `{"v":1,"type":"ready","request_id":"1"}`, errorCode_NotInMatchmaking},
+		{"result without token", `{"v":1,"type":"report_result","request_id":"1","payload":{"CompetitionID":1}}`, errorCode_Unauthenticated},
+		{"result with wrong token", `{"v":1,"type":"report_result","request_id":"1","payload":{"Token":"guess","CompetitionID":1}}`, errorCode_Unauthenticated},
+		{"unknown competition", `{"v":1,"type":"report_result","request_id":"1","payload":{"Token":"` + testGameServerToken + `","CompetitionID":99}}`, errorCode_ResultRejected},
+		{"result of unknown queue", `{"v":1,"type":"report_result","request_id":"1","payload":{"Token":"` + testGameServerToken + `","CompetitionID":1,"Queue":"unknown"}}`, errorCode_UnknownQueue},
+		{"invalid dropped player payload", `{"v":1,"type":"report_dropped","request_id":"1","payload":"player_1"}`, errorCode_InvalidPayload},
+		{"dropped player with wrong token", `{"v":1,"type":"report_dropped","request_id":"1","payload":{"Token":"guess","CompetitionID":1,"PlayerID":"player_1"}}`, errorCode_Unauthenticated},
+		{"dropped player without id", `{"v":1,"type":"report_dropped","request_id":"1","payload":{"Token":"` + testGameServerToken + `","CompetitionID":1}}`, errorCode_InvalidPayload},
+		{"dropped player of unknown queue", `{"v":1,"type":"report_dropped","request_id":"1","payload":{"Token":"` + testGameServerToken + `","CompetitionID":1,"PlayerID":"player_1","Queue":"unknown"}}`, errorCode_UnknownQueue},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := connectTestClient(t, newTestQueues(t))
+
+			client.send(test.request)
+			assert.Equal(t, test.code, client.receiveError("1"))
+
+			// a failed command does not close the connection
+			client.send(`{"v":1,"type":"ping","request_id":"2"}`)
+			assert.Equal(t, responseType_Pong, client.receive().Type)
+		})
+	}
+}
+
+func TestSession_InvalidJSONClosesUnversionedConnection(t *testing.T) {
+	client := connectTestClient(t, newTestQueues(t))
+
+	client.send(`not json`)
+	assert.Equal(t, errorCode_InvalidJSON, client.receiveError(""))
+	client.expectClosed()
+}
+
+func TestSession_JoinErrorCodes(t *testing.T) {
+	queues := newTestQueues(t)
+	first := connectTestClient(t, queues)
+	second := connectTestClient(t, queues)
+
+	first.send(`{"v":1,"type":"join","request_id":"1","payload":{"ID":"player_1","Level":5}}`)
+	assert.Equal(t, responseType_Joined, first.receive().Type)
+	first.receive()
+
+	first.send(`{"v":1,"type":"join","request_id":"2","payload":{"ID":"player_2","Level":5}}`)
+	assert.Equal(t, errorCode_AlreadyJoined, first.receiveError("2"))
+
+	second.send(`{"v":1,"type":"join","request_id":"1","payload":{"ID":"player_1","Level":5}}`)
+	assert.Equal(t, errorCode_AlreadyInMatchmaking, second.receiveError("1"))
+}
+
+func TestSession_JoinAfterShutdownIsRejected(t *testing.T) {
+	queues := newTestQueues(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, queues.Shutdown(ctx))
+
+	client := connectTestClient(t, queues)
+	client.send(`{"v":1,"type":"join","request_id":"1","payload":{"ID":"player_1","Level":5}}`)
+	assert.Equal(t, errorCode_ShuttingDown, client.receiveError("1"))
+}
+
+func TestToErrorResponse_UnknownErrorIsInternal(t *testing.T) {
+	assert.Equal(t, errorResponse{Code: errorCode_Internal, Message: "failure"}, toErrorResponse(errors.New("failure")))
+}
@@ -2,33 +2,61 @@ package server
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
-	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/SntrKslnn/matchmaking-service/internal/validation"
 )
 
 type MatchmakingTcpServer interface {
-	// Start starts the TCP server
+	// Start starts the TCP server and serves connections until the server is shut down
+	// @return an error if the server cannot listen on its port
 	Start() error
 
-	// Stop stops the TCP server
-	Stop() error
+	// Shutdown stops accepting connections and shuts down the matchmaking queues, which tells the waiting players
+	// that the server is shutting down and starts or aborts their competitions. The connections are closed
+	// once their players have received the outcome, or when the deadline passes
+	// @param ctx the deadline for the shutdown
+	// @return the error of the context if the shutdown did not finish before its deadline
+	Shutdown(ctx context.Context) error
 }
 
+// tcpWriteTimeout is how long writing a message may wait for a client that does not read its connection
+const tcpWriteTimeout = 10 * time.Second
+
 type tcpServer struct {
-	listener           net.Listener
-	port               int
-	matchmakingService matchmaking.MatchmakingService
+	port      int
+	queues    matchmaking.QueueRegistry
+	validator validation.JoinValidator
+	// gameServerAuth authenticates the game servers reporting competition results
+	gameServerAuth gameServerAuth
+
+	// mutex guards the listener
+	mutex       sync.Mutex
+	listener    net.Listener
+	connections *connectionTracker
 }
 
-func NewTCPServer(port int, matchmakingConfig matchmaking.MatchmakingConfig) MatchmakingTcpServer {
+// NewTCPServer creates a TCP server that lets players join the queues of the registry
+// @param port the port to listen on
+// @param queues the matchmaking queues players can join
+// @param validator the validator checking the players of join requests before they join a queue
+// @param gameServerToken the secret game servers send with their reports. Reports are refused if empty
+// @return a new TCP server
+func NewTCPServer(port int, queues matchmaking.QueueRegistry, validator validation.JoinValidator, gameServerToken string) MatchmakingTcpServer {
 	return &tcpServer{
-		port:               port,
-		matchmakingService: matchmaking.NewMatchmakingService(matchmakingConfig),
+		port:           port,
+		queues:         queues,
+		validator:      validator,
+		gameServerAuth: gameServerAuth{token: gameServerToken},
+		connections:    newConnectionTracker(),
 	}
 }
 
@@ -37,77 +65,124 @@ func (s *tcpServer) Start() error {
 	if err != nil {
 		return fmt.Errorf("failed to start TCP server: %w", err)
 	}
+
+	s.mutex.Lock()
+	if s.connections.isShuttingDown() {
+		s.mutex.Unlock()
+		return listener.Close()
+	}
 	s.listener = listener
+	s.mutex.Unlock()
 
 	slog.Info("TCP Server listening.", "port", s.port)
-	s.listenForConnections()
+	s.listenForConnections(listener)
 	return nil
 }
 
-func (s *tcpServer) listenForConnections() {
+// listenForConnections accepts connections until the listener is closed by the shutdown
+func (s *tcpServer) listenForConnections(listener net.Listener) {
 	for {
-		conn, err := s.listener.Accept()
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			slog.Info("TCP Server stopped listening.", "port", s.port)
+			return
+		}
 		if err != nil {
 			slog.Error("Error accepting connection", "error", err)
 			continue
 		}
+		if !s.connections.track(conn) {
+			conn.Close()
+			continue
+		}
 		go s.handleConnection(conn)
 	}
 }
 
-func (s *tcpServer) handlePlayerJoinRequest(reader *bufio.Reader) (<-chan matchmaking.MatchMakingNotification, error) {
-	data, err := reader.ReadString('\n')
-	if err != nil {
-		return nil, fmt.Errorf("error reading from connection: %w", err)
-	}
-
-	playerData := model.PlayerData{}
-	if err := json.Unmarshal([]byte(data), &playerData); err != nil {
-		return nil, fmt.Errorf("invalid JSON received: %w", err)
-	}
+// readRequests reads newline separated requests from the connection in a separate goroutine
+// so that a dropped connection is noticed while the player is waiting for notifications
+// @param reader the reader of the connection
+// @param connectionClosed is closed when the connection handler returns
+// @return a channel of received requests and a channel that is closed when the connection is dropped
+func (s *tcpServer) readRequests(reader *bufio.Reader, connectionClosed <-chan struct{}) (<-chan string, <-chan struct{}) {
+	requests := make(chan string)
+	disconnected := make(chan struct{})
+
+	s.connections.startGoroutine(func() {
+		defer close(disconnected)
+		for {
+			data, err := reader.ReadString('\n')
+			if err != nil {
+				slog.Info("Error reading from connection", "error", err)
+				return
+			}
+
+			select {
+			case requests <- data:
+			case <-connectionClosed:
+				return
+			}
+		}
+	})
 
-	return s.matchmakingService.HandlePlayerJoin(playerData), nil
+	return requests, disconnected
 }
 
-func (s *tcpServer) handlePlayerNotifications(conn net.Conn, notifications <-chan matchmaking.MatchMakingNotification) {
-	for notification := range notifications {
-		json, err := json.Marshal(notification)
-		if err != nil {
-			slog.Error("Error marshaling JSON response", "error", err)
-			continue
-		}
-
-		if _, err := conn.Write(json); err != nil {
-			slog.Error("Error writing to connection", "error", err)
-			return
-		}
+// writeResponse writes a message followed by a newline
+// A connection that cannot be written to before the write timeout is closed, so that its reader stops
+// and the session detaches its players
+func (s *tcpServer) writeResponse(conn net.Conn, response any) {
+	json, err := json.Marshal(response)
+	if err != nil {
+		slog.Error("Error marshaling JSON response", "error", err)
+		return
+	}
 
-		// just to separate the notifications from each other
-		if _, err := conn.Write([]byte("\n")); err != nil {
-			slog.Error("Error writing to connection", "error", err)
-			return
-		}
+	if err := conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout)); err != nil {
+		slog.Error("Error setting write deadline", "error", err)
+	}
+	// the newline just separates the notifications from each other
+	if _, err := conn.Write(append(json, '\n')); err != nil {
+		slog.Error("Error writing to connection. Closing connection", "error", err)
+		conn.Close()
 	}
 }
 
+// handleConnection serves the requests of a connection until the connection drops or the server shuts down
+// A connection whose players are in matchmaking is kept open during the shutdown until their notification
+// channels are closed, so the players receive the outcome of their competitions
 func (s *tcpServer) handleConnection(conn net.Conn) {
+	defer s.connections.untrack(conn)
 	defer conn.Close()
 
-	reader := bufio.NewReader(conn)
-	for {
-		notifications, err := s.handlePlayerJoinRequest(reader)
-		if err != nil {
-			slog.Error("Error handling player join request", "error", err)
-			conn.Write([]byte("closing connection... bye\n"))
-			return
+	connectionClosed := make(chan struct{})
+	defer close(connectionClosed)
+
+	requests, disconnected := s.readRequests(bufio.NewReader(conn), connectionClosed)
+	session := newSession(s.queues, s.validator, s.gameServerAuth, func(message any) { s.writeResponse(conn, message) }, s.connections.startGoroutine)
+	session.run(requests, disconnected, s.connections.shuttingDown)
+}
+
+func (s *tcpServer) Shutdown(ctx context.Context) error {
+	openConnections, started := s.connections.startShutdown(func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.listener != nil {
+			if err := s.listener.Close(); err != nil {
+				slog.Error("Error closing listener", "error", err)
+			}
 		}
-		s.handlePlayerNotifications(conn, notifications)
+	})
+	if !started {
+		return nil
 	}
-}
+	slog.Info("Shutting down TCP server", "connections", openConnections)
 
-func (s *tcpServer) Stop() error {
-	if s.listener != nil {
-		return s.listener.Close()
+	queuesErr := s.queues.Shutdown(ctx)
+	if err := s.connections.wait(ctx); err != nil {
+		slog.Warn("Shutdown deadline passed. Closed remaining connections")
+		return errors.Join(queuesErr, err)
 	}
-	return nil
+	slog.Info("TCP server shut down")
+	return queuesErr
 }
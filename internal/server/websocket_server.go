@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
+	"github.com/SntrKslnn/matchmaking-service/internal/validation"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketPath is the path the WebSocket server accepts connections on
+const WebSocketPath = "/ws"
+
+const (
+	// maxWebSocketMessageSize is the size limit of a message received from a client
+	maxWebSocketMessageSize = 64 * 1024
+
+	// webSocketCloseTimeout is the time given to send the close frame when the server ends a connection
+	webSocketCloseTimeout = time.Second
+
+	// webSocketWriteTimeout is how long writing a message may wait for a client that does not read its connection
+	webSocketWriteTimeout = 10 * time.Second
+)
+
+type MatchmakingWebSocketServer interface {
+	// Start starts the WebSocket server and serves connections until the server is shut down
+	// @return an error if the server cannot listen on its port
+	Start() error
+
+	// Shutdown stops accepting connections and shuts down the matchmaking queues, which tells the waiting players
+	// that the server is shutting down and starts or aborts their competitions. The connections are closed
+	// once their players have received the outcome, or when the deadline passes
+	// @param ctx the deadline for the shutdown
+	// @return the error of the context if the shutdown did not finish before its deadline
+	Shutdown(ctx context.Context) error
+}
+
+// webSocketServer serves the TCP protocol over WebSocket connections for clients that cannot open TCP sockets
+// Every text or binary message is a request, and every response and notification is sent as a text message
+type webSocketServer struct {
+	port      int
+	queues    matchmaking.QueueRegistry
+	validator validation.JoinValidator
+
+	httpServer  *http.Server
+	upgrader    websocket.Upgrader
+	connections *connectionTracker
+}
+
+// NewWebSocketServer creates a WebSocket server that lets players join the queues of the registry
+// The server speaks the same JSON messages as the TCP server
+// @param port the port to listen on
+// @param allowedOrigins the origins of the browser pages that may connect, for example https://play.example.com
+// Only pages served from the host of the server may connect if empty
+// @param queues the matchmaking queues players can join
+// @param validator the validator checking the players of join requests before they join a queue
+// @return a new WebSocket server
+func NewWebSocketServer(port int, allowedOrigins []string, queues matchmaking.QueueRegistry, validator validation.JoinValidator) MatchmakingWebSocketServer {
+	s := &webSocketServer{
+		port:        port,
+		queues:      queues,
+		validator:   validator,
+		connections: newConnectionTracker(),
+	}
+	if len(allowedOrigins) > 0 {
+		s.upgrader.CheckOrigin = func(r *http.Request) bool {
+			// clients other than browsers do not send an origin
+			origin := r.Header.Get("Origin")
+			return origin == "" || slices.Contains(allowedOrigins, origin)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+WebSocketPath, s.handleUpgrade)
+	s.httpServer = &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+	return s
+}
+
+func (s *webSocketServer) Start() error {
+	slog.Info("WebSocket Server listening.", "port", s.port, "path", WebSocketPath)
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		slog.Info("WebSocket Server stopped listening.", "port", s.port)
+		return nil
+	}
+	return fmt.Errorf("failed to start WebSocket server: %w", err)
+}
+
+// handleUpgrade turns an HTTP request into a WebSocket connection and serves it
+// The upgrader answers requests that are not valid WebSocket handshakes with an HTTP error
+func (s *webSocketServer) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Warn("Rejected WebSocket connection", "remote_addr", r.RemoteAddr, "error", err)
+		return
+	}
+	if !s.connections.track(conn) {
+		s.closeConnection(conn, websocket.CloseGoingAway)
+		return
+	}
+	s.handleConnection(conn)
+}
+
+// handleConnection serves the requests of a connection until the connection drops or the server shuts down
+// Closing the socket while in matchmaking removes the players from matchmaking, like a dropped TCP connection
+func (s *webSocketServer) handleConnection(conn *websocket.Conn) {
+	defer s.connections.untrack(conn)
+	closeCode := websocket.CloseNormalClosure
+	defer func() {
+		s.closeConnection(conn, closeCode)
+	}()
+
+	connectionClosed := make(chan struct{})
+	defer close(connectionClosed)
+
+	conn.SetReadLimit(maxWebSocketMessageSize)
+	requests, disconnected := s.readRequests(conn, connectionClosed)
+	// browsers are never game servers, so the sessions refuse reports
+	session := newSession(s.queues, s.validator, gameServerAuth{}, func(message any) { s.writeMessage(conn, message) }, s.connections.startGoroutine)
+	session.run(requests, disconnected, s.connections.shuttingDown)
+	if s.connections.isShuttingDown() {
+		closeCode = websocket.CloseGoingAway
+	}
+}
+
+// readRequests reads the messages of the connection in a separate goroutine
+// so that a closed socket is noticed while the player is waiting for notifications
+// @param conn the connection to read from
+// @param connectionClosed is closed when the connection handler returns
+// @return a channel of received requests and a channel that is closed when the connection is dropped
+func (s *webSocketServer) readRequests(conn *websocket.Conn, connectionClosed <-chan struct{}) (<-chan string, <-chan struct{}) {
+	requests := make(chan string)
+	disconnected := make(chan struct{})
+
+	s.connections.startGoroutine(func() {
+		defer close(disconnected)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				slog.Info("Error reading from WebSocket connection", "error", err)
+				return
+			}
+
+			select {
+			case requests <- string(data):
+			case <-connectionClosed:
+				return
+			}
+		}
+	})
+
+	return requests, disconnected
+}
+
+// writeMessage sends a response or a notification as a text message
+// Only the session writes messages, so there is never more than one writer at a time
+// A connection that cannot be written to before the write timeout is closed, so that its reader stops
+// and the session detaches its players
+func (s *webSocketServer) writeMessage(conn *websocket.Conn, message any) {
+	if err := conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout)); err != nil {
+		slog.Error("Error setting WebSocket write deadline", "error", err)
+	}
+	if err := conn.WriteJSON(message); err != nil {
+		slog.Error("Error writing to WebSocket connection. Closing connection", "error", err)
+		conn.Close()
+	}
+}
+
+// closeConnection sends a close frame with the given code and closes the connection
+func (s *webSocketServer) closeConnection(conn *websocket.Conn, closeCode int) {
+	closeMessage := websocket.FormatCloseMessage(closeCode, "")
+	if err := conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(webSocketCloseTimeout)); err != nil && !errors.Is(err, websocket.ErrCloseSent) {
+		slog.Info("Error sending WebSocket close frame", "error", err)
+	}
+	conn.Close()
+}
+
+func (s *webSocketServer) Shutdown(ctx context.Context) error {
+	openConnections, started := s.connections.startShutdown(nil)
+	if !started {
+		return nil
+	}
+	slog.Info("Shutting down WebSocket server", "connections", openConnections)
+
+	// stops listening. Upgraded connections are not served by the HTTP server anymore, so it does not wait for them
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		slog.Error("Error closing WebSocket listener", "error", err)
+	}
+	queuesErr := s.queues.Shutdown(ctx)
+	if err := s.connections.wait(ctx); err != nil {
+		slog.Warn("Shutdown deadline passed. Closed remaining WebSocket connections")
+		return errors.Join(queuesErr, err)
+	}
+	slog.Info("WebSocket server shut down")
+	return queuesErr
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/matchmaking"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestWebSocketServer serves the handler of a WebSocket server on a test HTTP server
+// @return the WebSocket URL of the server
+func startTestWebSocketServer(t *testing.T, allowedOrigins []string, queues matchmaking.QueueRegistry) string {
+	server := NewWebSocketServer(0, allowedOrigins, queues, newTestValidator(t)).(*webSocketServer)
+	httpServer := httptest.NewServer(server.httpServer.Handler)
+	t.Cleanup(httpServer.Close)
+	return "ws" + strings.TrimPrefix(httpServer.URL, "http") + WebSocketPath
+}
+
+// dialTestWebSocket connects to a WebSocket server, sending the origin header if it is not empty
+func dialTestWebSocket(url string, origin string) (*websocket.Conn, *http.Response, error) {
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, response, err := websocket.DefaultDialer.Dial(url, header)
+	if conn != nil {
+		conn.SetReadDeadline(time.Now().Add(testReadTimeout))
+	}
+	return conn, response, err
+}
+
+func TestWebSocketServer_JoinOverWebSocket(t *testing.T) {
+	url := startTestWebSocketServer(t, nil, newTestQueues(t))
+	conn, _, err := dialTestWebSocket(url, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"v":1,"type":"join","request_id":"1","payload":{"ID":"player_1","Level":5}}`)))
+	joined := testEnvelope{}
+	assert.NoError(t, conn.ReadJSON(&joined))
+	assert.Equal(t, responseType_Joined, joined.Type)
+	assert.Equal(t, "1", joined.RequestID)
+
+	notification := testEnvelope{}
+	assert.NoError(t, conn.ReadJSON(&notification))
+	assert.Equal(t, matchmaking.MatchMakingNotification{CompetitionID: 1, State: matchmaking.State_WaitingForPlayers}, decodePayload[matchmaking.MatchMakingNotification](t, notification))
+}
+
+func TestWebSocketServer_ClosingSocketLeavesMatchmaking(t *testing.T) {
+	queues := newTestQueues(t)
+	url := startTestWebSocketServer(t, nil, queues)
+	conn, _, err := dialTestWebSocket(url, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"ID":"player_1","Level":5}`)))
+	notification := matchmaking.MatchMakingNotification{}
+	assert.NoError(t, conn.ReadJSON(&notification))
+	assert.Equal(t, matchmaking.State_WaitingForPlayers, notification.State)
+
+	queue, _ := queues.GetQueue(matchmaking.DefaultQueueName)
+	_, err = queue.GetPlayerStatus("player_1")
+	assert.NoError(t, err)
+
+	conn.Close()
+	assert.Eventually(t, func() bool {
+		_, err := queue.GetPlayerStatus("player_1")
+		return errors.Is(err, matchmaking.ErrPlayerNotInMatchmaking)
+	}, testReadTimeout, 10*time.Millisecond)
+}
+
+func TestWebSocketServer_OriginCheck(t *testing.T) {
+	queues := newTestQueues(t)
+	allowedOriginURL := startTestWebSocketServer(t, []string{"https://play.example.com"}, queues)
+	sameHostURL := startTestWebSocketServer(t, nil, queues)
+
+	tests := []struct {
+		name     string
+		url      string
+		origin   string
+		accepted bool
+	}{
+		{"allowed origin", allowedOriginURL, "https://play.example.com", true},
+		{"other origin", allowedOriginURL, "https://evil.example.com", false},
+		{"no origin", allowedOriginURL, "", true},
+		{"other origin without allowed origins", sameHostURL, "https://play.example.com", false},
+		{"same host without allowed origins", sameHostURL, "http" + strings.TrimPrefix(strings.TrimSuffix(sameHostURL, WebSocketPath), "ws"), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, response, err := dialTestWebSocket(test.url, test.origin)
+			if test.accepted {
+				if assert.NoError(t, err) {
+					conn.Close()
+				}
+				return
+			}
+			assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+			if assert.NotNil(t, response) {
+				assert.Equal(t, http.StatusForbidden, response.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+)
+
+type joinValidator struct {
+	config ValidationConfig
+	// idPattern is the compiled IDPattern of the config. Nil if ids are not matched against a pattern
+	idPattern *regexp.Regexp
+}
+
+func newJoinValidator(config ValidationConfig) (*joinValidator, error) {
+	if config.MaxLevel < config.MinLevel {
+		return nil, fmt.Errorf("max level %d is lower than min level %d", config.MaxLevel, config.MinLevel)
+	}
+	if config.MaxIDLength < 0 {
+		return nil, fmt.Errorf("max id length %d is negative", config.MaxIDLength)
+	}
+
+	validator := &joinValidator{config: config}
+	if config.IDPattern != "" {
+		idPattern, err := regexp.Compile(config.IDPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid player id pattern: %w", err)
+		}
+		validator.idPattern = idPattern
+	}
+	return validator, nil
+}
+
+func (v *joinValidator) validatePlayer(player model.PlayerData) error {
+	if err := v.validateID(player.ID); err != nil {
+		return err
+	}
+	if player.Level < v.config.MinLevel || player.Level > v.config.MaxLevel {
+		return fmt.Errorf("%w: level %d of player %s is not between %d and %d", ErrLevelOutOfRange, player.Level, player.ID, v.config.MinLevel, v.config.MaxLevel)
+	}
+	for region, ping := range player.Pings {
+		if region == "" {
+			return fmt.Errorf("%w: player %s has a ping to a region without a name", ErrInvalidPing, player.ID)
+		}
+		if ping < 0 {
+			return fmt.Errorf("%w: ping %d of player %s to region %s is negative", ErrInvalidPing, ping, player.ID, region)
+		}
+	}
+	return nil
+}
+
+func (v *joinValidator) validateID(id string) error {
+	if id == "" {
+		return ErrMissingPlayerID
+	}
+	if v.config.MaxIDLength > 0 && len(id) > v.config.MaxIDLength {
+		return fmt.Errorf("%w: player id has %d bytes, at most %d are allowed", ErrPlayerIDTooLong, len(id), v.config.MaxIDLength)
+	}
+	if v.idPattern != nil && !v.idPattern.MatchString(id) {
+		return fmt.Errorf("%w: player id %q does not match %s", ErrInvalidPlayerID, id, v.config.IDPattern)
+	}
+	return nil
+}
+
+// validateParty validates every member of a party
+// The size of the party and duplicate members are checked by the matchmaking service
+func (v *joinValidator) validateParty(members []model.PlayerData) error {
+	for i, member := range members {
+		if err := v.validatePlayer(member); err != nil {
+			return fmt.Errorf("party member %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package validation
+
+import (
+	"errors"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+)
+
+const (
+	// DefaultMinLevel is the lowest level a player may join with by default
+	DefaultMinLevel = 0
+
+	// DefaultMaxLevel is the highest level a player may join with by default
+	DefaultMaxLevel = 1000
+
+	// DefaultMaxIDLength is the maximum length of a player id by default
+	DefaultMaxIDLength = 64
+
+	// DefaultIDPattern is the pattern player ids must match by default
+	DefaultIDPattern = `^[A-Za-z0-9_-]+$`
+)
+
+var (
+	// ErrMissingPlayerID is returned when a player has no id
+	ErrMissingPlayerID = errors.New("player id is missing")
+
+	// ErrPlayerIDTooLong is returned when a player id is longer than the configured maximum length
+	ErrPlayerIDTooLong = errors.New("player id is too long")
+
+	// ErrInvalidPlayerID is returned when a player id does not match the configured pattern
+	ErrInvalidPlayerID = errors.New("player id has an invalid format")
+
+	// ErrLevelOutOfRange is returned when the level of a player is outside the configured bounds
+	ErrLevelOutOfRange = errors.New("player level is out of range")
+
+	// ErrInvalidPing is returned when a player has a negative ping or a ping to a region without a name
+	ErrInvalidPing = errors.New("player ping is invalid")
+)
+
+// ValidationConfig defines which players may join matchmaking
+type ValidationConfig struct {
+	// MinLevel is the lowest level a player may join with
+	MinLevel int
+	// MaxLevel is the highest level a player may join with. Must not be lower than MinLevel
+	MaxLevel int
+
+	// MaxIDLength is the maximum length of a player id in bytes. The length is not limited if 0
+	MaxIDLength int
+	// IDPattern is the regular expression player ids must match. Any id is accepted if empty
+	IDPattern string
+}
+
+// JoinValidator checks the players of join requests before they are handed to matchmaking
+type JoinValidator interface {
+	// ValidatePlayer checks the data of a single player
+	// @param player the player joining matchmaking
+	// @return an error wrapping one of the validation errors if the player is not accepted
+	ValidatePlayer(player model.PlayerData) error
+
+	// ValidateParty checks the data of every member of a party
+	// @param members the members of the party joining matchmaking
+	// @return an error wrapping one of the validation errors for the first member that is not accepted
+	ValidateParty(members []model.PlayerData) error
+}
+
+// NewJoinValidator creates a validator that accepts the players allowed by the config
+// @param config the validation rules
+// @return a new join validator, or an error if the config is invalid
+func NewJoinValidator(config ValidationConfig) (JoinValidator, error) {
+	return newJoinValidator(config)
+}
+
+func (v *joinValidator) ValidatePlayer(player model.PlayerData) error {
+	return v.validatePlayer(player)
+}
+
+func (v *joinValidator) ValidateParty(members []model.PlayerData) error {
+	return v.validateParty(members)
+}
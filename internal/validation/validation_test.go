@@ -0,0 +1,84 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SntrKslnn/matchmaking-service/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestJoinValidator(t *testing.T) JoinValidator {
+	validator, err := NewJoinValidator(ValidationConfig{
+		MinLevel:    1,
+		MaxLevel:    100,
+		MaxIDLength: 16,
+		IDPattern:   DefaultIDPattern,
+	})
+	assert.NoError(t, err)
+	return validator
+}
+
+func TestJoinValidator_ValidatePlayer(t *testing.T) {
+	validator := newTestJoinValidator(t)
+
+	testCases := []struct {
+		player        model.PlayerData
+		expectedError error
+	}{
+		{player: model.PlayerData{ID: "test_user_1", Level: 1}},
+		{player: model.PlayerData{ID: "test-user-2", Level: 100, Pings: map[string]int{"eu-west": 0}}},
+		{player: model.PlayerData{Level: 5}, expectedError: ErrMissingPlayerID},
+		{player: model.PlayerData{ID: strings.Repeat("a", 17), Level: 5}, expectedError: ErrPlayerIDTooLong},
+		{player: model.PlayerData{ID: "test user", Level: 5}, expectedError: ErrInvalidPlayerID},
+		{player: model.PlayerData{ID: "test_user_1", Level: 0}, expectedError: ErrLevelOutOfRange},
+		{player: model.PlayerData{ID: "test_user_1", Level: -3}, expectedError: ErrLevelOutOfRange},
+		{player: model.PlayerData{ID: "test_user_1", Level: 101}, expectedError: ErrLevelOutOfRange},
+		{player: model.PlayerData{ID: "test_user_1", Level: 5, Pings: map[string]int{"eu-west": -1}}, expectedError: ErrInvalidPing},
+		{player: model.PlayerData{ID: "test_user_1", Level: 5, Pings: map[string]int{"": 20}}, expectedError: ErrInvalidPing},
+	}
+
+	for _, testCase := range testCases {
+		err := validator.ValidatePlayer(testCase.player)
+		if testCase.expectedError == nil {
+			assert.NoError(t, err, testCase.player.ID)
+		} else {
+			assert.ErrorIs(t, err, testCase.expectedError, testCase.player.ID)
+		}
+	}
+}
+
+func TestJoinValidator_ValidateParty(t *testing.T) {
+	validator := newTestJoinValidator(t)
+
+	assert.NoError(t, validator.ValidateParty([]model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 6},
+	}))
+
+	err := validator.ValidateParty([]model.PlayerData{
+		{ID: "test_user_1", Level: 5},
+		{ID: "test_user_2", Level: 600},
+	})
+	assert.ErrorIs(t, err, ErrLevelOutOfRange)
+	assert.ErrorContains(t, err, "party member 2")
+}
+
+func TestNewJoinValidator_InvalidConfig(t *testing.T) {
+	_, err := NewJoinValidator(ValidationConfig{MinLevel: 10, MaxLevel: 5})
+	assert.Error(t, err)
+
+	_, err = NewJoinValidator(ValidationConfig{MaxLevel: 5, MaxIDLength: -1})
+	assert.Error(t, err)
+
+	_, err = NewJoinValidator(ValidationConfig{MaxLevel: 5, IDPattern: "[a-"})
+	assert.Error(t, err)
+}
+
+func TestJoinValidator_NoIDRules(t *testing.T) {
+	validator, err := NewJoinValidator(ValidationConfig{MaxLevel: 10})
+	assert.NoError(t, err)
+
+	assert.NoError(t, validator.ValidatePlayer(model.PlayerData{ID: strings.Repeat("any id ", 20), Level: 10}))
+	assert.ErrorIs(t, validator.ValidatePlayer(model.PlayerData{Level: 10}), ErrMissingPlayerID)
+}